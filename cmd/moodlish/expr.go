@@ -0,0 +1,310 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// constants are the names that may be used in formulas without defining them.
+var constants = map[string]float64{
+	"pi": math.Pi,
+	"e":  math.E,
+}
+
+// functions are the functions that may be used in formulas.
+var functions = map[string]func(args ...float64) (float64, error){
+	"sqrt":  unary(math.Sqrt),
+	"abs":   unary(math.Abs),
+	"exp":   unary(math.Exp),
+	"ln":    unary(math.Log),
+	"log":   unary(math.Log10),
+	"sin":   unary(math.Sin),
+	"cos":   unary(math.Cos),
+	"tan":   unary(math.Tan),
+	"floor": unary(math.Floor),
+	"ceil":  unary(math.Ceil),
+	"round": func(args ...float64) (float64, error) {
+		switch len(args) {
+		case 1:
+			return math.Round(args[0]), nil
+		case 2:
+			scale := math.Pow(10, args[1])
+			return math.Round(args[0]*scale) / scale, nil
+		}
+		return 0, fmt.Errorf("round takes 1 or 2 arguments, but received %d", len(args))
+	},
+	"min": func(args ...float64) (float64, error) {
+		if len(args) == 0 {
+			return 0, fmt.Errorf("min takes at least 1 argument")
+		}
+		v := args[0]
+		for _, a := range args[1:] {
+			v = math.Min(v, a)
+		}
+		return v, nil
+	},
+	"max": func(args ...float64) (float64, error) {
+		if len(args) == 0 {
+			return 0, fmt.Errorf("max takes at least 1 argument")
+		}
+		v := args[0]
+		for _, a := range args[1:] {
+			v = math.Max(v, a)
+		}
+		return v, nil
+	},
+}
+
+// unary wraps f as a function taking a single argument.
+func unary(f func(float64) float64) func(args ...float64) (float64, error) {
+	return func(args ...float64) (float64, error) {
+		if len(args) != 1 {
+			return 0, fmt.Errorf("Function takes 1 argument, but received %d", len(args))
+		}
+		return f(args[0]), nil
+	}
+}
+
+// syntaxError describes a formula that cannot be parsed or that refers to
+// unknown names, as opposed to a formula that cannot be evaluated.
+type syntaxError struct {
+	msg string
+}
+
+func newSyntaxError(format string, a ...any) *syntaxError {
+	return &syntaxError{fmt.Sprintf(format, a...)}
+}
+
+func (e *syntaxError) Error() string {
+	return e.msg
+}
+
+// evaluate computes the value of the arithmetic expression s using the given
+// variables. Supported operators are +, -, *, / and ^ (with the usual
+// precedence), along with parentheses, the constants pi and e, and the
+// functions listed in functions. If s is not a valid formula, the returned
+// error wraps a *syntaxError.
+func evaluate(s string, vars map[string]float64) (float64, error) {
+	p := &exprParser{s: s, vars: vars}
+	v, err := p.sum()
+	if err != nil {
+		return 0, fmt.Errorf("Formula %q: %w", s, err)
+	}
+	p.skipSpace()
+	if p.pos < len(p.s) {
+		return 0, fmt.Errorf("Formula %q: %w", s, newSyntaxError("Unexpected %q", p.s[p.pos:]))
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("Formula %q is undefined for %v", s, vars)
+	}
+	return v, nil
+}
+
+// exprParser is a recursive descent parser evaluating an expression.
+type exprParser struct {
+	s    string
+	pos  int
+	vars map[string]float64
+}
+
+// sum parses terms separated by + or -.
+func (p *exprParser) sum() (float64, error) {
+	v, err := p.product()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch p.next() {
+		case '+':
+			p.pos++
+			w, err := p.product()
+			if err != nil {
+				return 0, err
+			}
+			v += w
+		case '-':
+			p.pos++
+			w, err := p.product()
+			if err != nil {
+				return 0, err
+			}
+			v -= w
+		default:
+			return v, nil
+		}
+	}
+}
+
+// product parses factors separated by * or /.
+func (p *exprParser) product() (float64, error) {
+	v, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch p.next() {
+		case '*':
+			p.pos++
+			w, err := p.unary()
+			if err != nil {
+				return 0, err
+			}
+			v *= w
+		case '/':
+			p.pos++
+			w, err := p.unary()
+			if err != nil {
+				return 0, err
+			}
+			if w == 0 {
+				return 0, fmt.Errorf("Division by zero")
+			}
+			v /= w
+		default:
+			return v, nil
+		}
+	}
+}
+
+// unary parses a factor with an optional sign. The sign binds less tightly
+// than ^, so -2^2 is -4.
+func (p *exprParser) unary() (float64, error) {
+	switch p.next() {
+	case '-':
+		p.pos++
+		v, err := p.unary()
+		return -v, err
+	case '+':
+		p.pos++
+		return p.unary()
+	}
+	return p.power()
+}
+
+// power parses a base with an optional exponent. Exponentiation is right
+// associative.
+func (p *exprParser) power() (float64, error) {
+	v, err := p.atom()
+	if err != nil {
+		return 0, err
+	}
+	if p.next() != '^' {
+		return v, nil
+	}
+	p.pos++
+	w, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+	return math.Pow(v, w), nil
+}
+
+// atom parses a number, a variable, a function call or a parenthesized
+// expression.
+func (p *exprParser) atom() (float64, error) {
+	c := p.next()
+	switch {
+	case c == '(':
+		p.pos++
+		v, err := p.sum()
+		if err != nil {
+			return 0, err
+		}
+		if p.next() != ')' {
+			return 0, newSyntaxError("Missing )")
+		}
+		p.pos++
+		return v, nil
+	case c == '.' || ('0' <= c && c <= '9'):
+		start := p.pos
+		for p.pos < len(p.s) && strings.IndexByte("0123456789.", p.s[p.pos]) >= 0 {
+			p.pos++
+		}
+		// Exponent, e.g. 1e-3
+		if p.pos < len(p.s) && (p.s[p.pos] == 'e' || p.s[p.pos] == 'E') {
+			end := p.pos + 1
+			if end < len(p.s) && (p.s[end] == '+' || p.s[end] == '-') {
+				end++
+			}
+			if end < len(p.s) && '0' <= p.s[end] && p.s[end] <= '9' {
+				for p.pos = end; p.pos < len(p.s) && '0' <= p.s[p.pos] && p.s[p.pos] <= '9'; p.pos++ {
+				}
+			}
+		}
+		v, err := strconv.ParseFloat(p.s[start:p.pos], 64)
+		if err != nil {
+			return 0, newSyntaxError("Invalid number %q", p.s[start:p.pos])
+		}
+		return v, nil
+	case c == '_' || unicode.IsLetter(rune(c)):
+		start := p.pos
+		for p.pos < len(p.s) && (p.s[p.pos] == '_' || unicode.IsLetter(rune(p.s[p.pos])) || unicode.IsDigit(rune(p.s[p.pos]))) {
+			p.pos++
+		}
+		name := p.s[start:p.pos]
+		if p.next() == '(' {
+			return p.call(name)
+		}
+		if v, ok := p.vars[name]; ok {
+			return v, nil
+		}
+		if v, ok := constants[name]; ok {
+			return v, nil
+		}
+		return 0, newSyntaxError("Unknown parameter %q", name)
+	case c == 0:
+		return 0, newSyntaxError("Unexpected end of formula")
+	}
+	return 0, newSyntaxError("Unexpected %q", p.s[p.pos:])
+}
+
+// call parses the arguments of the function name and calls it.
+func (p *exprParser) call(name string) (float64, error) {
+	f, ok := functions[name]
+	if !ok {
+		return 0, newSyntaxError("Unknown function %q", name)
+	}
+	p.pos++ // Skip (
+
+	var args []float64
+	if p.next() == ')' {
+		p.pos++
+		return f(args...)
+	}
+	for {
+		v, err := p.sum()
+		if err != nil {
+			return 0, err
+		}
+		args = append(args, v)
+
+		switch p.next() {
+		case ',':
+			p.pos++
+		case ')':
+			p.pos++
+			return f(args...)
+		default:
+			return 0, newSyntaxError("Expected , or ) in arguments of %s", name)
+		}
+	}
+}
+
+// next skips whitespace and returns the next character, or 0 at the end of the
+// input.
+func (p *exprParser) next() byte {
+	p.skipSpace()
+	if p.pos >= len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *exprParser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t' || p.s[p.pos] == '\n') {
+		p.pos++
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	vars := map[string]float64{"a": 3, "b_2": -2}
+	testCases := []struct {
+		s    string
+		want float64
+	}{
+		{"1 + 2*3", 7},
+		{"(1+2)*3", 9},
+		{"2^3^2", 512},
+		{"-2^2", -4},
+		{"a*b_2 - -1", -5},
+		{"10/4", 2.5},
+		{"1.5e2 + .5", 150.5},
+		{"sqrt(a^2 + 16)", 5},
+		{"round(pi, 2)", 3.14},
+		{"max(a, 7, b_2) + min(1, 2)", 8},
+		{"ln(e)", 1},
+		{"log(1000)", 3},
+	}
+	for _, v := range testCases {
+		got, err := evaluate(v.s, vars)
+		if err != nil {
+			t.Errorf("Evaluating %q caused error: %s", v.s, err)
+		} else if math.Abs(got-v.want) > 1e-12 {
+			t.Errorf("Evaluating %q gave %g, but expected %g", v.s, got, v.want)
+		}
+	}
+
+	for _, s := range []string{"1 +", "(1", "c + 1", "foo(1)", "1/0", "sqrt(-1)", "round(1, 2, 3)", "1 2", "2 $ 3"} {
+		if _, err := evaluate(s, vars); err == nil {
+			t.Errorf("Evaluating %q failed to return an error", s)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Command moodlish generates a Moodle question bank from a question template,
+// such that questions can be made without writing Go code.
+//
+// Usage:
+//
+//	moodlish [flags] template.toml
+//
+// The flags are:
+//
+//	-n int
+//		Number of questions to generate (default 10)
+//	-o file
+//		Output file (default: the template name with extension .xml)
+//	-category name
+//		Question category (default: the name of the output file)
+//	-seed int
+//		Seed for drawing parameters, allowing a bank to be regenerated
+//		(default 0, meaning a random seed)
+//
+// A template is a TOML file such as
+//
+//	type = "numerical"   # multichoice, shorttext or numerical
+//	points = 1
+//	text = 'Compute \(\frac{ {{a}} }{ {{b}} }\) to two decimals.'
+//
+//	[params]
+//	a = {min = 1, max = 20}
+//	b = {min = -5, max = 5, zero = false}
+//	q = "a/b"
+//
+//	[[answers]]
+//	formula = "round(q, 2)"
+//	tolerance = 0.005
+//	grade = 100
+//	feedback = 'Correct, the result is {{round(q, 4)}}.'
+//
+// The parameters are drawn in the order of the file. A range has the keys min
+// and max along with the optional keys step (default 1) and zero (whether zero
+// is allowed, default true). A string defines a parameter computed from the
+// previous ones.
+//
+// In text, feedback and answer texts, {{formula}} is replaced by the value of
+// the formula. Formulas may use +, -, *, /, ^, parentheses, the constants pi
+// and e, and the functions sqrt, abs, exp, ln, log, sin, cos, tan, floor, ceil,
+// round, min and max. Answers are given either by a text or by a formula. For
+// multiple choice questions, parameters are redrawn until all answers differ.
+//
+// The optional keys figure and figure_alt contain TikZ code and an alternative
+// description of an image which is compiled and added below the text. This
+// requires a LaTeX installation. Placeholders may be used in the figure as
+// well, but text such as {{$x$}} which is not a formula in the parameters is
+// left unchanged, since double braces are common in TikZ.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ReneBoedker/MoodlishInquisition/moodle"
+	"github.com/ReneBoedker/MoodlishInquisition/unif"
+)
+
+func main() {
+	count := flag.Int("n", 10, "Number of questions to generate")
+	out := flag.String("o", "", "Output file (default: the template name with extension .xml)")
+	category := flag.String("category", "", "Question category (default: the name of the output file)")
+	seed := flag.Uint64("seed", 0, "Seed for drawing parameters (0 means a random seed)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] template.toml\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0), *out, *category, *count, *seed); err != nil {
+		fmt.Fprintln(os.Stderr, "moodlish:", err)
+		os.Exit(1)
+	}
+}
+
+// run generates nQuestions questions from the template in the file tmplName
+// and writes them to outName.
+func run(tmplName, outName, category string, nQuestions int, seed uint64) error {
+	if nQuestions < 1 {
+		return fmt.Errorf("Number of questions must be positive, but received %d", nQuestions)
+	}
+	content, err := os.ReadFile(tmplName)
+	if err != nil {
+		return err
+	}
+	tmpl, err := loadTemplate(string(content))
+	if err != nil {
+		return fmt.Errorf("%s: %w", tmplName, err)
+	}
+
+	if outName == "" {
+		outName = strings.TrimSuffix(tmplName, filepath.Ext(tmplName)) + ".xml"
+	}
+	if category == "" {
+		category = strings.TrimSuffix(filepath.Base(outName), filepath.Ext(outName))
+	}
+	if seed != 0 {
+		unif.Seed(seed)
+	}
+
+	// Instantiate the questions before writing, since the generator passed to
+	// the question bank cannot report errors
+	questions := make([]moodle.Question, nQuestions)
+	for i := range questions {
+		if questions[i], err = tmpl.instantiate(); err != nil {
+			return fmt.Errorf("Question %d: %w", i+1, err)
+		}
+	}
+
+	i := 0
+	return moodle.GenerateQuestionBankInCategory(outName, category, nQuestions, func() moodle.Question {
+		i++
+		return questions[i-1]
+	})
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ReneBoedker/MoodlishInquisition/graphics"
+	"github.com/ReneBoedker/MoodlishInquisition/moodle"
+	"github.com/ReneBoedker/MoodlishInquisition/unif"
+)
+
+// questionTemplate describes the questions generated from a template file.
+type questionTemplate struct {
+	kind      string // multichoice, shorttext or numerical
+	points    uint
+	text      string
+	figure    string // TikZ code
+	figureAlt string
+	params    []param
+	answers   []answerTemplate
+}
+
+// param is a parameter of a template. It is either drawn uniformly from the
+// range min, min+step, ..., max or computed from a formula.
+type param struct {
+	name           string
+	formula        string
+	min, max, step float64
+	allowZero      bool
+}
+
+// answerTemplate is an answer of a template. The answer is given either by a
+// text containing placeholders or by a formula.
+type answerTemplate struct {
+	text      string
+	formula   string
+	grade     float64
+	feedback  string
+	tolerance string // Formula
+}
+
+// maxAttempts is the number of times parameters are drawn when trying to
+// generate a multiple choice question with distinct answers.
+const maxAttempts = 100
+
+// loadTemplate reads a question template from the TOML document s.
+func loadTemplate(s string) (*questionTemplate, error) {
+	root, err := parseToml(s)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkKeys(root, "type", "points", "text", "figure", "figure_alt", "params", "answers"); err != nil {
+		return nil, err
+	}
+
+	t := &questionTemplate{points: 1}
+	if t.kind, err = getString(root, "type", true); err != nil {
+		return nil, err
+	}
+	switch t.kind {
+	case "multichoice", "shorttext", "numerical":
+	default:
+		return nil, fmt.Errorf("Unsupported question type %q (use multichoice, shorttext or numerical)", t.kind)
+	}
+	if t.text, err = getString(root, "text", true); err != nil {
+		return nil, err
+	}
+	if t.figure, err = getString(root, "figure", false); err != nil {
+		return nil, err
+	}
+	if t.figureAlt, err = getString(root, "figure_alt", false); err != nil {
+		return nil, err
+	}
+	if v, ok := root.values["points"]; ok {
+		points, ok := v.(float64)
+		if !ok || points < 0 || points != math.Trunc(points) {
+			return nil, fmt.Errorf("Points must be a non-negative integer")
+		}
+		t.points = uint(points)
+	}
+
+	if v, ok := root.values["params"]; ok {
+		params, ok := v.(*table)
+		if !ok {
+			return nil, fmt.Errorf("Params must be a table")
+		}
+		for _, name := range params.keys {
+			p, err := loadParam(name, params.values[name])
+			if err != nil {
+				return nil, fmt.Errorf("Parameter %q: %w", name, err)
+			}
+			t.params = append(t.params, p)
+		}
+	}
+
+	answers, ok := root.values["answers"].([]*table)
+	if !ok || len(answers) == 0 {
+		return nil, fmt.Errorf("Answers must be given as [[answers]] tables")
+	}
+	for i, v := range answers {
+		a, err := loadAnswer(v)
+		if err != nil {
+			return nil, fmt.Errorf("Answer %d: %w", i+1, err)
+		}
+		t.answers = append(t.answers, a)
+	}
+
+	return t, nil
+}
+
+// loadParam reads a parameter. The value is either a formula or an inline
+// table with the keys min, max, step and zero.
+func loadParam(name string, v any) (param, error) {
+	p := param{name: name, step: 1, allowZero: true}
+	if _, ok := constants[name]; ok {
+		return p, fmt.Errorf("Name is reserved for a constant")
+	}
+
+	switch v := v.(type) {
+	case string:
+		p.formula = v
+		return p, nil
+	case *table:
+		if err := checkKeys(v, "min", "max", "step", "zero"); err != nil {
+			return p, err
+		}
+		var err error
+		if p.min, err = getNumber(v, "min", true, 0); err != nil {
+			return p, err
+		}
+		if p.max, err = getNumber(v, "max", true, 0); err != nil {
+			return p, err
+		}
+		if p.step, err = getNumber(v, "step", false, 1); err != nil {
+			return p, err
+		}
+		if zero, ok := v.values["zero"]; ok {
+			if p.allowZero, ok = zero.(bool); !ok {
+				return p, fmt.Errorf("Zero must be true or false")
+			}
+		}
+
+		if p.step <= 0 || p.min > p.max {
+			return p, fmt.Errorf("Range from %g to %g with step %g is empty", p.min, p.max, p.step)
+		}
+		if !p.allowZero && p.min == 0 && p.max < p.min+p.step {
+			return p, fmt.Errorf("Range only contains zero, which is not allowed")
+		}
+		return p, nil
+	}
+	return p, fmt.Errorf("Expected a formula or a range such as {min = 1, max = 10}")
+}
+
+// loadAnswer reads an answer.
+func loadAnswer(t *table) (answerTemplate, error) {
+	var a answerTemplate
+	if err := checkKeys(t, "text", "formula", "grade", "feedback", "tolerance"); err != nil {
+		return a, err
+	}
+
+	var err error
+	if a.text, err = getString(t, "text", false); err != nil {
+		return a, err
+	}
+	if a.formula, err = getString(t, "formula", false); err != nil {
+		return a, err
+	}
+	if (a.text == "") == (a.formula == "") {
+		return a, fmt.Errorf("Either text or formula must be given")
+	}
+	if a.grade, err = getNumber(t, "grade", true, 0); err != nil {
+		return a, err
+	}
+	if a.feedback, err = getString(t, "feedback", false); err != nil {
+		return a, err
+	}
+
+	// Tolerances may be numbers or formulas
+	switch v := t.values["tolerance"].(type) {
+	case nil:
+	case float64:
+		a.tolerance = strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		a.tolerance = v
+	default:
+		return a, fmt.Errorf("Tolerance must be a number or a formula")
+	}
+	return a, nil
+}
+
+// checkKeys returns an error if t contains other keys than the allowed ones.
+func checkKeys(t *table, allowed ...string) error {
+	for _, k := range t.keys {
+		if !slices.Contains(allowed, k) {
+			return fmt.Errorf("Unknown key %q (allowed keys are %s)", k, strings.Join(allowed, ", "))
+		}
+	}
+	return nil
+}
+
+// getString returns the string stored at key in t.
+func getString(t *table, key string, required bool) (string, error) {
+	v, ok := t.values[key]
+	if !ok {
+		if required {
+			return "", fmt.Errorf("Missing key %q", key)
+		}
+		return "", nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("Key %q must be a string", key)
+	}
+	return s, nil
+}
+
+// getNumber returns the number stored at key in t. If key is not present and
+// not required, def is returned.
+func getNumber(t *table, key string, required bool, def float64) (float64, error) {
+	v, ok := t.values[key]
+	if !ok {
+		if required {
+			return 0, fmt.Errorf("Missing key %q", key)
+		}
+		return def, nil
+	}
+	f, ok := v.(float64)
+	if !ok {
+		return 0, fmt.Errorf("Key %q must be a number", key)
+	}
+	return f, nil
+}
+
+// draw returns random values of the parameters of t.
+func (t *questionTemplate) draw() (map[string]float64, error) {
+	vars := make(map[string]float64, len(t.params))
+	for _, p := range t.params {
+		if p.formula != "" {
+			v, err := evaluate(p.formula, vars)
+			if err != nil {
+				return nil, fmt.Errorf("Parameter %q: %w", p.name, err)
+			}
+			vars[p.name] = v
+			continue
+		}
+
+		n := int(math.Floor((p.max-p.min)/p.step + 1e-9))
+		scale := math.Pow(10, float64(max(decimals(p.min), decimals(p.step))))
+		for {
+			// Round away floating point errors such as 0.30000000000000004 or
+			// 5.551115123125783e-17 (from -0.3+3*0.1)
+			v := math.Round((p.min+float64(unif.IntInInterval(0, n, true))*p.step)*scale) / scale
+			if p.allowZero || v != 0 {
+				vars[p.name] = v
+				break
+			}
+		}
+	}
+	return vars, nil
+}
+
+// decimals returns the number of decimals needed to write v exactly.
+func decimals(v float64) int {
+	s := strconv.FormatFloat(v, 'f', -1, 64)
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		return len(s) - i - 1
+	}
+	return 0
+}
+
+// formatNumber formats v for inclusion in a question.
+func formatNumber(v float64) string {
+	if v == 0 {
+		// Avoid -0
+		return "0"
+	}
+	return strconv.FormatFloat(v, 'g', 10, 64)
+}
+
+// rePlaceholder matches a placeholder {{formula}}. Formulas do not contain
+// braces, so in {{{a}}} only the inner part is a placeholder.
+var rePlaceholder = regexp.MustCompile(`\{\{([^{}]*)\}\}`)
+
+// fill replaces each placeholder {{formula}} in s by the value of the formula.
+func fill(s string, vars map[string]float64) (string, error) {
+	return fillPlaceholders(s, vars, false)
+}
+
+// fillTikz replaces placeholders in the TikZ code s like fill. Braces are
+// common in TikZ, so text such as {{$x$}} that is not a formula in the given
+// parameters is left unchanged.
+func fillTikz(s string, vars map[string]float64) (string, error) {
+	return fillPlaceholders(s, vars, true)
+}
+
+// fillPlaceholders replaces placeholders in s by the values of their formulas.
+// If skipInvalid is set, placeholders which are not valid formulas are left
+// unchanged rather than causing an error.
+func fillPlaceholders(s string, vars map[string]float64, skipInvalid bool) (string, error) {
+	var err error
+	filled := rePlaceholder.ReplaceAllStringFunc(s, func(match string) string {
+		v, evalErr := evaluate(rePlaceholder.FindStringSubmatch(match)[1], vars)
+		if evalErr != nil {
+			var syntaxErr *syntaxError
+			if err == nil && !(skipInvalid && errors.As(evalErr, &syntaxErr)) {
+				err = evalErr
+			}
+			return match
+		}
+		return formatNumber(v)
+	})
+	return filled, err
+}
+
+// instantiate generates a question from t using random values of the
+// parameters.
+func (t *questionTemplate) instantiate() (moodle.Question, error) {
+	for attempt := 0; ; attempt++ {
+		vars, err := t.draw()
+		if err != nil {
+			return nil, err
+		}
+		answers, texts, err := t.fillAnswers(vars)
+		if err != nil {
+			return nil, err
+		}
+
+		if t.kind == "multichoice" && !distinct(texts) {
+			if attempt < maxAttempts {
+				continue
+			}
+			return nil, fmt.Errorf("Failed to draw parameters giving distinct answers in %d attempts", maxAttempts)
+		}
+
+		text, err := fill(t.text, vars)
+		if err != nil {
+			return nil, err
+		}
+
+		var figure *moodle.File
+		if t.figure != "" {
+			if figure, err = t.compileFigure(vars); err != nil {
+				return nil, err
+			}
+			text += figure.Html()
+		}
+
+		var q moodle.Question
+		switch t.kind {
+		case "multichoice":
+			q = moodle.NewMultiChoice(text, t.points, answers)
+		case "shorttext":
+			q = moodle.NewShortText(text, t.points, answers)
+		default:
+			q = moodle.NewNumerical(text, t.points, answers)
+		}
+		if figure != nil {
+			fa, ok := q.(moodle.FileAttacher)
+			if !ok {
+				return nil, fmt.Errorf("Question type %q does not support figures", q.MoodleName())
+			}
+			fa.AddFiles(figure)
+		}
+		return q, nil
+	}
+}
+
+// fillAnswers creates the answers of t using the given parameters. The texts
+// of the answers are returned as well.
+func (t *questionTemplate) fillAnswers(vars map[string]float64) ([]*moodle.Answer, []string, error) {
+	answers := make([]*moodle.Answer, len(t.answers))
+	texts := make([]string, len(t.answers))
+	for i, a := range t.answers {
+		text, err := fill(a.text, vars)
+		if a.formula != "" {
+			var v float64
+			v, err = evaluate(a.formula, vars)
+			text = formatNumber(v)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("Answer %d: %w", i+1, err)
+		}
+		texts[i] = text
+
+		feedback, err := fill(a.feedback, vars)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Feedback of answer %d: %w", i+1, err)
+		}
+		answers[i] = moodle.NewAnswerWithFeedback(text, a.grade, feedback)
+
+		if a.tolerance != "" {
+			tol, err := evaluate(a.tolerance, vars)
+			if err != nil {
+				return nil, nil, fmt.Errorf("Tolerance of answer %d: %w", i+1, err)
+			}
+			answers[i].SetOption("tolerance", formatNumber(tol))
+		}
+	}
+	return answers, texts, nil
+}
+
+// distinct reports whether the given texts are different.
+func distinct(texts []string) bool {
+	seen := make(map[string]bool, len(texts))
+	for _, s := range texts {
+		if seen[s] {
+			return false
+		}
+		seen[s] = true
+	}
+	return true
+}
+
+// compileFigure compiles the TikZ figure of t using the given parameters.
+func (t *questionTemplate) compileFigure(vars map[string]float64) (*moodle.File, error) {
+	tikz, err := fillTikz(t.figure, vars)
+	if err != nil {
+		return nil, fmt.Errorf("Figure: %w", err)
+	}
+	img, err := graphics.SvgFromTikz(tikz, "")
+	if err != nil {
+		return nil, fmt.Errorf("Figure: %w", err)
+	}
+	alt, err := fill(t.figureAlt, vars)
+	if err != nil {
+		return nil, fmt.Errorf("Alt text of figure: %w", err)
+	}
+	img.SetAltDescription(alt)
+	return moodle.NewFile("figure", img)
+}
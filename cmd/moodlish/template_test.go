@@ -0,0 +1,172 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const numericalTemplate = `type = "numerical"
+text = 'Compute \(\frac{ {{a}} }{ {{b}} }\).'
+
+[params]
+a = {min = 1, max = 20}
+b = {min = -1, max = 1, zero = false}
+q = "a/b"
+
+[[answers]]
+formula = "q"
+tolerance = "abs(q)/100"
+grade = 100
+feedback = "The answer is {{q}}"
+`
+
+func TestLoadTemplate(t *testing.T) {
+	tmpl, err := loadTemplate(numericalTemplate)
+	if err != nil {
+		t.Fatalf("Loading template caused error: %s", err)
+	}
+	if tmpl.kind != "numerical" || tmpl.points != 1 || len(tmpl.params) != 3 || len(tmpl.answers) != 1 {
+		t.Errorf("Unexpected template %+v", tmpl)
+	}
+
+	testCases := []struct {
+		s   string
+		msg string
+	}{
+		{`type = "essay"`, "Unsupported question type"},
+		{"type = \"numerical\"\ntext = \"x\"\ncolour = 1", `Unknown key "colour"`},
+		{"type = \"numerical\"\ntext = \"x\"", "Answers must be given"},
+		{"type = \"numerical\"\ntext = \"x\"\n[[answers]]\ngrade = 100", "Answer 1: Either text or formula"},
+		{"type = \"numerical\"\ntext = \"x\"\n[params]\na = {min = 0, max = 0, zero = false}", `Parameter "a": Range only contains zero`},
+		{"type = \"numerical\"\ntext = \"x\"\n[params]\npi = \"1\"", `Parameter "pi": Name is reserved`},
+	}
+	for _, v := range testCases {
+		if _, err := loadTemplate(v.s); err == nil || !strings.Contains(err.Error(), v.msg) {
+			t.Errorf("Loading %q gave error %v, but expected %q", v.s, err, v.msg)
+		}
+	}
+}
+
+func TestInstantiate(t *testing.T) {
+	tmpl, err := loadTemplate(numericalTemplate)
+	if err != nil {
+		t.Fatalf("Loading template caused error: %s", err)
+	}
+	for i := 0; i < 20; i++ {
+		vars, err := tmpl.draw()
+		if err != nil {
+			t.Fatalf("Drawing parameters caused error: %s", err)
+		}
+		if a := vars["a"]; a < 1 || a > 20 || a != float64(int(a)) {
+			t.Errorf("Parameter a=%g is outside the range", a)
+		}
+		if b := vars["b"]; b != 1 && b != -1 {
+			t.Errorf("Parameter b=%g is outside the range", b)
+		}
+		if vars["q"] != vars["a"]/vars["b"] {
+			t.Errorf("Derived parameter was not computed: %v", vars)
+		}
+	}
+
+	// Fractional steps crossing zero give exact multiples of the step
+	tmpl, err = loadTemplate("type = \"numerical\"\ntext = \"x\"\n[params]\na = {min = -0.3, max = 0.3, step = 0.1, zero = false}\n[[answers]]\nformula = \"a\"\ngrade = 100")
+	if err != nil {
+		t.Fatalf("Loading template caused error: %s", err)
+	}
+	seen := make(map[float64]bool)
+	for i := 0; i < 200; i++ {
+		vars, err := tmpl.draw()
+		if err != nil {
+			t.Fatalf("Drawing parameters caused error: %s", err)
+		}
+		a := vars["a"]
+		if a == 0 || a != math.Round(a*10)/10 {
+			t.Errorf("Parameter a=%g is not a nonzero multiple of the step", a)
+		}
+		seen[a] = true
+	}
+	if len(seen) != 6 {
+		t.Errorf("Expected 6 different values, but got %v", seen)
+	}
+
+	// The answers 0 and {{a-a}} always coincide
+	tmpl, err = loadTemplate(`type = "multichoice"
+text = "Pick {{a}}"
+[params]
+a = {min = 1, max = 3}
+[[answers]]
+formula = "0"
+grade = 100
+[[answers]]
+text = "{{a-a}}"
+grade = 0
+`)
+	if err != nil {
+		t.Fatalf("Loading template caused error: %s", err)
+	}
+	if _, err := tmpl.instantiate(); err == nil || !strings.Contains(err.Error(), "distinct answers") {
+		t.Errorf("Coinciding answers gave error %v", err)
+	}
+}
+
+func TestFill(t *testing.T) {
+	vars := map[string]float64{"a": 0.1, "b": 0.2}
+	if s, err := fill("{{a+b}} and {{ -a*0 }} and {b}", vars); err != nil || s != "0.3 and 0 and {b}" {
+		t.Errorf("Filling placeholders gave %q and error %v", s, err)
+	}
+	if _, err := fill("{{c}}", vars); err == nil {
+		t.Errorf("Unknown parameter failed to return an error")
+	}
+	if s, err := fill("\\frac{{{a}}}{2}", vars); err != nil || s != "\\frac{0.1}{2}" {
+		t.Errorf("Filling nested braces gave %q and error %v", s, err)
+	}
+
+	// TikZ code may contain double braces which are not placeholders
+	tikz := `\node[fill={{red!20}}] at ({{a}}, {{2*b}}) {{$x$}}; \node {{label}};`
+	s, err := fillTikz(tikz, vars)
+	if err != nil {
+		t.Fatalf("Filling TikZ code caused error: %s", err)
+	}
+	if want := `\node[fill={{red!20}}] at (0.1, 0.4) {{$x$}}; \node {{label}};`; s != want {
+		t.Errorf("Filling TikZ code gave %q, but expected %q", s, want)
+	}
+	if _, err := fillTikz("{{a/0}}", vars); err == nil {
+		t.Errorf("Division by zero in TikZ code failed to return an error")
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	tmplName := filepath.Join(dir, "fractions.toml")
+	if err := os.WriteFile(tmplName, []byte(numericalTemplate), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var outputs []string
+	for i, name := range []string{"a.xml", "b.xml"} {
+		outName := filepath.Join(dir, name)
+		if err := run(tmplName, outName, "Fractions", 5, 42); err != nil {
+			t.Fatalf("Run %d caused error: %s", i, err)
+		}
+		content, err := os.ReadFile(outName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs = append(outputs, string(content))
+	}
+	if outputs[0] != outputs[1] {
+		t.Errorf("Same seed gave different question banks")
+	}
+	if !strings.Contains(outputs[0], "$module$/Fractions") || strings.Count(outputs[0], `<question type="numerical">`) != 5 {
+		t.Errorf("Unexpected question bank:\n%s", outputs[0])
+	}
+
+	if err := run(tmplName, "", "", 1, 0); err != nil {
+		t.Errorf("Default output name caused error: %s", err)
+	} else if _, err := os.Stat(filepath.Join(dir, "fractions.xml")); err != nil {
+		t.Errorf("Output was not written next to the template: %s", err)
+	}
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// table is a TOML table. The keys are kept in the order of the file, since
+// parameters may depend on the ones defined before them.
+type table struct {
+	keys   []string
+	values map[string]any
+}
+
+func newTable() *table {
+	return &table{values: make(map[string]any)}
+}
+
+// set adds key to t. An error is returned if key is already defined.
+func (t *table) set(key string, value any) error {
+	if _, ok := t.values[key]; ok {
+		return fmt.Errorf("Key %q is defined twice", key)
+	}
+	t.keys = append(t.keys, key)
+	t.values[key] = value
+	return nil
+}
+
+// tomlParser reads the subset of TOML used by question templates. This
+// includes comments, tables, arrays of tables, basic and literal strings
+// (including multi-line strings), numbers, booleans, arrays and inline tables.
+// Dotted keys and dates are not supported.
+//
+// All numbers are parsed as float64.
+type tomlParser struct {
+	s    string
+	pos  int
+	line int
+}
+
+// parseToml parses the TOML document s.
+func parseToml(s string) (*table, error) {
+	p := &tomlParser{s: s, line: 1}
+	root, err := p.document()
+	if err != nil {
+		return nil, fmt.Errorf("Line %d: %w", p.line, err)
+	}
+	return root, nil
+}
+
+// document parses the entire input.
+func (p *tomlParser) document() (*table, error) {
+	root := newTable()
+	current := root
+	for {
+		p.skipSpace(true)
+		if p.pos >= len(p.s) {
+			return root, nil
+		}
+
+		if p.s[p.pos] == '[' {
+			t, err := p.header(root)
+			if err != nil {
+				return nil, err
+			}
+			current = t
+		} else {
+			key, err := p.key()
+			if err != nil {
+				return nil, err
+			}
+			p.skipSpace(false)
+			if !p.consume("=") {
+				return nil, fmt.Errorf("Expected = after key %q", key)
+			}
+			p.skipSpace(false)
+			value, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			if err := current.set(key, value); err != nil {
+				return nil, err
+			}
+		}
+
+		// Only a comment may follow on the same line
+		p.skipSpace(false)
+		if p.pos < len(p.s) && p.s[p.pos] != '\n' {
+			return nil, fmt.Errorf("Unexpected %q at end of line", p.rest())
+		}
+	}
+}
+
+// header parses a table header [name] or an array of tables header [[name]]
+// and returns the table in which the following keys are defined.
+func (p *tomlParser) header(root *table) (*table, error) {
+	array := p.consume("[[")
+	if !array {
+		p.consume("[")
+	}
+	p.skipSpace(false)
+	name, err := p.key()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace(false)
+	if (array && !p.consume("]]")) || (!array && !p.consume("]")) {
+		return nil, fmt.Errorf("Table header %q is not closed (dotted keys are not supported)", name)
+	}
+
+	t := newTable()
+	if !array {
+		return t, root.set(name, t)
+	}
+
+	existing, ok := root.values[name]
+	if !ok {
+		return t, root.set(name, []*table{t})
+	}
+	tables, ok := existing.([]*table)
+	if !ok {
+		return nil, fmt.Errorf("Key %q is not an array of tables", name)
+	}
+	root.values[name] = append(tables, t)
+	return t, nil
+}
+
+// key parses a bare or quoted key.
+func (p *tomlParser) key() (string, error) {
+	if p.pos < len(p.s) && (p.s[p.pos] == '"' || p.s[p.pos] == '\'') {
+		return p.str()
+	}
+	start := p.pos
+	for p.pos < len(p.s) && isBareKeyChar(p.s[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		return "", fmt.Errorf("Expected key, but found %q", p.rest())
+	}
+	return p.s[start:p.pos], nil
+}
+
+func isBareKeyChar(c byte) bool {
+	return c == '_' || c == '-' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+// value parses a value of any supported type.
+func (p *tomlParser) value() (any, error) {
+	if p.pos >= len(p.s) {
+		return nil, fmt.Errorf("Expected value at end of file")
+	}
+
+	switch c := p.s[p.pos]; {
+	case c == '"' || c == '\'':
+		return p.str()
+	case c == '[':
+		return p.array()
+	case c == '{':
+		return p.inlineTable()
+	case strings.HasPrefix(p.s[p.pos:], "true"):
+		p.pos += 4
+		return true, nil
+	case strings.HasPrefix(p.s[p.pos:], "false"):
+		p.pos += 5
+		return false, nil
+	}
+	return p.number()
+}
+
+// number parses an integer or a float.
+func (p *tomlParser) number() (float64, error) {
+	start := p.pos
+	for p.pos < len(p.s) && strings.IndexByte("+-0123456789._eE", p.s[p.pos]) >= 0 {
+		p.pos++
+	}
+	s := strings.ReplaceAll(p.s[start:p.pos], "_", "")
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || s == "" {
+		p.pos = start
+		return 0, fmt.Errorf("Invalid value %q", p.rest())
+	}
+	return v, nil
+}
+
+// array parses an array, which may span several lines.
+func (p *tomlParser) array() ([]any, error) {
+	p.consume("[")
+	var values []any
+	for {
+		p.skipSpace(true)
+		if p.consume("]") {
+			return values, nil
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+
+		p.skipSpace(true)
+		if !p.consume(",") {
+			p.skipSpace(true)
+			if !p.consume("]") {
+				return nil, fmt.Errorf("Expected , or ] in array, but found %q", p.rest())
+			}
+			return values, nil
+		}
+	}
+}
+
+// inlineTable parses an inline table, which must be on a single line.
+func (p *tomlParser) inlineTable() (*table, error) {
+	p.consume("{")
+	t := newTable()
+	p.skipSpace(false)
+	if p.consume("}") {
+		return t, nil
+	}
+	for {
+		p.skipSpace(false)
+		key, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace(false)
+		if !p.consume("=") {
+			return nil, fmt.Errorf("Expected = after key %q", key)
+		}
+		p.skipSpace(false)
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		if err := t.set(key, v); err != nil {
+			return nil, err
+		}
+
+		p.skipSpace(false)
+		if p.consume("}") {
+			return t, nil
+		}
+		if !p.consume(",") {
+			return nil, fmt.Errorf("Expected , or } in inline table, but found %q", p.rest())
+		}
+	}
+}
+
+// str parses a basic or literal string, which may be a multi-line string.
+func (p *tomlParser) str() (string, error) {
+	quote := p.s[p.pos : p.pos+1]
+	multiline := strings.HasPrefix(p.s[p.pos:], strings.Repeat(quote, 3))
+	if multiline {
+		quote = strings.Repeat(quote, 3)
+	}
+	p.pos += len(quote)
+	if multiline {
+		// A newline immediately after the opening quotes is trimmed
+		if !p.consume("\r\n") {
+			p.consume("\n")
+		}
+	}
+
+	var b strings.Builder
+	for {
+		if p.pos >= len(p.s) {
+			return "", fmt.Errorf("String is not terminated by %s", quote)
+		}
+		if strings.HasPrefix(p.s[p.pos:], quote) {
+			p.pos += len(quote)
+			return b.String(), nil
+		}
+
+		c := p.s[p.pos]
+		switch {
+		case c == '\n' && !multiline:
+			return "", fmt.Errorf("String is not terminated by %s", quote)
+		case c == '\n':
+			p.line++
+		case c == '\\' && quote[0] == '"':
+			r, err := p.escape()
+			if err != nil {
+				return "", err
+			}
+			b.WriteRune(r)
+			continue
+		}
+		b.WriteByte(c)
+		p.pos++
+	}
+}
+
+// escape parses an escape sequence in a basic string.
+func (p *tomlParser) escape() (rune, error) {
+	if p.pos+1 >= len(p.s) {
+		return 0, fmt.Errorf("Incomplete escape sequence")
+	}
+	c := p.s[p.pos+1]
+	p.pos += 2
+	switch c {
+	case 'n':
+		return '\n', nil
+	case 't':
+		return '\t', nil
+	case 'r':
+		return '\r', nil
+	case '"':
+		return '"', nil
+	case '\\':
+		return '\\', nil
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+		if p.pos+n > len(p.s) {
+			return 0, fmt.Errorf("Incomplete escape sequence")
+		}
+		v, err := strconv.ParseUint(p.s[p.pos:p.pos+n], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(v)) {
+			return 0, fmt.Errorf("Invalid escape sequence \\%c%s", c, p.s[p.pos:p.pos+n])
+		}
+		p.pos += n
+		return rune(v), nil
+	}
+	return 0, fmt.Errorf("Invalid escape sequence \\%c (use a literal string '...' for LaTeX code)", c)
+}
+
+// skipSpace skips whitespace and comments. Newlines are only skipped if
+// newlines is set.
+func (p *tomlParser) skipSpace(newlines bool) {
+	for p.pos < len(p.s) {
+		switch c := p.s[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\r':
+			p.pos++
+		case c == '\n' && newlines:
+			p.pos++
+			p.line++
+		case c == '#':
+			for p.pos < len(p.s) && p.s[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// consume skips s if the input continues with s, and reports whether it did.
+func (p *tomlParser) consume(s string) bool {
+	if strings.HasPrefix(p.s[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+// rest returns the remainder of the current line.
+func (p *tomlParser) rest() string {
+	s := p.s[p.pos:]
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseToml(t *testing.T) {
+	input := `# A comment
+type = "numerical" # Trailing comment
+points = 2
+text = 'Literal \(x\)'
+escaped = "Tab\tand \u00e6"
+multi = """
+Line 1
+Line 2"""
+list = [1, 2.5,
+	-3e2, ]
+
+[params]
+a = {min = 1, max = 1_000, zero = false}
+
+[[answers]]
+grade = 100
+
+[[answers]]
+grade = 0
+`
+	root, err := parseToml(input)
+	if err != nil {
+		t.Fatalf("Parsing caused error: %s", err)
+	}
+	if strings.Join(root.keys, ",") != "type,points,text,escaped,multi,list,params,answers" {
+		t.Errorf("Unexpected keys %q", root.keys)
+	}
+	for key, want := range map[string]any{
+		"type":    "numerical",
+		"points":  2.0,
+		"text":    `Literal \(x\)`,
+		"escaped": "Tab\tand æ",
+		"multi":   "Line 1\nLine 2",
+	} {
+		if root.values[key] != want {
+			t.Errorf("Key %q has value %#v, but expected %#v", key, root.values[key], want)
+		}
+	}
+	if list, ok := root.values["list"].([]any); !ok || len(list) != 3 || list[2] != -300.0 {
+		t.Errorf("Unexpected array %#v", root.values["list"])
+	}
+	a := root.values["params"].(*table).values["a"].(*table)
+	if a.values["max"] != 1000.0 || a.values["zero"] != false {
+		t.Errorf("Unexpected inline table %#v", a.values)
+	}
+	if answers, ok := root.values["answers"].([]*table); !ok || len(answers) != 2 || answers[1].values["grade"] != 0.0 {
+		t.Errorf("Unexpected array of tables %#v", root.values["answers"])
+	}
+
+	testCases := []struct {
+		s   string
+		msg string
+	}{
+		{"a = 1\na = 2", "Line 2: Key \"a\" is defined twice"},
+		{"a = \"\\frac\"", "Line 1: Invalid escape sequence"},
+		{"a = 'open", "Line 1: String is not terminated"},
+		{"\n\na = 1 b", "Line 3: Unexpected"},
+		{"[a.b]", "dotted keys are not supported"},
+		{"a = nope", "Line 1: Invalid value"},
+	}
+	for _, v := range testCases {
+		if _, err := parseToml(v.s); err == nil || !strings.Contains(err.Error(), v.msg) {
+			t.Errorf("Parsing %q gave error %v, but expected %q", v.s, err, v.msg)
+		}
+	}
+}
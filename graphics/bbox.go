@@ -0,0 +1,447 @@
+package graphics
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// svgNode is a generic element in an SVG document.
+type svgNode struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Children []*svgNode `xml:",any"`
+}
+
+// parseSvgTree parses the elements of an SVG document into a tree.
+func parseSvgTree(content []byte) (*svgNode, error) {
+	root := new(svgNode)
+	if err := xml.NewDecoder(bytes.NewReader(content)).Decode(root); err != nil {
+		return nil, fmt.Errorf("Failed to parse svg: %v", err)
+	}
+	if root.XMLName.Local != "svg" {
+		return nil, fmt.Errorf("Root element is %q, not \"svg\"", root.XMLName.Local)
+	}
+	return root, nil
+}
+
+// attr returns the value of the attribute name. Presentation attributes may
+// also be given in the style attribute.
+func (n *svgNode) attr(name string) (string, bool) {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return strings.TrimSpace(a.Value), true
+		}
+	}
+
+	for _, a := range n.Attrs {
+		if a.Name.Local != "style" {
+			continue
+		}
+		for _, decl := range strings.Split(a.Value, ";") {
+			k, v, ok := strings.Cut(decl, ":")
+			if ok && strings.TrimSpace(k) == name {
+				return strings.TrimSpace(v), true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// number returns the numerical value of the attribute name, or def if the
+// attribute is not set. Units are ignored.
+func (n *svgNode) number(name string, def float64) float64 {
+	s, ok := n.attr(name)
+	if !ok {
+		return def
+	}
+	match := reNumber.FindString(s)
+	if match == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(match, 64)
+	if err != nil {
+		return def
+	}
+	return f
+}
+
+// ids maps the id attribute of every element in the tree to the element.
+func (n *svgNode) ids(m map[string]*svgNode) map[string]*svgNode {
+	if m == nil {
+		m = make(map[string]*svgNode)
+	}
+	if id, ok := n.attr("id"); ok {
+		m[id] = n
+	}
+	for _, c := range n.Children {
+		c.ids(m)
+	}
+	return m
+}
+
+// matrix is an affine transformation given by the values (a, b, c, d, e, f) as
+// in the SVG specification.
+type matrix [6]float64
+
+var identity = matrix{1, 0, 0, 1, 0, 0}
+
+// mul returns the transformation obtained by first applying n and then m.
+func (m matrix) mul(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[2]*n[1],
+		m[1]*n[0] + m[3]*n[1],
+		m[0]*n[2] + m[2]*n[3],
+		m[1]*n[2] + m[3]*n[3],
+		m[0]*n[4] + m[2]*n[5] + m[4],
+		m[1]*n[4] + m[3]*n[5] + m[5],
+	}
+}
+
+// apply transforms the point (x, y).
+func (m matrix) apply(x, y float64) (float64, float64) {
+	return m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]
+}
+
+var (
+	reNumber    = regexp.MustCompile(`[+-]?(?:[0-9]+\.?[0-9]*|\.[0-9]+)(?:[eE][+-]?[0-9]+)?`)
+	reTransform = regexp.MustCompile(`([a-zA-Z]+)\s*\(([^)]*)\)`)
+	rePathToken = regexp.MustCompile(`[MmLlHhVvCcSsQqTtAaZz]|` + reNumber.String())
+)
+
+// parseNumbers extracts all numbers from s.
+func parseNumbers(s string) []float64 {
+	matches := reNumber.FindAllString(s, -1)
+	nums := make([]float64, 0, len(matches))
+	for _, v := range matches {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			nums = append(nums, f)
+		}
+	}
+	return nums
+}
+
+// parseTransform converts an SVG transform attribute into a matrix.
+func parseTransform(s string) (matrix, error) {
+	m := identity
+	for _, match := range reTransform.FindAllStringSubmatch(s, -1) {
+		args := parseNumbers(match[2])
+		arg := func(i int, def float64) float64 {
+			if i < len(args) {
+				return args[i]
+			}
+			return def
+		}
+
+		var t matrix
+		switch match[1] {
+		case "matrix":
+			if len(args) != 6 {
+				return identity, fmt.Errorf("Transform %q needs 6 arguments", match[0])
+			}
+			copy(t[:], args)
+		case "translate":
+			t = matrix{1, 0, 0, 1, arg(0, 0), arg(1, 0)}
+		case "scale":
+			t = matrix{arg(0, 1), 0, 0, arg(1, arg(0, 1)), 0, 0}
+		case "rotate":
+			a := arg(0, 0) * math.Pi / 180
+			cx, cy := arg(1, 0), arg(2, 0)
+			t = matrix{1, 0, 0, 1, cx, cy}.
+				mul(matrix{math.Cos(a), math.Sin(a), -math.Sin(a), math.Cos(a), 0, 0}).
+				mul(matrix{1, 0, 0, 1, -cx, -cy})
+		case "skewX":
+			t = matrix{1, 0, math.Tan(arg(0, 0) * math.Pi / 180), 1, 0, 0}
+		case "skewY":
+			t = matrix{1, math.Tan(arg(0, 0) * math.Pi / 180), 0, 1, 0, 0}
+		default:
+			return identity, fmt.Errorf("Unknown transform %q", match[1])
+		}
+		m = m.mul(t)
+	}
+	return m, nil
+}
+
+// bbox is an axis-aligned bounding box.
+type bbox struct {
+	minX, minY, maxX, maxY float64
+	nonEmpty               bool
+}
+
+// add extends b to contain the point (x, y).
+func (b *bbox) add(x, y float64) {
+	if !b.nonEmpty {
+		*b = bbox{x, y, x, y, true}
+		return
+	}
+	b.minX, b.maxX = min(b.minX, x), max(b.maxX, x)
+	b.minY, b.maxY = min(b.minY, y), max(b.maxY, y)
+}
+
+// union extends b to contain o.
+func (b *bbox) union(o bbox) {
+	if o.nonEmpty {
+		b.add(o.minX, o.minY)
+		b.add(o.maxX, o.maxY)
+	}
+}
+
+// width returns the width of b.
+func (b bbox) width() float64 {
+	return b.maxX - b.minX
+}
+
+// height returns the height of b.
+func (b bbox) height() float64 {
+	return b.maxY - b.minY
+}
+
+// nonRendered lists the elements whose children are only drawn when referenced.
+var nonRendered = map[string]bool{
+	"defs":     true,
+	"clipPath": true,
+	"mask":     true,
+	"symbol":   true,
+	"pattern":  true,
+	"marker":   true,
+	"title":    true,
+	"desc":     true,
+	"metadata": true,
+}
+
+// boundsContext contains the state inherited by child elements when computing
+// bounds.
+type boundsContext struct {
+	ids         map[string]*svgNode
+	transform   matrix
+	stroke      bool
+	strokeWidth float64
+	depth       int
+}
+
+// contentBounds computes the bounding box of the visible content of an SVG
+// document. The bounding box is given in the user coordinates of the root
+// element. Curves are bounded by their control points, so the result may be
+// slightly larger than the exact bounding box.
+func contentBounds(root *svgNode) (bbox, error) {
+	ctx := boundsContext{
+		ids:         root.ids(nil),
+		transform:   identity,
+		strokeWidth: 1,
+	}
+
+	return root.childBounds(ctx)
+}
+
+// bounds computes the bounding box of n and its children.
+func (n *svgNode) bounds(ctx boundsContext) (bbox, error) {
+	if nonRendered[n.XMLName.Local] {
+		return bbox{}, nil
+	}
+	if v, _ := n.attr("display"); v == "none" {
+		return bbox{}, nil
+	}
+	if ctx.depth > 64 {
+		return bbox{}, fmt.Errorf("Elements are nested too deeply (possibly a recursive <use>)")
+	}
+	ctx.depth++
+
+	if s, ok := n.attr("transform"); ok {
+		t, err := parseTransform(s)
+		if err != nil {
+			return bbox{}, err
+		}
+		ctx.transform = ctx.transform.mul(t)
+	}
+	if s, ok := n.attr("stroke"); ok {
+		ctx.stroke = s != "none"
+	}
+	ctx.strokeWidth = n.number("stroke-width", ctx.strokeWidth)
+
+	var points [][2]float64
+	switch n.XMLName.Local {
+	case "path":
+		d, _ := n.attr("d")
+		var err error
+		if points, err = pathPoints(d); err != nil {
+			return bbox{}, err
+		}
+	case "rect", "image":
+		x, y := n.number("x", 0), n.number("y", 0)
+		w, h := n.number("width", 0), n.number("height", 0)
+		points = [][2]float64{{x, y}, {x + w, y + h}}
+	case "circle":
+		cx, cy, r := n.number("cx", 0), n.number("cy", 0), n.number("r", 0)
+		points = [][2]float64{{cx - r, cy - r}, {cx + r, cy + r}}
+	case "ellipse":
+		cx, cy := n.number("cx", 0), n.number("cy", 0)
+		rx, ry := n.number("rx", 0), n.number("ry", 0)
+		points = [][2]float64{{cx - rx, cy - ry}, {cx + rx, cy + ry}}
+	case "line":
+		points = [][2]float64{
+			{n.number("x1", 0), n.number("y1", 0)},
+			{n.number("x2", 0), n.number("y2", 0)},
+		}
+	case "polyline", "polygon":
+		s, _ := n.attr("points")
+		nums := parseNumbers(s)
+		for i := 0; i+1 < len(nums); i += 2 {
+			points = append(points, [2]float64{nums[i], nums[i+1]})
+		}
+	case "use":
+		href, ok := n.attr("href")
+		ref := ctx.ids[strings.TrimPrefix(href, "#")]
+		if !ok || ref == nil {
+			return bbox{}, nil
+		}
+		ctx.transform = ctx.transform.mul(matrix{1, 0, 0, 1, n.number("x", 0), n.number("y", 0)})
+		if ref.XMLName.Local == "symbol" {
+			return ref.childBounds(ctx)
+		}
+		return ref.bounds(ctx)
+	default:
+		return n.childBounds(ctx)
+	}
+
+	// Compute the box in local coordinates, including strokes
+	var local bbox
+	for _, p := range points {
+		local.add(p[0], p[1])
+	}
+	if !local.nonEmpty {
+		return bbox{}, nil
+	}
+	if ctx.stroke && n.XMLName.Local != "image" {
+		hw := ctx.strokeWidth / 2
+		local.minX, local.minY = local.minX-hw, local.minY-hw
+		local.maxX, local.maxY = local.maxX+hw, local.maxY+hw
+	}
+
+	var b bbox
+	for _, p := range [][2]float64{
+		{local.minX, local.minY},
+		{local.minX, local.maxY},
+		{local.maxX, local.minY},
+		{local.maxX, local.maxY},
+	} {
+		b.add(ctx.transform.apply(p[0], p[1]))
+	}
+	return b, nil
+}
+
+// childBounds computes the union of the bounding boxes of the children of n.
+// It is also used for referenced symbols, which are otherwise not rendered.
+func (n *svgNode) childBounds(ctx boundsContext) (bbox, error) {
+	var b bbox
+	for _, c := range n.Children {
+		cb, err := c.bounds(ctx)
+		if err != nil {
+			return bbox{}, err
+		}
+		b.union(cb)
+	}
+	return b, nil
+}
+
+// pathPoints returns the end and control points of the path given by d. The
+// bounding box of these points contains the path.
+func pathPoints(d string) ([][2]float64, error) {
+	tokens := rePathToken.FindAllString(d, -1)
+
+	var (
+		points         [][2]float64
+		cmd            byte
+		x, y           float64
+		startX, startY float64
+	)
+	i := 0
+	next := func(n int) ([]float64, error) {
+		if i+n > len(tokens) {
+			return nil, fmt.Errorf("Path data ended unexpectedly in %q", d)
+		}
+		nums := make([]float64, n)
+		for j := range nums {
+			f, err := strconv.ParseFloat(tokens[i+j], 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid path data in %q", d)
+			}
+			nums[j] = f
+		}
+		i += n
+		return nums, nil
+	}
+
+	for i < len(tokens) {
+		if t := tokens[i]; len(t) == 1 && strings.ContainsAny(t, "MmLlHhVvCcSsQqTtAaZz") {
+			cmd = t[0]
+			i++
+		} else if cmd == 0 {
+			return nil, fmt.Errorf("Path data must begin with a command in %q", d)
+		}
+
+		relative := cmd >= 'a'
+		ox, oy := 0.0, 0.0
+		if relative {
+			ox, oy = x, y
+		}
+
+		var n int
+		switch cmd {
+		case 'Z', 'z':
+			x, y = startX, startY
+			cmd = 0
+			continue
+		case 'H', 'h', 'V', 'v':
+			n = 1
+		case 'M', 'm', 'L', 'l', 'T', 't':
+			n = 2
+		case 'S', 's', 'Q', 'q':
+			n = 4
+		case 'C', 'c':
+			n = 6
+		case 'A', 'a':
+			n = 7
+		}
+
+		nums, err := next(n)
+		if err != nil {
+			return nil, err
+		}
+
+		switch cmd {
+		case 'H', 'h':
+			x = ox + nums[0]
+		case 'V', 'v':
+			y = oy + nums[0]
+		case 'A', 'a':
+			// Bound the arc by a box of radius max(rx, ry) around both endpoints
+			r := max(math.Abs(nums[0]), math.Abs(nums[1]))
+			points = append(points, [2]float64{x - r, y - r}, [2]float64{x + r, y + r})
+			x, y = ox+nums[5], oy+nums[6]
+			points = append(points, [2]float64{x - r, y - r}, [2]float64{x + r, y + r})
+			continue
+		default:
+			for j := 0; j < n-2; j += 2 {
+				points = append(points, [2]float64{ox + nums[j], oy + nums[j+1]})
+			}
+			x, y = ox+nums[n-2], oy+nums[n-1]
+		}
+		points = append(points, [2]float64{x, y})
+
+		switch cmd {
+		case 'M':
+			startX, startY = x, y
+			cmd = 'L'
+		case 'm':
+			startX, startY = x, y
+			cmd = 'l'
+		}
+	}
+
+	return points, nil
+}
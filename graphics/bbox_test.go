@@ -0,0 +1,100 @@
+package graphics
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+const exampleCairoSvg = `<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="200pt" height="100pt" viewBox="0 0 200 100" version="1.1">
+<defs>
+<g>
+<symbol overflow="visible" id="glyph0-0">
+<path style="stroke:none;" d="M 0 0 L 5 0 L 5 -7 L 0 -7 Z "/>
+</symbol>
+</g>
+<clipPath id="clip1">
+  <path d="M 0 0 L 200 0 L 200 100 L 0 100 Z "/>
+</clipPath>
+</defs>
+<g id="surface1">
+<path style="fill:none;stroke-width:2;stroke:rgb(0%,0%,0%);" d="M 20 30 L 60 30 L 60 50 " transform="matrix(1,0,0,1,10,0)"/>
+<g style="fill:rgb(0%,0%,0%);fill-opacity:1;">
+  <use xlink:href="#glyph0-0" x="100" y="80"/>
+</g>
+</g>
+</svg>
+`
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestParseTransform(t *testing.T) {
+	testCases := []struct {
+		transform string
+		in, out   [2]float64
+	}{
+		{"translate(10,5) scale(2)", [2]float64{1, 1}, [2]float64{12, 7}},
+		{"rotate(90)", [2]float64{1, 0}, [2]float64{0, 1}},
+		{"rotate(180, 1, 1)", [2]float64{2, 1}, [2]float64{0, 1}},
+		{"matrix(1 0 0 -1 0 10)", [2]float64{3, 4}, [2]float64{3, 6}},
+	}
+
+	for _, v := range testCases {
+		m, err := parseTransform(v.transform)
+		if err != nil {
+			t.Errorf("Parsing %q caused error: %s", v.transform, err)
+			continue
+		}
+		x, y := m.apply(v.in[0], v.in[1])
+		if !approxEqual(x, v.out[0]) || !approxEqual(y, v.out[1]) {
+			t.Errorf("%q mapped %v to (%f, %f), but expected %v", v.transform, v.in, x, y, v.out)
+		}
+	}
+
+	if _, err := parseTransform("perspective(2)"); err == nil {
+		t.Errorf("Unknown transform failed to return an error")
+	}
+}
+
+func TestPathPoints(t *testing.T) {
+	points, err := pathPoints("m 10 10 l 5 0 0 5 h -10 z M 1,2 C 3,4 5,6 7,8")
+	if err != nil {
+		t.Fatalf("Parsing path caused error: %s", err)
+	}
+
+	var b bbox
+	for _, p := range points {
+		b.add(p[0], p[1])
+	}
+	if b.minX != 1 || b.minY != 2 || b.maxX != 15 || b.maxY != 15 {
+		t.Errorf("Unexpected path bounds %+v", b)
+	}
+
+	if _, err := pathPoints("M 1 2 L 3"); err == nil {
+		t.Errorf("Incomplete path failed to return an error")
+	}
+}
+
+func TestCropNative(t *testing.T) {
+	img, err := SvgFromBytes([]byte(exampleCairoSvg))
+	if err != nil {
+		t.Fatalf("Reading svg caused error: %s", err)
+	}
+
+	if err := img.CropToContent(); err != nil {
+		t.Fatalf("Cropping caused error: %s", err)
+	}
+
+	// The stroked path spans (29,29)-(71,51), and the glyph (100,73)-(105,80)
+	if img.dim != [2]float64{76, 51} {
+		t.Errorf("Expected dimensions [76 51], but got %v", img.dim)
+	}
+	for _, v := range []string{`viewBox="29 29 76 51"`, `width="76pt"`, `height="51pt"`} {
+		if !strings.Contains(string(img.content), v) {
+			t.Errorf("Cropped svg does not contain %s", v)
+		}
+	}
+}
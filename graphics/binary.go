@@ -3,31 +3,55 @@ package graphics
 import (
 	"encoding/base64"
 	"fmt"
+	"html"
 	"io"
 	"net/http"
 	"path/filepath"
-	"strings"
 )
 
 type BinaryImage struct {
+	description
 	content   []byte
 	extension string
-	alt       string
+	dim       [2]float64
 }
 
 var _ Image = (*BinaryImage)(nil) // Ensure that interface is satisfied
 
 // ImageFromFile reads an image file into memory. An error is returned if
 // filePath does not exist.
+//
+// The file contents are checked as described for ImageFromBytes, using the file
+// extension as the expected file type. Svg files should be read using
+// SvgFromFile instead, and an error is returned if the file contains an svg.
+// To read either kind of image, use OpenImage.
 func ImageFromFile(path string) (*BinaryImage, error) {
+	ext := normalizeFiletype(filepath.Ext(path))
+
 	content, err := fileAsBytes(path)
+	if err != nil {
+		return &BinaryImage{extension: ext}, err
+	}
+
+	img, err := ImageFromBytes(content, ext)
+	if img.extension == "svg" {
+		return img, fmt.Errorf("File %q contains an svg. Use SvgFromFile instead", path)
+	}
+	return img, err
+}
 
-	ext := filepath.Ext(path)
+// OpenImage reads an image file into memory. Depending on the file contents,
+// the result is either an *SvgImage or a *BinaryImage.
+func OpenImage(path string) (Image, error) {
+	content, err := fileAsBytes(path)
+	if err != nil {
+		return nil, err
+	}
 
-	return &BinaryImage{
-		content:   content,
-		extension: strings.TrimPrefix(ext, "."),
-	}, err
+	if sniffImageType(content) == "svg" {
+		return SvgFromBytes(content)
+	}
+	return ImageFromBytes(content, filepath.Ext(path))
 }
 
 // ImageFromBytes creates an image object directly from given byte slice.
@@ -35,25 +59,42 @@ func ImageFromFile(path string) (*BinaryImage, error) {
 // file type. A simple sanity-check is performed, and an error is returned if
 // the check fails. However, an image object with the specified content is
 // produced regardless of the error value.
+//
+// The file type is detected from the contents for png, jpeg, gif, webp, and
+// svg images. If it differs from filetype, the detected type is used.
+// Equivalent spellings such as "jpg" and "jpeg" are considered equal.
 func ImageFromBytes(b []byte, filetype string) (*BinaryImage, error) {
+	img := &BinaryImage{
+		content:   b,
+		extension: normalizeFiletype(filetype),
+	}
+
+	detected := sniffImageType(b)
+	if detected == "" {
+		mime := http.DetectContentType(b)
+		return img, fmt.Errorf("Given bytes do not seem to be an image (detected MIME-type is %q)", mime)
+	}
+
 	var err error
-	mime := http.DetectContentType(b)
-	if !strings.HasPrefix(mime, "image/") {
-		err = fmt.Errorf("Given bytes do not seem to be an image (detected MIME-type is %q)", mime)
-	} else if ext := strings.TrimPrefix(mime, "image/"); ext != filetype {
-		err = fmt.Errorf("Warning: File seems to be %s, not %s", ext, filetype)
+	if detected != img.extension {
+		err = fmt.Errorf("Warning: File seems to be %s, not %s", detected, img.extension)
+		img.extension = detected
 	}
 
-	return &BinaryImage{
-		content:   b,
-		extension: strings.TrimPrefix(filetype, "."),
-	}, err
+	dim, dimErr := imageSize(b, detected)
+	if dimErr != nil {
+		return img, fmt.Errorf("Failed to decode image dimensions: %v", dimErr)
+	}
+	img.dim = dim
+
+	return img, err
 }
 
-// SetAltDescription allows given string to be used as the 'alt' attribute when
-// converting to HTML.
-func (img *BinaryImage) SetAltDescription(s string) {
-	img.alt = s
+// GetDimension returns the width and height at which img is displayed. For
+// images read from files or bytes, this is the size in pixels. If the size is
+// unknown, both values are zero.
+func (img *BinaryImage) GetDimension() [2]float64 {
+	return img.dim
 }
 
 // Filetype returns the filetype of img.
@@ -63,17 +104,32 @@ func (img *BinaryImage) Filetype() string {
 
 // ToHtml embeds img in Moodle-ready HTML and writes it to w.
 // This should be used with care, especially with large image files, as they
-// will be included directly in the HTML code.
+// will be included directly in the HTML code. Consider attaching the image
+// using moodle.NewFile instead.
 func (img *BinaryImage) ToHtml(w io.Writer) {
-	fmt.Fprintf(w, `<img src="data:image/%s;base64,`, img.Filetype())
+	fmt.Fprintf(w, `<img src="data:%s;base64,`, mimeType(img.Filetype()))
 
 	img.ToBase64(w)
 
+	fmt.Fprint(w, `"`)
+	if img.dim != [2]float64{} {
+		fmt.Fprintf(w, ` width="%.1f" height="%.1f"`, img.dim[0], img.dim[1])
+	}
 	if img.alt != "" {
-		fmt.Fprintf(w, `" alt="%s" />`, img.alt)
-	} else {
-		fmt.Fprintf(w, `" />`)
+		fmt.Fprintf(w, ` alt="%s"`, html.EscapeString(img.alt))
+	}
+	if img.title != "" {
+		fmt.Fprintf(w, ` title="%s"`, html.EscapeString(img.title))
 	}
+	if img.long == "" {
+		fmt.Fprint(w, ` />`)
+		return
+	}
+
+	// Expose the long description to screen readers only
+	id := img.descriptionId(img.content)
+	fmt.Fprintf(w, ` aria-describedby="%s-desc" />`, id)
+	fmt.Fprintf(w, `<span id="%s-desc" class="sr-only">%s</span>`, id, html.EscapeString(img.long))
 }
 
 // ToBase64 encodes img to base64 format.
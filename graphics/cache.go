@@ -0,0 +1,328 @@
+package graphics
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache stores compiled TikZ graphics on disk, such that identical figures are
+// only compiled once. Entries are addressed by a hash of the full LaTeX document
+// and the versions of the external tools.
+//
+// To let SvgFromTikz and SvgFromMultipageTikz use a cache, pass it to UseCache.
+type Cache struct {
+	dir     string
+	maxSize int64
+
+	mu     sync.Mutex
+	hits   uint
+	misses uint
+}
+
+// CacheStats contains usage statistics for a Cache.
+type CacheStats struct {
+	Hits    uint  // Number of lookups that found a compiled figure
+	Misses  uint  // Number of lookups that required compilation
+	Entries int   // Number of figures currently stored
+	Size    int64 // Total size of stored figures in bytes
+}
+
+// tikzCache is the cache used when compiling TikZ graphics. It is nil unless
+// enabled by UseCache.
+var tikzCache *Cache
+
+// UseCache sets the cache used by SvgFromTikz and SvgFromMultipageTikz.
+// Caching is disabled by passing nil, which is also the default.
+func UseCache(c *Cache) {
+	tikzCache = c
+}
+
+// NewCache creates a cache in the folder dir, creating the folder if needed.
+// If maxSize is positive, the least recently used figures are removed whenever
+// the total size of the cache exceeds maxSize bytes.
+func NewCache(dir string, maxSize int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &Cache{
+		dir:     dir,
+		maxSize: maxSize,
+	}, nil
+}
+
+// Stats returns the usage statistics of c.
+// Hits and misses are counted since the creation of c, while the number of
+// entries and their size reflect the current contents of the folder.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	stats := CacheStats{Hits: c.hits, Misses: c.misses}
+	c.mu.Unlock()
+
+	entries, _ := c.entries()
+	stats.Entries = len(entries)
+	for _, e := range entries {
+		stats.Size += e.size
+	}
+
+	return stats
+}
+
+// Clear removes all figures from c.
+func (c *Cache) Clear() error {
+	entries, err := c.entries()
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if err := os.RemoveAll(e.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// load retrieves the figures compiled from document using engine. If document
+// is not in the cache, ok will be false.
+func (c *Cache) load(document string, engine Engine) (svgs []*SvgImage, ok bool) {
+	entry := filepath.Join(c.dir, cacheKey(document, engine))
+	paths, _ := filepath.Glob(filepath.Join(entry, "page*.svg"))
+
+	if len(paths) == 0 {
+		c.count(false)
+		return nil, false
+	}
+
+	sort.Strings(paths)
+	svgs = make([]*SvgImage, len(paths))
+	for i, v := range paths {
+		var err error
+		if svgs[i], err = SvgFromFile(v); err == nil {
+			svgs[i].zones, err = loadZones(strings.TrimSuffix(v, ".svg") + ".zones")
+		}
+		if err != nil {
+			// Treat a corrupted entry as missing
+			os.RemoveAll(entry)
+			c.count(false)
+			return nil, false
+		}
+	}
+
+	if pdfPath := filepath.Join(entry, "document.pdf"); pathExists(pdfPath) {
+		if err := attachPdf(svgs, pdfPath); err != nil {
+			os.RemoveAll(entry)
+			c.count(false)
+			return nil, false
+		}
+	}
+
+	// Mark entry as recently used
+	now := time.Now()
+	os.Chtimes(entry, now, now)
+
+	c.count(true)
+	return svgs, true
+}
+
+// store copies the compiled figures in svgPaths to c along with the PDF at
+// pdfPath from which they were converted. If pdfPath is empty, no PDF is
+// stored. If zones is non-nil, it must contain the zones of each figure.
+func (c *Cache) store(document string, engine Engine, pdfPath string, svgPaths []string, zones []map[string][2]float64) error {
+	tmp, err := os.MkdirTemp(c.dir, "tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	if pdfPath != "" {
+		if err := copyFile(pdfPath, filepath.Join(tmp, "document.pdf")); err != nil {
+			return err
+		}
+	}
+
+	for i, v := range svgPaths {
+		page := filepath.Join(tmp, fmt.Sprintf("page%03d", i+1))
+		if err := copyFile(v, page+".svg"); err != nil {
+			return err
+		}
+		if zones != nil && len(zones[i]) > 0 {
+			if err := storeZones(page+".zones", zones[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	entry := filepath.Join(c.dir, cacheKey(document, engine))
+	if pathExists(entry) {
+		// Another process stored the same figure in the meantime
+		return nil
+	}
+	if err := os.Rename(tmp, entry); err != nil {
+		return err
+	}
+
+	return c.evict()
+}
+
+// storeZones writes zones to the file at path.
+func storeZones(path string, zones map[string][2]float64) error {
+	b, err := json.Marshal(zones)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0o644)
+}
+
+// loadZones reads zones written by storeZones. If the file does not exist, no
+// zones are returned.
+func loadZones(path string) (map[string][2]float64, error) {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var zones map[string][2]float64
+	if err := json.Unmarshal(b, &zones); err != nil {
+		return nil, err
+	}
+	return zones, nil
+}
+
+// evict removes the least recently used entries until the cache size no longer
+// exceeds the maximum.
+func (c *Cache) evict() error {
+	if c.maxSize <= 0 {
+		return nil
+	}
+
+	entries, err := c.entries()
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	for _, e := range entries {
+		size += e.size
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].used.Before(entries[j].used)
+	})
+	for _, e := range entries {
+		if size <= c.maxSize {
+			break
+		}
+		if err := os.RemoveAll(e.path); err != nil {
+			return err
+		}
+		size -= e.size
+	}
+
+	return nil
+}
+
+// count updates the hit or miss statistics.
+func (c *Cache) count(hit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if hit {
+		c.hits++
+	} else {
+		c.misses++
+	}
+}
+
+// cacheEntry describes a single compiled figure in the cache.
+type cacheEntry struct {
+	path string
+	size int64
+	used time.Time
+}
+
+// entries lists the figures currently stored in c.
+func (c *Cache) entries() ([]cacheEntry, error) {
+	dirs, err := os.ReadDir(c.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]cacheEntry, 0, len(dirs))
+	for _, d := range dirs {
+		if !d.IsDir() || len(d.Name()) != 2*sha256.Size {
+			continue
+		}
+		info, err := d.Info()
+		if err != nil {
+			continue
+		}
+
+		e := cacheEntry{
+			path: filepath.Join(c.dir, d.Name()),
+			used: info.ModTime(),
+		}
+		files, _ := os.ReadDir(e.path)
+		for _, f := range files {
+			if fi, err := f.Info(); err == nil {
+				e.size += fi.Size()
+			}
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// cacheKey computes the key used to address the figures compiled from
+// document using engine.
+func cacheKey(document string, engine Engine) string {
+	hash := sha256.New()
+	io.WriteString(hash, document)
+	hash.Write([]byte{0})
+	io.WriteString(hash, toolVersions(engine))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+var (
+	versionsMu sync.Mutex
+	versions   = make(map[Engine]string)
+)
+
+// toolVersions returns the version information of the external tools used for
+// compilation with engine. Tools that are not installed are ignored.
+func toolVersions(engine Engine) string {
+	versionsMu.Lock()
+	defer versionsMu.Unlock()
+
+	if v, ok := versions[engine]; ok {
+		return v
+	}
+
+	var b bytes.Buffer
+	for _, args := range [][]string{
+		{string(engine), "--version"},
+		{"pdftocairo", "-v"},
+	} {
+		out, _ := exec.Command(args[0], args[1:]...).CombinedOutput()
+		line, _, _ := bytes.Cut(out, []byte("\n"))
+		fmt.Fprintf(&b, "%s: %s\n", args[0], line)
+	}
+	versions[engine] = b.String()
+
+	return versions[engine]
+}
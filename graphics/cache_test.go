@@ -0,0 +1,98 @@
+package graphics
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestSvg(t *testing.T, dir string, name string, width int) string {
+	path := filepath.Join(dir, name)
+	content := fmt.Sprintf(`<svg width="%dpt" height="10pt"></svg>`, width)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Writing test svg caused error: %s", err)
+	}
+	return path
+}
+
+func TestCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	c, err := NewCache(filepath.Join(tmpDir, "cache"), 0)
+	if err != nil {
+		t.Fatalf("Creating cache caused error: %s", err)
+	}
+
+	if _, ok := c.load("document", PdfLatex); ok {
+		t.Fatalf("Empty cache returned a figure")
+	}
+
+	paths := []string{
+		writeTestSvg(t, tmpDir, "tikz01.svg", 1),
+		writeTestSvg(t, tmpDir, "tikz02.svg", 2),
+	}
+	zones := []map[string][2]float64{nil, {"A": {1, 2}}}
+	pdfPath := filepath.Join(tmpDir, "tikz.pdf")
+	os.WriteFile(pdfPath, []byte("%PDF-1.5"), 0o644)
+	if err := c.store("document", PdfLatex, pdfPath, paths, zones); err != nil {
+		t.Fatalf("Storing figures caused error: %s", err)
+	}
+
+	svgs, ok := c.load("document", PdfLatex)
+	if !ok {
+		t.Fatalf("Stored figures were not found")
+	}
+	if len(svgs) != 2 || svgs[0].dim[0] != 1 || svgs[1].dim[0] != 2 {
+		t.Errorf("Cache did not preserve pages and their order")
+	}
+	if len(svgs[0].zones) != 0 || svgs[1].zones["A"] != [2]float64{1, 2} {
+		t.Errorf("Cache did not preserve zones")
+	}
+	if svgs[1].pdf == nil || string(svgs[1].pdf.content) != "%PDF-1.5" || svgs[1].pdf.page != 2 {
+		t.Errorf("Cache did not preserve the PDF")
+	}
+	if _, ok := c.load("other document", PdfLatex); ok {
+		t.Errorf("Cache returned figure for unknown document")
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.Misses != 2 || stats.Entries != 1 || stats.Size == 0 {
+		t.Errorf("Unexpected statistics %+v", stats)
+	}
+
+	if err := c.Clear(); err != nil {
+		t.Fatalf("Clearing cache caused error: %s", err)
+	}
+	if stats := c.Stats(); stats.Entries != 0 {
+		t.Errorf("Cache contains %d entries after clearing", stats.Entries)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := writeTestSvg(t, tmpDir, "tikz01.svg", 1)
+	info, _ := os.Stat(path)
+
+	// Room for two figures only
+	c, err := NewCache(filepath.Join(tmpDir, "cache"), 2*info.Size())
+	if err != nil {
+		t.Fatalf("Creating cache caused error: %s", err)
+	}
+
+	for i := range 3 {
+		if err := c.store(fmt.Sprintf("document %d", i), PdfLatex, "", []string{path}, nil); err != nil {
+			t.Fatalf("Storing figure caused error: %s", err)
+		}
+		// Ensure distinct modification times
+		used := time.Now().Add(time.Duration(i-3) * time.Minute)
+		os.Chtimes(filepath.Join(c.dir, cacheKey(fmt.Sprintf("document %d", i), PdfLatex)), used, used)
+	}
+
+	if stats := c.Stats(); stats.Entries != 2 {
+		t.Fatalf("Cache contains %d entries, but 2 were expected", stats.Entries)
+	}
+	if _, ok := c.load("document 0", PdfLatex); ok {
+		t.Errorf("Least recently used figure was not evicted")
+	}
+}
@@ -0,0 +1,229 @@
+package graphics
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Unit is a CSS unit of length used for the dimensions of an SvgImage.
+type Unit string
+
+// Supported units of length. Since em is relative to the font size, it is
+// converted to other units assuming a font size of 16px.
+const (
+	UserUnit Unit = "" // Unitless values are interpreted as px
+	Px       Unit = "px"
+	Pt       Unit = "pt"
+	Pc       Unit = "pc"
+	Mm       Unit = "mm"
+	Cm       Unit = "cm"
+	In       Unit = "in"
+	Q        Unit = "Q"
+	Em       Unit = "em"
+)
+
+// pxPerUnit gives the size of each unit measured in px.
+var pxPerUnit = map[Unit]float64{
+	UserUnit: 1,
+	Px:       1,
+	Pt:       96.0 / 72,
+	Pc:       16,
+	Mm:       96 / 25.4,
+	Cm:       96 / 2.54,
+	In:       96,
+	Q:        96 / 101.6,
+	Em:       16,
+}
+
+// ConvertLength converts the length v from one unit to another. An error is
+// returned if either unit is unsupported.
+func ConvertLength(v float64, from, to Unit) (float64, error) {
+	f, ok := pxPerUnit[from]
+	if !ok {
+		return 0, fmt.Errorf("Unsupported unit %q", from)
+	}
+	t, ok := pxPerUnit[to]
+	if !ok {
+		return 0, fmt.Errorf("Unsupported unit %q", to)
+	}
+	if f == t {
+		return v, nil
+	}
+	return v * f / t, nil
+}
+
+var reLength = regexp.MustCompile(`^([+-]?(?:[0-9]+\.?[0-9]*|\.[0-9]+)(?:[eE][+-]?[0-9]+)?)\s*([a-zA-Z%]*)$`)
+
+// percent is the unit of lengths relative to the surrounding document. Such
+// lengths cannot be converted to other units.
+const percent Unit = "%"
+
+// parseLength parses a length such as "12.5pt" or "100%". Percentages are
+// returned with the unit percent.
+func parseLength(s string) (float64, Unit, error) {
+	match := reLength.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		return 0, "", fmt.Errorf("Invalid length %q", s)
+	}
+
+	unit := Unit(match[2])
+	if unit != Q {
+		unit = Unit(strings.ToLower(match[2]))
+	}
+	if _, ok := pxPerUnit[unit]; !ok && unit != percent {
+		return 0, "", fmt.Errorf("Unsupported unit in length %q", s)
+	}
+
+	v, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, "", err
+	}
+	return v, unit, nil
+}
+
+// svgRoot describes the start tag of the root element in an svg document.
+type svgRoot struct {
+	start, end int // Position of the start tag in the document
+	attrs      []xml.Attr
+}
+
+// findSvgRoot locates the start tag of the root element in content.
+func findSvgRoot(content []byte) (svgRoot, error) {
+	d := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		start := d.InputOffset()
+		tok, err := d.RawToken()
+		if err != nil {
+			if err == io.EOF {
+				return svgRoot{}, fmt.Errorf("No svg element found")
+			}
+			return svgRoot{}, fmt.Errorf("Failed to parse svg: %v", err)
+		}
+
+		if el, ok := tok.(xml.StartElement); ok {
+			if el.Name.Local != "svg" {
+				return svgRoot{}, fmt.Errorf("Root element is %q, not \"svg\"", el.Name.Local)
+			}
+			return svgRoot{
+				start: int(start),
+				end:   int(d.InputOffset()),
+				attrs: el.Attr,
+			}, nil
+		}
+	}
+}
+
+// attr returns the value of the attribute name of the root element.
+func (r svgRoot) attr(name string) (string, bool) {
+	for _, a := range r.attrs {
+		if a.Name.Space == "" && a.Name.Local == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// viewBox returns the viewBox of the root element, if specified.
+func (r svgRoot) viewBox() ([]float64, bool) {
+	s, ok := r.attr("viewBox")
+	if !ok {
+		return nil, false
+	}
+	vb := parseNumbers(s)
+	if len(vb) != 4 || vb[2] <= 0 || vb[3] <= 0 {
+		return nil, false
+	}
+	return vb, true
+}
+
+// svgDimensions determines the width and height of an svg document from its
+// root element. If the width or height is missing or relative, it is derived
+// from the viewBox.
+func svgDimensions(content []byte) ([2]float64, Unit, error) {
+	root, err := findSvgRoot(content)
+	if err != nil {
+		return [2]float64{}, "", err
+	}
+
+	var (
+		dim   [2]float64
+		units [2]Unit
+		ok    [2]bool
+	)
+	for i, name := range [2]string{"width", "height"} {
+		if s, set := root.attr(name); set {
+			// Percentages depend on the surrounding document, so the viewBox
+			// is used instead
+			v, u, err := parseLength(s)
+			if err == nil && v > 0 && u != percent {
+				dim[i], units[i], ok[i] = v, u, true
+			}
+		}
+	}
+
+	vb, hasViewBox := root.viewBox()
+	switch {
+	case ok[0] && ok[1]:
+		// Express both dimensions in the unit of the width
+		dim[1], _ = ConvertLength(dim[1], units[1], units[0])
+	case ok[0] && hasViewBox:
+		dim[1] = dim[0] * vb[3] / vb[2]
+	case ok[1] && hasViewBox:
+		dim[0] = dim[1] * vb[2] / vb[3]
+		units[0] = units[1]
+	case hasViewBox:
+		dim, units[0] = [2]float64{vb[2], vb[3]}, Px
+	default:
+		return [2]float64{}, "", fmt.Errorf("Failed to extract dimensions of svg.")
+	}
+
+	return dim, units[0], nil
+}
+
+// setRootAttrs sets the given attributes on the root element of content.
+func setRootAttrs(content []byte, attrs map[string]string) ([]byte, error) {
+	root, err := findSvgRoot(content)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := content[root.start:root.end]
+	names := make([]string, 0, len(attrs))
+	for k := range attrs {
+		names = append(names, k)
+	}
+	slices.Sort(names)
+	for _, k := range names {
+		tag = setTagAttr(tag, k, attrs[k])
+	}
+
+	return slices.Concat(content[:root.start], tag, content[root.end:]), nil
+}
+
+// setTagAttr sets the attribute name to value in the XML start tag, adding the
+// attribute if necessary.
+func setTagAttr(tag []byte, name, value string) []byte {
+	re := regexp.MustCompile(`(\s` + regexp.QuoteMeta(name) + `\s*=\s*)("[^"]*"|'[^']*')`)
+	if re.Match(tag) {
+		return re.ReplaceAllLiteral(tag, fmt.Appendf(nil, ` %s="%s"`, name, value))
+	}
+
+	end := len(tag) - 1
+	if bytes.HasSuffix(tag, []byte("/>")) {
+		end--
+	}
+	return slices.Concat(tag[:end], fmt.Appendf(nil, ` %s="%s"`, name, value), tag[end:])
+}
+
+// formatSvgNumber formats f with three decimals, omitting trailing zeros.
+func formatSvgNumber(f float64) string {
+	s := strconv.FormatFloat(f, 'f', 3, 64)
+	s = strings.TrimRight(s, "0")
+	return strings.TrimSuffix(s, ".")
+}
@@ -0,0 +1,132 @@
+package graphics
+
+import (
+	"bytes"
+	"encoding/base64"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseLength(t *testing.T) {
+	testCases := []struct {
+		s    string
+		v    float64
+		unit Unit
+	}{
+		{"12.5pt", 12.5, Pt},
+		{"100", 100, UserUnit},
+		{" 3.2 mm ", 3.2, Mm},
+		{"2IN", 2, In},
+		{"1e2px", 100, Px},
+		{"8Q", 8, Q},
+		{"100%", 100, percent},
+	}
+	for _, v := range testCases {
+		val, unit, err := parseLength(v.s)
+		if err != nil {
+			t.Errorf("Parsing %q caused error: %s", v.s, err)
+			continue
+		}
+		if val != v.v || unit != v.unit {
+			t.Errorf("Parsing %q gave %f%s, but expected %f%s", v.s, val, unit, v.v, v.unit)
+		}
+	}
+
+	for _, s := range []string{"12ft", "%", "pt", ""} {
+		if _, _, err := parseLength(s); err == nil {
+			t.Errorf("Parsing %q failed to return an error", s)
+		}
+	}
+}
+
+func TestSvgDimensions(t *testing.T) {
+	testCases := []struct {
+		root string
+		dim  [2]float64
+		unit Unit
+	}{
+		{`<svg width="10cm" height="50mm">`, [2]float64{10, 5}, Cm},
+		{`<svg viewBox="0 0 400 300">`, [2]float64{400, 300}, Px},
+		{`<svg width="100%" height="100%" viewBox="0 0 40 30">`, [2]float64{40, 30}, Px},
+		{`<svg width="2in" viewBox="0 0 40 30">`, [2]float64{2, 1.5}, In},
+		{`<svg width="100%" height="15pt" viewBox="0 0 40 30">`, [2]float64{20, 15}, Pt},
+		{`<?xml version="1.0"?>
+<!-- width="1pt" -->
+<svg xmlns="http://www.w3.org/2000/svg" height="20pt" width="10pt">`, [2]float64{10, 20}, Pt},
+	}
+	for _, v := range testCases {
+		dim, unit, err := svgDimensions([]byte(v.root + `<rect width="1" height="1"/></svg>`))
+		if err != nil {
+			t.Errorf("Extracting dimensions from %q caused error: %s", v.root, err)
+			continue
+		}
+		if math.Abs(dim[0]-v.dim[0]) > 1e-9 || math.Abs(dim[1]-v.dim[1]) > 1e-9 || unit != v.unit {
+			t.Errorf("Extracted %v%s from %q, but expected %v%s", dim, unit, v.root, v.dim, v.unit)
+		}
+	}
+
+	if _, err := SvgFromBytes([]byte(`<svg width="100%"></svg>`)); err == nil {
+		t.Errorf("Svg without absolute dimensions failed to return an error")
+	}
+}
+
+func TestResize(t *testing.T) {
+	img, err := SvgFromBytes([]byte(`<svg width="4cm" height="3cm"><rect width="1" height="1"/></svg>`))
+	if err != nil {
+		t.Fatalf("Reading svg caused error: %s", err)
+	}
+
+	if err := img.Scale(2); err != nil {
+		t.Fatalf("Scaling caused error: %s", err)
+	}
+	if img.GetDimension() != [2]float64{8, 6} || img.Unit() != Cm {
+		t.Errorf("Scaling produced %v%s", img.GetDimension(), img.Unit())
+	}
+	for _, v := range []string{`width="8cm"`, `height="6cm"`, `viewBox="0 0 151.181 113.386"`} {
+		if !bytes.Contains(img.content, []byte(v)) {
+			t.Errorf("Scaled svg does not contain %s:\n%s", v, img.content)
+		}
+	}
+	if !bytes.Contains(img.content, []byte(`<rect width="1" height="1"/>`)) {
+		t.Errorf("Scaling changed the contents of the svg")
+	}
+
+	if err := img.ResizeTo(200, 0, Px); err != nil {
+		t.Fatalf("Resizing caused error: %s", err)
+	}
+	if img.GetDimension() != [2]float64{200, 150} || img.Unit() != Px {
+		t.Errorf("Resizing produced %v%s", img.GetDimension(), img.Unit())
+	}
+
+	dim, err := img.DimensionIn(Pt)
+	if err != nil || dim != [2]float64{150, 112.5} {
+		t.Errorf("Converting dimensions to pt produced %v (error %v)", dim, err)
+	}
+
+	for _, v := range [][2]float64{{0, 0}, {-1, 2}} {
+		if err := img.ResizeTo(v[0], v[1], Px); err == nil {
+			t.Errorf("Resizing to %v failed to return an error", v)
+		}
+	}
+	if err := img.ResizeTo(1, 1, "ft"); err == nil {
+		t.Errorf("Resizing with unsupported unit failed to return an error")
+	}
+}
+
+func TestToBase64Dimensions(t *testing.T) {
+	img, err := SvgFromBytes([]byte(`<svg width="12.5pt" height="10pt" viewBox="0 0 12.5 10"></svg>`))
+	if err != nil {
+		t.Fatalf("Reading svg caused error: %s", err)
+	}
+
+	var b strings.Builder
+	img.ToBase64(&b)
+	decoded, err := base64.StdEncoding.DecodeString(b.String())
+	if err != nil {
+		t.Fatalf("Decoding output caused error: %s", err)
+	}
+	if !bytes.Contains(decoded, []byte(`width="16.667px" height="13.333px"`)) {
+		t.Errorf("Unexpected base64 contents %s", decoded)
+	}
+}
@@ -11,6 +11,15 @@
 //
 // The package relies on external tools to perform the compilation and
 // conversion of TikZ graphics. More precisely, pdflatex and either pdftocairo
-// or pdf2svg must be installed for the functions to succeed. If cropping of
-// figures is requested, the package will call Inkscape.
+// or pdf2svg must be installed for the functions to succeed. To use a different
+// LaTeX-engine or to customize the preamble, use a TikzCompiler. Cropping of
+// figures is done without external tools, but Inkscape is used as a fallback if
+// it is installed.
+//
+// Figures may record named positions using \moodlezone{name}{coordinate}. The
+// positions are available in pixels from SvgImage.ZonePosition, which makes it
+// possible to place drop zones from the TikZ source.
+//
+// Compiling TikZ graphics is slow, so if many questions share the same figures,
+// consider enabling a Cache via UseCache.
 package graphics
@@ -24,3 +24,12 @@ func pathExists(path string) bool {
 	}
 	return true
 }
+
+// copyFile copies the file at src to dst.
+func copyFile(src, dst string) error {
+	content, err := fileAsBytes(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, content, 0o644)
+}
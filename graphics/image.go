@@ -1,11 +1,71 @@
 package graphics
 
 import (
+	"fmt"
+	"hash/fnv"
 	"io"
 )
 
+// Image is the common interface of all image types.
 type Image interface {
 	Filetype() string
 	ToHtml(w io.Writer)
 	ToBase64(w io.Writer)
+
+	SetAltDescription(s string)
+	AltDescription() string
+	SetTitle(s string)
+	Title() string
+	SetLongDescription(s string)
+	LongDescription() string
+}
+
+// description contains the textual descriptions of an image that are used to
+// make it accessible. It is embedded in the image types.
+type description struct {
+	alt   string
+	title string
+	long  string
+}
+
+// SetAltDescription sets a short text alternative for the image. It is used as
+// the 'alt' attribute (or the title element of an svg) when converting to HTML.
+func (d *description) SetAltDescription(s string) {
+	d.alt = s
+}
+
+// AltDescription returns the short text alternative for the image.
+func (d *description) AltDescription() string {
+	return d.alt
+}
+
+// SetTitle sets the title of the image, which is typically shown as a tooltip.
+func (d *description) SetTitle(s string) {
+	d.title = s
+}
+
+// Title returns the title of the image.
+func (d *description) Title() string {
+	return d.title
+}
+
+// SetLongDescription sets a detailed description of the image, for instance
+// explaining the data shown in a plot. It is exposed to screen readers, but not
+// displayed.
+func (d *description) SetLongDescription(s string) {
+	d.long = s
+}
+
+// LongDescription returns the detailed description of the image.
+func (d *description) LongDescription() string {
+	return d.long
+}
+
+// descriptionId generates an id for the HTML elements describing an image with
+// the given content.
+func (d *description) descriptionId(content []byte) string {
+	hash := fnv.New32a()
+	hash.Write(content)
+	fmt.Fprintf(hash, "%s\x00%s\x00%s", d.alt, d.title, d.long)
+	return fmt.Sprintf("mi-%X", hash.Sum32())
 }
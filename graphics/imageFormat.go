@@ -0,0 +1,111 @@
+package graphics
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"strings"
+)
+
+// normalizeFiletype converts a file extension or type into the name used by
+// this package. For instance, ".JPG" becomes "jpeg".
+func normalizeFiletype(filetype string) string {
+	filetype = strings.ToLower(strings.TrimPrefix(filetype, "."))
+	switch filetype {
+	case "jpg", "jpe", "jfif":
+		return "jpeg"
+	case "svgz", "svg+xml":
+		return "svg"
+	}
+	return filetype
+}
+
+// mimeType returns the MIME-type corresponding to a normalized file type.
+func mimeType(filetype string) string {
+	if filetype == "svg" {
+		return "image/svg+xml"
+	}
+	return "image/" + filetype
+}
+
+// sniffImageType determines the file type of an image from its contents.
+// Supported types are png, jpeg, gif, webp, and svg. If the type cannot be
+// determined, the empty string is returned.
+func sniffImageType(b []byte) string {
+	switch {
+	case bytes.HasPrefix(b, []byte("\x89PNG\r\n\x1a\n")):
+		return "png"
+	case bytes.HasPrefix(b, []byte("\xff\xd8\xff")):
+		return "jpeg"
+	case bytes.HasPrefix(b, []byte("GIF87a")), bytes.HasPrefix(b, []byte("GIF89a")):
+		return "gif"
+	case len(b) >= 12 && bytes.HasPrefix(b, []byte("RIFF")) && string(b[8:12]) == "WEBP":
+		return "webp"
+	}
+
+	if _, err := findSvgRoot(b); err == nil {
+		return "svg"
+	}
+	return ""
+}
+
+// imageSize decodes the width and height (in pixels) of an image of the given
+// normalized file type.
+func imageSize(b []byte, filetype string) ([2]float64, error) {
+	switch filetype {
+	case "webp":
+		return webpSize(b)
+	case "svg":
+		dim, unit, err := svgDimensions(b)
+		if err != nil {
+			return [2]float64{}, err
+		}
+		w, _ := ConvertLength(dim[0], unit, Px)
+		h, _ := ConvertLength(dim[1], unit, Px)
+		return [2]float64{w, h}, nil
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return [2]float64{}, err
+	}
+	return [2]float64{float64(cfg.Width), float64(cfg.Height)}, nil
+}
+
+// webpSize extracts the canvas size from the header of a WebP image.
+func webpSize(b []byte) ([2]float64, error) {
+	if len(b) < 30 {
+		return [2]float64{}, fmt.Errorf("WebP header is too short")
+	}
+
+	var w, h uint32
+	switch string(b[12:16]) {
+	case "VP8X":
+		// Extended format: 24-bit canvas width and height minus one
+		w = 1 + (uint32(b[24]) | uint32(b[25])<<8 | uint32(b[26])<<16)
+		h = 1 + (uint32(b[27]) | uint32(b[28])<<8 | uint32(b[29])<<16)
+	case "VP8 ":
+		// Lossy format: 14-bit sizes following the start code of the key frame
+		if !bytes.Equal(b[23:26], []byte{0x9d, 0x01, 0x2a}) {
+			return [2]float64{}, fmt.Errorf("Invalid WebP key frame")
+		}
+		w = uint32(binary.LittleEndian.Uint16(b[26:28]) & 0x3fff)
+		h = uint32(binary.LittleEndian.Uint16(b[28:30]) & 0x3fff)
+	case "VP8L":
+		// Lossless format: 14-bit sizes minus one following the signature
+		if b[20] != 0x2f {
+			return [2]float64{}, fmt.Errorf("Invalid WebP lossless signature")
+		}
+		bits := binary.LittleEndian.Uint32(b[21:25])
+		w = 1 + bits&0x3fff
+		h = 1 + (bits>>14)&0x3fff
+	default:
+		return [2]float64{}, fmt.Errorf("Unknown WebP chunk %q", b[12:16])
+	}
+
+	return [2]float64{float64(w), float64(h)}, nil
+}
@@ -0,0 +1,135 @@
+package graphics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSniffImageType(t *testing.T) {
+	testCases := []struct {
+		content  []byte
+		expected string
+	}{
+		{pngExample, "png"},
+		{jpegExample, "jpeg"},
+		{[]byte("GIF89a..."), "gif"},
+		{[]byte("RIFF\x00\x00\x00\x00WEBPVP8 "), "webp"},
+		{[]byte("\n<?xml version=\"1.0\"?>\n<svg width=\"1\" height=\"1\"></svg>"), "svg"},
+		{[]byte("<html></html>"), ""},
+		{[]byte("test"), ""},
+	}
+
+	for i, v := range testCases {
+		if got := sniffImageType(v.content); got != v.expected {
+			t.Errorf("Test case %d: Detected %q, but expected %q", i, got, v.expected)
+		}
+	}
+}
+
+func TestWebpSize(t *testing.T) {
+	header := func(chunk string, data ...byte) []byte {
+		b := append([]byte("RIFF\x00\x00\x00\x00WEBP"+chunk+"\x00\x00\x00\x00"), data...)
+		for len(b) < 30 {
+			b = append(b, 0)
+		}
+		return b
+	}
+
+	testCases := map[string][]byte{
+		"VP8X": header("VP8X", 0, 0, 0, 0, 99, 0, 0, 74, 0, 0),
+		"VP8 ": header("VP8 ", 0, 0, 0, 0x9d, 0x01, 0x2a, 100, 0, 75, 0),
+		// Width 100 and height 75 minus one packed into 14-bit fields
+		"VP8L": header("VP8L", 0x2f, 0x63, 0x80, 0x12, 0x00),
+	}
+	for chunk, b := range testCases {
+		dim, err := webpSize(b)
+		if err != nil {
+			t.Errorf("Chunk %q: Decoding size caused error: %s", chunk, err)
+			continue
+		}
+		if dim != [2]float64{100, 75} {
+			t.Errorf("Chunk %q: Decoded size %v, but expected [100 75]", chunk, dim)
+		}
+	}
+}
+
+func TestImageFromBytesNormalization(t *testing.T) {
+	img, err := ImageFromBytes(jpegExample, ".JPG")
+	if err != nil {
+		t.Errorf("Jpeg with extension .JPG produced error: %s", err)
+	}
+	if img.Filetype() != "jpeg" {
+		t.Errorf("Expected file type jpeg, but got %q", img.Filetype())
+	}
+
+	img, err = ImageFromBytes(pngExample, "gif")
+	if err == nil {
+		t.Errorf("Mismatched file type failed to return an error")
+	}
+	if img.Filetype() != "png" {
+		t.Errorf("Detected file type was not used")
+	}
+	if img.GetDimension() != [2]float64{100, 75} {
+		t.Errorf("Expected dimensions [100 75], but got %v", img.GetDimension())
+	}
+}
+
+func TestOpenImage(t *testing.T) {
+	tmpDir := t.TempDir()
+	svgPath := filepath.Join(tmpDir, "test.svg")
+	os.WriteFile(svgPath, []byte(`<svg width="10pt" height="5pt"></svg>`), 0o644)
+
+	if _, err := ImageFromFile(svgPath); err == nil {
+		t.Errorf("Reading svg using ImageFromFile failed to return an error")
+	}
+
+	img, err := OpenImage(svgPath)
+	if err != nil {
+		t.Fatalf("Opening svg caused error: %s", err)
+	}
+	if _, ok := img.(*SvgImage); !ok {
+		t.Errorf("Opening svg produced %T", img)
+	}
+
+	pngPath := filepath.Join(tmpDir, "test.png")
+	os.WriteFile(pngPath, pngExample, 0o644)
+	img, err = OpenImage(pngPath)
+	if err != nil {
+		t.Fatalf("Opening png caused error: %s", err)
+	}
+	if _, ok := img.(*BinaryImage); !ok {
+		t.Errorf("Opening png produced %T", img)
+	}
+}
+
+func TestShrink(t *testing.T) {
+	img, _ := ImageFromBytes(pngExample, "png")
+
+	if err := img.Shrink(200, 0, 90); err != nil {
+		t.Fatalf("Shrinking caused error: %s", err)
+	}
+	if img.GetDimension() != [2]float64{100, 75} {
+		t.Errorf("Image that fits was resized to %v", img.GetDimension())
+	}
+
+	if err := img.Shrink(50, 50, 90); err != nil {
+		t.Fatalf("Shrinking caused error: %s", err)
+	}
+	if img.GetDimension() != [2]float64{50, 38} {
+		t.Errorf("Expected dimensions [50 38], but got %v", img.GetDimension())
+	}
+	if dim, _ := imageSize(img.content, "png"); dim != img.GetDimension() {
+		t.Errorf("Encoded image has size %v, but reports %v", dim, img.GetDimension())
+	}
+
+	if err := img.ConvertToJpeg(80); err != nil {
+		t.Fatalf("Converting to jpeg caused error: %s", err)
+	}
+	if img.Filetype() != "jpeg" || sniffImageType(img.content) != "jpeg" {
+		t.Errorf("Converted image is not a jpeg")
+	}
+	if err := img.ConvertToJpeg(0); err == nil {
+		t.Errorf("Invalid quality failed to return an error")
+	}
+}
@@ -0,0 +1,120 @@
+package graphics
+
+import (
+	"encoding/xml"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestAccessibleSvg(t *testing.T) {
+	img, err := SvgFromBytes([]byte(exampleCairoSvg))
+	if err != nil {
+		t.Fatalf("Reading svg caused error: %s", err)
+	}
+	img.SetAltDescription("A line & a glyph")
+	img.SetLongDescription("The line is <b>horizontal</b>")
+
+	var b strings.Builder
+	img.ToHtml(&b)
+	out := b.String()
+
+	for _, v := range []string{
+		`role="img"`,
+		`aria-labelledby="`,
+		`aria-describedby="`,
+		`-title">A line &amp; a glyph</title>`,
+		`-desc">The line is &lt;b&gt;horizontal&lt;/b&gt;</desc>`,
+	} {
+		if !strings.Contains(out, v) {
+			t.Errorf("HTML output does not contain %s", v)
+		}
+	}
+
+	d := xml.NewDecoder(strings.NewReader(out))
+	for {
+		err := d.Decode(new(any))
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Decoding XML output produced error: %q", err)
+		}
+	}
+
+	// Images without descriptions are not changed
+	img.SetAltDescription("")
+	img.SetLongDescription("")
+	b.Reset()
+	img.ToHtml(&b)
+	if strings.Contains(b.String(), `role="img"`) {
+		t.Errorf("Svg without descriptions was marked as an image")
+	}
+}
+
+func TestAccessibleBinary(t *testing.T) {
+	img, _ := ImageFromBytes(pngExample, "png")
+	img.SetAltDescription(`Mount "Everest"`)
+	img.SetTitle("Everest")
+	img.SetLongDescription("A mountain")
+
+	var b strings.Builder
+	img.ToHtml(&b)
+	out := b.String()
+
+	for _, v := range []string{
+		`alt="Mount &#34;Everest&#34;"`,
+		`title="Everest"`,
+		`aria-describedby="`,
+		`class="sr-only">A mountain</span>`,
+	} {
+		if !strings.Contains(out, v) {
+			t.Errorf("HTML output does not contain %s", v)
+		}
+	}
+}
+
+func TestNamespaceIds(t *testing.T) {
+	svg := `<svg width="1pt" height="1pt"><defs><symbol id="glyph0-1"/>` +
+		`<clipPath id='clip1'/></defs>` +
+		`<use xlink:href="#glyph0-1" href="#glyph0-1" clip-path="url(#clip1)" style="fill:url( '#clip1')"/></svg>`
+	expected := `<svg width="1pt" height="1pt"><defs><symbol id="p-glyph0-1"/>` +
+		`<clipPath id='p-clip1'/></defs>` +
+		`<use xlink:href="#p-glyph0-1" href="#p-glyph0-1" clip-path="url(#p-clip1)" style="fill:url( '#p-clip1')"/></svg>`
+
+	if got := string(namespaceIds([]byte(svg), "p-")); got != expected {
+		t.Errorf("Expected\n%s\nbut got\n%s", expected, got)
+	}
+}
+
+func TestUniqueIds(t *testing.T) {
+	imgs := make([]*SvgImage, 2)
+	for i := range imgs {
+		var err error
+		imgs[i], err = SvgFromBytes([]byte(exampleCairoSvg))
+		if err != nil {
+			t.Fatalf("Reading svg caused error: %s", err)
+		}
+	}
+	imgs[1].Scale(2)
+
+	var b [2]strings.Builder
+	for i, img := range imgs {
+		img.ToHtml(&b[i])
+	}
+
+	id := regexp.MustCompile(`\sid="([^"]*)"`)
+	ids := make(map[string]bool)
+	for _, match := range id.FindAllStringSubmatch(b[0].String(), -1) {
+		ids[match[1]] = true
+	}
+	for _, match := range id.FindAllStringSubmatch(b[1].String(), -1) {
+		if ids[match[1]] {
+			t.Errorf("Id %q is used in both images", match[1])
+		}
+	}
+	if !strings.Contains(b[0].String(), `xlink:href="#`+svgIdPrefix(imgs[0].content)+`glyph0-0"`) {
+		t.Errorf("References were not updated:\n%s", b[0].String())
+	}
+}
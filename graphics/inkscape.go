@@ -3,11 +3,51 @@ package graphics
 import (
 	"bytes"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
 	"strconv"
 )
 
+// cropWithInkscape crops img by calling Inkscape.
+func (img *SvgImage) cropWithInkscape() error {
+	// Create temporary folder
+	tmpDir, err := os.MkdirTemp("", "moodleTikz-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// Write image to disk
+	path := filepath.Join(tmpDir, "tmp.svg")
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	file.Write(img.content)
+	file.Close()
+
+	// Perform cropping
+	err = cropSvg(path)
+	if err != nil {
+		return err
+	}
+
+	// Overwrite image contents with cropped image. Inkscape moves the contents,
+	// so the positions of zones are lost, and the attached PDF no longer
+	// matches.
+	cropped, err := SvgFromFile(file.Name())
+	if err != nil {
+		return err
+	}
+	cropped.description = img.description
+	cropped.optimize = img.optimize
+	*img = *cropped
+
+	return nil
+}
+
 // cropSvg calls Inkscape to reduce the canvas size to its contents
 func cropSvg(fileName string) error {
 	version, err := inkscapeVersion()
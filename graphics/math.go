@@ -0,0 +1,62 @@
+package graphics
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SvgFromMath compiles a LaTeX math expression into a compact SvgImage. The
+// expression may be given with or without the delimiters $...$ or \(...\). The
+// alternative description of the image is set to the Unicode approximation
+// returned by MathToUnicode.
+//
+// The tmpDir argument is handled as in SvgFromTikz.
+func SvgFromMath(math string, tmpDir string) (*SvgImage, error) {
+	return NewTikzCompiler().SvgFromMath(math, tmpDir)
+}
+
+// PngFromMath compiles a LaTeX math expression into a png image with the given
+// resolution. See SvgFromMath for details.
+func PngFromMath(math string, dpi float64, tmpDir string) (*BinaryImage, error) {
+	return NewTikzCompiler().PngFromMath(math, dpi, tmpDir)
+}
+
+// SvgFromMath compiles a LaTeX math expression into an SvgImage using the
+// settings of tc. See the function SvgFromMath for details.
+func (tc *TikzCompiler) SvgFromMath(math string, tmpDir string) (*SvgImage, error) {
+	img, err := tc.SvgFromTikz(mathPicture(math), tmpDir)
+	if err != nil {
+		return nil, err
+	}
+	img.SetAltDescription(MathToUnicode(math))
+	return img, nil
+}
+
+// PngFromMath compiles a LaTeX math expression into a png image using the
+// settings of tc. See the function SvgFromMath for details.
+func (tc *TikzCompiler) PngFromMath(math string, dpi float64, tmpDir string) (*BinaryImage, error) {
+	img, err := tc.PngFromTikz(mathPicture(math), dpi, tmpDir)
+	if err != nil {
+		return nil, err
+	}
+	img.SetAltDescription(MathToUnicode(math))
+	return img, nil
+}
+
+// mathPicture returns a TikZ-picture containing only the given math
+// expression.
+func mathPicture(math string) string {
+	return fmt.Sprintf("\\begin{tikzpicture}\n\\node[inner sep=1pt] {$%s$};\n\\end{tikzpicture}",
+		stripMathDelimiters(math))
+}
+
+// stripMathDelimiters removes surrounding math delimiters from s.
+func stripMathDelimiters(s string) string {
+	s = strings.TrimSpace(s)
+	for _, d := range [...][2]string{{`$$`, `$$`}, {`$`, `$`}, {`\(`, `\)`}, {`\[`, `\]`}} {
+		if len(s) >= len(d[0])+len(d[1]) && strings.HasPrefix(s, d[0]) && strings.HasSuffix(s, d[1]) {
+			return strings.TrimSpace(s[len(d[0]) : len(s)-len(d[1])])
+		}
+	}
+	return s
+}
@@ -0,0 +1,583 @@
+package graphics
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// OptimizeOptions controls the optimization of svg images. Comments, metadata
+// and whitespace between elements are always removed.
+type OptimizeOptions struct {
+	Precision          int  // Number of decimals kept in coordinates (negative to disable rounding)
+	RemoveUnusedDefs   bool // Remove definitions that are never referenced
+	CollapseTransforms bool // Combine nested and chained transformations
+	ShareGlyphs        bool // Convert glyphs to symbols and merge identical ones
+}
+
+// DefaultOptimizeOptions returns options that reduce the size of svgs produced
+// by pdftocairo or pdf2svg without visible changes.
+func DefaultOptimizeOptions() OptimizeOptions {
+	return OptimizeOptions{
+		Precision:          2,
+		RemoveUnusedDefs:   true,
+		CollapseTransforms: true,
+		ShareGlyphs:        true,
+	}
+}
+
+// OptimizeReport describes the result of an optimization.
+type OptimizeReport struct {
+	OriginalSize  int // Size in bytes before optimization
+	OptimizedSize int // Size in bytes after optimization
+	RemovedDefs   int // Number of unused definitions removed
+	MergedGlyphs  int // Number of duplicate glyphs removed
+}
+
+// Savings returns the relative reduction in size, i.e. a number between 0
+// and 1.
+func (r OptimizeReport) Savings() float64 {
+	if r.OriginalSize == 0 {
+		return 0
+	}
+	return 1 - float64(r.OptimizedSize)/float64(r.OriginalSize)
+}
+
+// String returns a human-readable summary of the report.
+func (r OptimizeReport) String() string {
+	return fmt.Sprintf(
+		"Reduced size from %d to %d bytes (%.1f%%); removed %d unused definitions and %d duplicate glyphs",
+		r.OriginalSize, r.OptimizedSize, 100*r.Savings(), r.RemovedDefs, r.MergedGlyphs,
+	)
+}
+
+// Optimize reduces the size of img according to opts.
+func (img *SvgImage) Optimize(opts OptimizeOptions) (OptimizeReport, error) {
+	content, report, err := optimizeSvg(img.content, opts)
+	if err != nil {
+		return OptimizeReport{}, err
+	}
+	img.content = content
+	return report, nil
+}
+
+// SetOptimization makes ToHtml and ToBase64 optimize the svg according to opts
+// before writing it. The contents of img are not changed. Passing nil disables
+// the optimization, which is also the default.
+func (img *SvgImage) SetOptimization(opts *OptimizeOptions) {
+	img.optimize = opts
+}
+
+// outputContent returns the contents of img to be used for output. If
+// optimization has been enabled, the result is optimized.
+func (img *SvgImage) outputContent() []byte {
+	if img.optimize == nil {
+		return img.content
+	}
+	content, _, err := optimizeSvg(img.content, *img.optimize)
+	if err != nil {
+		return img.content
+	}
+	return content
+}
+
+// xmlElement is an element in an XML document. Unlike svgNode, names are kept
+// exactly as written, so namespace prefixes are preserved.
+type xmlElement struct {
+	name     xml.Name
+	attrs    []xml.Attr
+	children []any // Either *xmlElement or string
+}
+
+// xmlDocument is a parsed XML document.
+type xmlDocument struct {
+	prolog []xml.Token
+	root   *xmlElement
+}
+
+// textElements lists the elements in which character data is significant.
+var textElements = map[string]bool{
+	"text":     true,
+	"tspan":    true,
+	"textPath": true,
+	"title":    true,
+	"desc":     true,
+	"style":    true,
+	"script":   true,
+}
+
+// parseXmlDocument parses content, dropping comments, metadata, and whitespace
+// that is not part of a text element.
+func parseXmlDocument(content []byte) (*xmlDocument, error) {
+	doc := new(xmlDocument)
+	d := xml.NewDecoder(bytes.NewReader(content))
+
+	var stack []*xmlElement
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse svg: %v", err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			el := &xmlElement{name: t.Name, attrs: t.Copy().Attr}
+			if len(stack) == 0 {
+				if doc.root != nil {
+					return nil, fmt.Errorf("Failed to parse svg: Multiple root elements")
+				}
+				doc.root = el
+			} else {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, el)
+			}
+			stack = append(stack, el)
+		case xml.EndElement:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("Failed to parse svg: Unexpected end tag")
+			}
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) == 0 {
+				continue
+			}
+			parent := stack[len(stack)-1]
+			if textElements[parent.name.Local] || len(bytes.TrimSpace(t)) > 0 {
+				parent.children = append(parent.children, string(t))
+			}
+		case xml.ProcInst, xml.Directive:
+			if doc.root == nil {
+				doc.prolog = append(doc.prolog, xml.CopyToken(t))
+			}
+		}
+	}
+
+	if doc.root == nil {
+		return nil, fmt.Errorf("No svg element found")
+	}
+	doc.root.removeElements(func(el *xmlElement) bool { return el.name.Local == "metadata" })
+	return doc, nil
+}
+
+// qualifiedName returns the name as written in the document.
+func qualifiedName(n xml.Name) string {
+	if n.Space != "" {
+		return n.Space + ":" + n.Local
+	}
+	return n.Local
+}
+
+// write serializes doc.
+func (doc *xmlDocument) write(w *bytes.Buffer) {
+	for _, tok := range doc.prolog {
+		switch t := tok.(type) {
+		case xml.ProcInst:
+			fmt.Fprintf(w, "<?%s %s?>\n", t.Target, t.Inst)
+		case xml.Directive:
+			fmt.Fprintf(w, "<!%s>\n", t)
+		}
+	}
+	doc.root.write(w)
+}
+
+// write serializes el and its children.
+func (el *xmlElement) write(w *bytes.Buffer) {
+	fmt.Fprintf(w, "<%s", qualifiedName(el.name))
+	for _, a := range el.attrs {
+		fmt.Fprintf(w, ` %s="%s"`, qualifiedName(a.Name), html.EscapeString(a.Value))
+	}
+	if len(el.children) == 0 {
+		w.WriteString("/>")
+		return
+	}
+
+	w.WriteString(">")
+	for _, c := range el.children {
+		switch c := c.(type) {
+		case *xmlElement:
+			c.write(w)
+		case string:
+			xml.EscapeText(w, []byte(c))
+		}
+	}
+	fmt.Fprintf(w, "</%s>", qualifiedName(el.name))
+}
+
+// attr returns the value of the attribute with the given (unprefixed) name.
+func (el *xmlElement) attr(name string) (string, bool) {
+	for _, a := range el.attrs {
+		if a.Name.Local == name && (a.Name.Space == "" || name == "href") {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// setAttr sets the attribute name to value, adding it if necessary. An empty
+// value removes the attribute.
+func (el *xmlElement) setAttr(name, value string) {
+	for i, a := range el.attrs {
+		if a.Name.Space == "" && a.Name.Local == name {
+			if value == "" {
+				el.attrs = append(el.attrs[:i], el.attrs[i+1:]...)
+			} else {
+				el.attrs[i].Value = value
+			}
+			return
+		}
+	}
+	if value != "" {
+		el.attrs = append(el.attrs, xml.Attr{Name: xml.Name{Local: name}, Value: value})
+	}
+}
+
+// elements returns the child elements of el.
+func (el *xmlElement) elements() []*xmlElement {
+	var els []*xmlElement
+	for _, c := range el.children {
+		if c, ok := c.(*xmlElement); ok {
+			els = append(els, c)
+		}
+	}
+	return els
+}
+
+// walk calls f for el and all its descendants.
+func (el *xmlElement) walk(f func(*xmlElement)) {
+	f(el)
+	for _, c := range el.elements() {
+		c.walk(f)
+	}
+}
+
+// removeElements removes all descendants of el for which remove returns true.
+// The number of removed elements is returned.
+func (el *xmlElement) removeElements(remove func(*xmlElement) bool) int {
+	n := 0
+	kept := el.children[:0]
+	for _, c := range el.children {
+		if c, ok := c.(*xmlElement); ok {
+			if remove(c) {
+				n++
+				continue
+			}
+			n += c.removeElements(remove)
+		}
+		kept = append(kept, c)
+	}
+	el.children = kept
+	return n
+}
+
+// optimizeSvg optimizes the svg in content according to opts.
+func optimizeSvg(content []byte, opts OptimizeOptions) ([]byte, OptimizeReport, error) {
+	report := OptimizeReport{OriginalSize: len(content)}
+
+	doc, err := parseXmlDocument(content)
+	if err != nil {
+		return nil, OptimizeReport{}, err
+	}
+
+	if opts.ShareGlyphs {
+		report.MergedGlyphs = shareGlyphs(doc.root)
+	}
+	if opts.RemoveUnusedDefs {
+		report.RemovedDefs = removeUnusedDefs(doc.root)
+	}
+	if opts.CollapseTransforms {
+		if err := collapseTransforms(doc.root); err != nil {
+			return nil, OptimizeReport{}, err
+		}
+	}
+	if opts.Precision >= 0 {
+		// The dimensions of the root element are kept exact
+		for _, c := range doc.root.elements() {
+			c.walk(func(el *xmlElement) { roundAttrs(el, opts.Precision) })
+		}
+	}
+
+	var b bytes.Buffer
+	doc.write(&b)
+	report.OptimizedSize = b.Len()
+
+	return b.Bytes(), report, nil
+}
+
+var reIdRef = regexp.MustCompile(`#([^\s"'()]+)`)
+
+// references returns the ids referred to by the attributes of el.
+func (el *xmlElement) references() []string {
+	var refs []string
+	for _, a := range el.attrs {
+		switch {
+		case a.Name.Local == "href":
+			refs = append(refs, strings.TrimPrefix(a.Value, "#"))
+		case strings.HasPrefix(a.Name.Local, "aria-"):
+			refs = append(refs, strings.Fields(a.Value)...)
+		case strings.Contains(a.Value, "url("):
+			for _, m := range reIdRef.FindAllStringSubmatch(a.Value, -1) {
+				refs = append(refs, m[1])
+			}
+		}
+	}
+	return refs
+}
+
+var reCssIdRef = regexp.MustCompile(`#(-?[A-Za-z_][\w-]*)`)
+
+// styleReferences returns the ids referred to by the style sheet in el, either
+// as url(#id) or in selectors such as #id. Hexadecimal colours may be returned
+// as well, but this only means that definitions with such ids are kept.
+func (el *xmlElement) styleReferences() []string {
+	if el.name.Local != "style" {
+		return nil
+	}
+	var refs []string
+	for _, c := range el.children {
+		if text, ok := c.(string); ok {
+			for _, m := range reCssIdRef.FindAllStringSubmatch(text, -1) {
+				refs = append(refs, m[1])
+			}
+		}
+	}
+	return refs
+}
+
+// isDefs reports whether el only contains definitions.
+func isDefs(el *xmlElement) bool {
+	return el.name.Local == "defs"
+}
+
+// removeUnusedDefs removes definitions that are not referenced from the
+// rendered part of the document. The number of removed definitions is
+// returned.
+func removeUnusedDefs(root *xmlElement) int {
+	ids := make(map[string]*xmlElement)
+	root.walk(func(el *xmlElement) {
+		if id, ok := el.attr("id"); ok {
+			ids[id] = el
+		}
+	})
+
+	// Find references from outside the definitions
+	used := make(map[string]bool)
+	var queue []string
+	var visit func(el *xmlElement)
+	visit = func(el *xmlElement) {
+		if isDefs(el) {
+			return
+		}
+		queue = append(queue, el.references()...)
+		for _, c := range el.elements() {
+			visit(c)
+		}
+	}
+	visit(root)
+
+	// Style sheets apply to the rendered part even when placed in definitions
+	root.walk(func(el *xmlElement) { queue = append(queue, el.styleReferences()...) })
+
+	// Follow references from the used definitions
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if used[id] {
+			continue
+		}
+		used[id] = true
+		if el, ok := ids[id]; ok {
+			el.walk(func(d *xmlElement) { queue = append(queue, d.references()...) })
+		}
+	}
+
+	n := 0
+	root.walk(func(el *xmlElement) {
+		if !isDefs(el) {
+			return
+		}
+		n += el.removeElements(func(d *xmlElement) bool {
+			id, ok := d.attr("id")
+			return ok && !used[id] && !containsUsed(d, used)
+		})
+		// Remove groups left empty
+		el.removeElements(func(d *xmlElement) bool {
+			_, hasId := d.attr("id")
+			return d.name.Local == "g" && !hasId && len(d.children) == 0
+		})
+	})
+	root.removeElements(func(el *xmlElement) bool {
+		return isDefs(el) && len(el.children) == 0
+	})
+
+	return n
+}
+
+// containsUsed reports whether any descendant of el has a used id.
+func containsUsed(el *xmlElement, used map[string]bool) bool {
+	found := false
+	for _, c := range el.elements() {
+		c.walk(func(d *xmlElement) {
+			if id, ok := d.attr("id"); ok && used[id] {
+				found = true
+			}
+		})
+	}
+	return found
+}
+
+// shareGlyphs converts glyph definitions into symbols and merges identical
+// glyphs. The number of removed duplicates is returned.
+func shareGlyphs(root *xmlElement) int {
+	renamed := make(map[string]string)
+	seen := make(map[string]string)
+
+	root.walk(func(el *xmlElement) {
+		if !isDefs(el) {
+			return
+		}
+		el.walk(func(d *xmlElement) {
+			id, ok := d.attr("id")
+			if !ok || !strings.HasPrefix(id, "glyph") {
+				return
+			}
+			if d.name.Local == "g" {
+				d.name.Local = "symbol"
+				d.setAttr("overflow", "visible")
+			}
+
+			var b bytes.Buffer
+			for _, c := range d.elements() {
+				c.write(&b)
+			}
+			if first, ok := seen[b.String()]; ok {
+				renamed[id] = first
+			} else {
+				seen[b.String()] = id
+			}
+		})
+	})
+
+	if len(renamed) == 0 {
+		return 0
+	}
+
+	// Redirect references and remove duplicates
+	root.walk(func(el *xmlElement) {
+		for i, a := range el.attrs {
+			if a.Name.Local != "href" {
+				continue
+			}
+			if to, ok := renamed[strings.TrimPrefix(a.Value, "#")]; ok {
+				el.attrs[i].Value = "#" + to
+			}
+		}
+	})
+	return root.removeElements(func(el *xmlElement) bool {
+		id, ok := el.attr("id")
+		_, duplicate := renamed[id]
+		return ok && duplicate
+	})
+}
+
+// collapseTransforms combines chained transformations into a single one, and
+// merges groups whose only purpose is to transform a single child.
+func collapseTransforms(el *xmlElement) error {
+	for i, c := range el.children {
+		c, ok := c.(*xmlElement)
+		if !ok {
+			continue
+		}
+
+		// Merge a transforming group into its only child
+		for c.name.Local == "g" && len(c.attrs) == 1 && len(c.children) == 1 {
+			child, ok := c.children[0].(*xmlElement)
+			t, hasTransform := c.attr("transform")
+			if !ok || !hasTransform {
+				break
+			}
+			childT, _ := child.attr("transform")
+			child.setAttr("transform", strings.TrimSpace(t+" "+childT))
+			c = child
+		}
+		el.children[i] = c
+
+		if t, ok := c.attr("transform"); ok {
+			m, err := parseTransform(t)
+			if err != nil {
+				return err
+			}
+			c.setAttr("transform", formatTransform(m))
+		}
+
+		if err := collapseTransforms(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatTransform writes m as a transform attribute using the shortest form.
+func formatTransform(m matrix) string {
+	f := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	switch {
+	case m == identity:
+		return ""
+	case m[0] == 1 && m[1] == 0 && m[2] == 0 && m[3] == 1:
+		return fmt.Sprintf("translate(%s,%s)", f(m[4]), f(m[5]))
+	case m[1] == 0 && m[2] == 0 && m[4] == 0 && m[5] == 0:
+		return fmt.Sprintf("scale(%s,%s)", f(m[0]), f(m[3]))
+	}
+	return fmt.Sprintf("matrix(%s,%s,%s,%s,%s,%s)", f(m[0]), f(m[1]), f(m[2]), f(m[3]), f(m[4]), f(m[5]))
+}
+
+// numericAttrs lists the attributes whose numbers may be rounded.
+var numericAttrs = map[string]bool{
+	"d": true, "points": true, "transform": true, "viewBox": true,
+	"x": true, "y": true, "x1": true, "y1": true, "x2": true, "y2": true,
+	"cx": true, "cy": true, "r": true, "rx": true, "ry": true,
+	"width": true, "height": true, "stroke-width": true,
+	"stroke-dasharray": true, "stroke-dashoffset": true, "style": true,
+}
+
+// roundAttrs rounds the numbers in the numerical attributes of el.
+func roundAttrs(el *xmlElement, precision int) {
+	scale := math.Pow(10, float64(precision))
+	round := func(s string) string {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return s
+		}
+		r := strconv.FormatFloat(math.Round(f*scale)/scale, 'f', -1, 64)
+		if r == "-0" {
+			return "0"
+		}
+		return r
+	}
+
+	for i, a := range el.attrs {
+		if a.Name.Space != "" || !numericAttrs[a.Name.Local] {
+			continue
+		}
+		if a.Name.Local == "transform" {
+			// Keep the linear part of matrices exact, as rounding it would
+			// distort glyphs. Only translations are rounded.
+			if strings.HasPrefix(a.Value, "translate(") {
+				el.attrs[i].Value = reNumber.ReplaceAllStringFunc(a.Value, round)
+			}
+			continue
+		}
+		el.attrs[i].Value = reNumber.ReplaceAllStringFunc(a.Value, round)
+	}
+}
@@ -0,0 +1,126 @@
+package graphics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	img, err := SvgFromBytes([]byte(exampleCairoSvg))
+	if err != nil {
+		t.Fatalf("Reading svg caused error: %s", err)
+	}
+
+	report, err := img.Optimize(DefaultOptimizeOptions())
+	if err != nil {
+		t.Fatalf("Optimizing caused error: %s", err)
+	}
+	out := string(img.content)
+
+	if report.OriginalSize != len(exampleCairoSvg) || report.OptimizedSize != len(out) {
+		t.Errorf("Report %+v does not match sizes %d and %d", report, len(exampleCairoSvg), len(out))
+	}
+	if report.Savings() <= 0 {
+		t.Errorf("Optimization did not reduce size: %s", report)
+	}
+	if report.RemovedDefs != 1 || strings.Contains(out, "clip1") {
+		t.Errorf("Unused clip path was not removed:\n%s", out)
+	}
+	for _, v := range []string{
+		`<?xml version="1.0" encoding="UTF-8"?>`,
+		`xmlns:xlink="http://www.w3.org/1999/xlink"`,
+		`<symbol overflow="visible" id="glyph0-0">`,
+		`<use xlink:href="#glyph0-0" x="100" y="80"/>`,
+		`transform="translate(10,0)"`,
+	} {
+		if !strings.Contains(out, v) {
+			t.Errorf("Optimized svg does not contain %s:\n%s", v, out)
+		}
+	}
+
+	// The content is drawn at the same position
+	before, _ := parseSvgTree([]byte(exampleCairoSvg))
+	after, err := parseSvgTree(img.content)
+	if err != nil {
+		t.Fatalf("Parsing optimized svg caused error: %s", err)
+	}
+	b1, _ := contentBounds(before)
+	b2, err := contentBounds(after)
+	if err != nil {
+		t.Fatalf("Computing bounds of optimized svg caused error: %s", err)
+	}
+	if b1 != b2 {
+		t.Errorf("Bounds changed from %v to %v", b1, b2)
+	}
+}
+
+func TestOptimizeGlyphsAndPrecision(t *testing.T) {
+	svg := `<svg xmlns:xlink="http://www.w3.org/1999/xlink" width="10.12345pt" height="5pt">` +
+		`<!-- pdf2svg output --><metadata>Produced by test</metadata>` +
+		`<defs><g><g id="glyph0-1"><path d="M 0.123456 -1.5 L 2 2 Z"/></g>` +
+		`<g id="glyph1-1"><path d="M 0.123456 -1.5 L 2 2 Z"/></g></g></defs>` +
+		`<g transform="translate(1,2)"><g transform="scale(2)"><use xlink:href="#glyph1-1" x="1.004" y="-0.001"/></g></g>` +
+		`<text x="0" y="0">  A  </text></svg>`
+	expected := `<svg xmlns:xlink="http://www.w3.org/1999/xlink" width="10.12345pt" height="5pt">` +
+		`<defs><g><symbol id="glyph0-1" overflow="visible"><path d="M 0.12 -1.5 L 2 2 Z"/></symbol></g></defs>` +
+		`<use xlink:href="#glyph0-1" x="1" y="0" transform="matrix(2,0,0,2,1,2)"/>` +
+		`<text x="0" y="0">  A  </text></svg>`
+
+	out, report, err := optimizeSvg([]byte(svg), DefaultOptimizeOptions())
+	if err != nil {
+		t.Fatalf("Optimizing caused error: %s", err)
+	}
+	if string(out) != expected {
+		t.Errorf("Expected\n%s\nbut got\n%s", expected, out)
+	}
+	if report.MergedGlyphs != 1 {
+		t.Errorf("Expected 1 merged glyph, but got %d", report.MergedGlyphs)
+	}
+}
+
+func TestAutoOptimize(t *testing.T) {
+	img, err := SvgFromBytes([]byte(exampleCairoSvg))
+	if err != nil {
+		t.Fatalf("Reading svg caused error: %s", err)
+	}
+
+	var plain, optimized strings.Builder
+	img.ToBase64(&plain)
+	opts := DefaultOptimizeOptions()
+	img.SetOptimization(&opts)
+	img.ToBase64(&optimized)
+
+	if optimized.Len() >= plain.Len() {
+		t.Errorf("Optimization was not applied to base64 output")
+	}
+	if string(img.content) != exampleCairoSvg {
+		t.Errorf("Automatic optimization changed the contents of the image")
+	}
+}
+
+func TestOptimizeKeepsStyleReferences(t *testing.T) {
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" width="10pt" height="10pt" viewBox="0 0 10 10">
+<defs>
+<style>.shaded { fill: url(#grad1); } #marker2 { stroke: #ff0000; }</style>
+<linearGradient id="grad1"><stop offset="0" stop-color="red"/></linearGradient>
+<linearGradient id="grad2"><stop offset="0" stop-color="blue"/></linearGradient>
+</defs>
+<rect class="shaded" width="10" height="10"/>
+<circle id="marker2" r="2"/>
+</svg>`
+	img, err := SvgFromBytes([]byte(svg))
+	if err != nil {
+		t.Fatalf("Reading svg caused error: %s", err)
+	}
+	report, err := img.Optimize(DefaultOptimizeOptions())
+	if err != nil {
+		t.Fatalf("Optimizing caused error: %s", err)
+	}
+	out := string(img.content)
+	if !strings.Contains(out, `id="grad1"`) {
+		t.Errorf("Gradient referenced from CSS was removed:\n%s", out)
+	}
+	if report.RemovedDefs != 1 || strings.Contains(out, `id="grad2"`) {
+		t.Errorf("Unused gradient was not removed:\n%s", out)
+	}
+}
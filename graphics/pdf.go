@@ -0,0 +1,134 @@
+package graphics
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// pdfSource is the PDF compiled by LaTeX from which an SvgImage was converted.
+type pdfSource struct {
+	content []byte
+	page    int // The page containing the image, starting from 1
+	nPages  int
+}
+
+// attachPdf records the PDF at path as the source of svgs. The i'th image must
+// be converted from page i+1.
+func attachPdf(svgs []*SvgImage, path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	for i, v := range svgs {
+		v.pdf = &pdfSource{
+			content: content,
+			page:    i + 1,
+			nPages:  len(svgs),
+		}
+	}
+	return nil
+}
+
+// ToPdf writes img to w as a single-page PDF, e.g. for including it in a LaTeX
+// document. Images compiled from TikZ use the PDF produced by LaTeX, so the
+// output matches the original figure exactly. The PDF keeps the original size
+// if img is scaled, but it is not used once img has been cropped or its aspect
+// ratio changed. Other images are converted using rsvg-convert or, if that
+// fails, Inkscape.
+func (img *SvgImage) ToPdf(w io.Writer) error {
+	if img.pdf == nil {
+		return convertSvgToPdf(img.outputContent(), w)
+	}
+
+	content := img.pdf.content
+	if img.pdf.nPages > 1 {
+		var err error
+		content, err = extractPdfPage(content, img.pdf.page)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(content)
+	return err
+}
+
+// extractPdfPage returns the given page of the PDF with the specified content
+// as a separate PDF. The page is extracted using pdflatex.
+func extractPdfPage(content []byte, page int) ([]byte, error) {
+	tmpDir, err := os.MkdirTemp("", "moodleTikz-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	if err := os.WriteFile(filepath.Join(tmpDir, "source.pdf"), content, 0o644); err != nil {
+		return nil, err
+	}
+
+	document := fmt.Sprintf(`\documentclass{standalone}
+\usepackage{graphicx}
+\begin{document}
+\includegraphics[page=%d]{source.pdf}
+\end{document}`, page)
+	path, err := runLatex(PdfLatex, document, tmpDir, "page", tmpDir, 0)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+// convertSvgToPdf converts the svg with the given content into a PDF and writes
+// it to w. Either rsvg-convert or Inkscape is used for the conversion.
+func convertSvgToPdf(content []byte, w io.Writer) error {
+	tmpDir, err := os.MkdirTemp("", "moodleTikz-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	svgPath := filepath.Join(tmpDir, "image.svg")
+	pdfPath := filepath.Join(tmpDir, "image.pdf")
+	if err := os.WriteFile(svgPath, content, 0o644); err != nil {
+		return err
+	}
+
+	err1 := exec.Command("rsvg-convert", "-f", "pdf", "-o", pdfPath, svgPath).Run()
+	if err1 != nil {
+		err2 := inkscapeToPdf(svgPath, pdfPath)
+		if err2 != nil {
+			return fmt.Errorf("rsvg-convert failed. Error message was: %s\n%s", err1, err2)
+		}
+	}
+
+	pdf, err := os.ReadFile(pdfPath)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(pdf)
+	return err
+}
+
+// inkscapeToPdf calls Inkscape to convert the svg at svgPath into a PDF at
+// pdfPath.
+func inkscapeToPdf(svgPath, pdfPath string) error {
+	version, err := inkscapeVersion()
+	if err != nil {
+		return fmt.Errorf("inkscape: %v", err)
+	}
+
+	var cmd *exec.Cmd
+	// The export options were renamed in Inkscape 1.0
+	if version[0] >= 1 {
+		cmd = exec.Command("inkscape", "--export-type=pdf", "--export-filename="+pdfPath, svgPath)
+	} else {
+		cmd = exec.Command("inkscape", "--export-pdf="+pdfPath, svgPath)
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("inkscape: %v", err)
+	}
+	return nil
+}
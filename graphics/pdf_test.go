@@ -0,0 +1,66 @@
+package graphics
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestToPdf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tikz.pdf")
+	if err := os.WriteFile(path, []byte("%PDF-1.5"), 0o644); err != nil {
+		t.Fatalf("Writing test PDF caused error: %s", err)
+	}
+
+	img, _ := SvgFromBytes([]byte(`<svg width="10pt" height="10pt"></svg>`))
+	if err := attachPdf([]*SvgImage{img}, path); err != nil {
+		t.Fatalf("Attaching PDF caused error: %s", err)
+	}
+	if img.pdf.page != 1 || img.pdf.nPages != 1 {
+		t.Errorf("Unexpected PDF source %+v", img.pdf)
+	}
+
+	var b bytes.Buffer
+	if err := img.ToPdf(&b); err != nil {
+		t.Fatalf("Writing PDF caused error: %s", err)
+	}
+	if b.String() != "%PDF-1.5" {
+		t.Errorf("Expected the attached PDF, but got %q", b.String())
+	}
+}
+
+func TestToPdfAfterChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tikz.pdf")
+	if err := os.WriteFile(path, []byte("%PDF-1.5 uncropped"), 0o644); err != nil {
+		t.Fatalf("Writing test PDF caused error: %s", err)
+	}
+	svg := `<svg width="20pt" height="10pt" viewBox="0 0 20 10"><rect x="5" y="2" width="10" height="5"/></svg>`
+
+	// Uniform scaling keeps the PDF
+	img, _ := SvgFromBytes([]byte(svg))
+	attachPdf([]*SvgImage{img}, path)
+	img.Scale(2)
+	if img.pdf == nil {
+		t.Errorf("Scaling removed the attached PDF")
+	}
+	img.ResizeTo(40, 40, Pt)
+	if img.pdf != nil {
+		t.Errorf("Changing the aspect ratio kept the attached PDF")
+	}
+
+	img, _ = SvgFromBytes([]byte(svg))
+	attachPdf([]*SvgImage{img}, path)
+	if err := img.CropToContent(); err != nil {
+		t.Fatalf("Cropping caused error: %s", err)
+	}
+	if img.pdf != nil {
+		t.Errorf("Cropping kept the attached PDF")
+	}
+
+	// The cropped svg is converted instead, which fails without converters
+	var b bytes.Buffer
+	if err := img.ToPdf(&b); err == nil && bytes.Contains(b.Bytes(), []byte("uncropped")) {
+		t.Errorf("Cropped image returned the uncropped PDF")
+	}
+}
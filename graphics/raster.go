@@ -0,0 +1,138 @@
+package graphics
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ToPng rasterizes img into a PNG image with the given resolution. The
+// resolution is relative to CSS pixels (i.e. 1/96 inch), so a resolution of 96
+// dpi produces one image pixel per CSS pixel. The resulting image reports the
+// size of img in px, and this is used when the image is converted to HTML.
+//
+// Images compiled from TikZ are rasterized from the PDF produced by LaTeX
+// using pdftocairo. Other images require rsvg-convert (part of librsvg) to be
+// installed.
+func (img *SvgImage) ToPng(dpi float64) (*BinaryImage, error) {
+	if dpi <= 0 {
+		return nil, fmt.Errorf("Resolution must be positive, but received %f", dpi)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "moodleTikz-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	pxDim, err := img.DimensionIn(Px)
+	if err != nil {
+		return nil, err
+	}
+	size := pngSize(pxDim, dpi)
+	pngPath := filepath.Join(tmpDir, "tmp.png")
+	if img.pdf != nil {
+		pdfPath := filepath.Join(tmpDir, "tmp.pdf")
+		if err := os.WriteFile(pdfPath, img.pdf.content, 0o644); err != nil {
+			return nil, err
+		}
+		args := pdftocairoPngArgs(pdfPath, img.pdf.page, size, strings.TrimSuffix(pngPath, ".png"))
+		if err := exec.Command("pdftocairo", args...).Run(); err != nil {
+			return nil, fmt.Errorf("pdftocairo failed. Error message was: %s", err)
+		}
+	} else {
+		svgPath := filepath.Join(tmpDir, "tmp.svg")
+		if err := os.WriteFile(svgPath, img.outputContent(), 0o644); err != nil {
+			return nil, err
+		}
+		err := exec.Command(
+			"rsvg-convert", "-f", "png",
+			"-w", strconv.Itoa(size[0]), "-h", strconv.Itoa(size[1]),
+			"-o", pngPath, svgPath,
+		).Run()
+		if err != nil {
+			return nil, fmt.Errorf("rsvg-convert failed. Error message was: %s", err)
+		}
+	}
+
+	return pngFromFile(pngPath, pxDim, dpi)
+}
+
+// pngSize returns the size in pixels of a PNG showing an image with dimensions
+// dim (in CSS pixels) at the given resolution.
+func pngSize(dim [2]float64, dpi float64) [2]int {
+	return [2]int{
+		max(1, int(math.Round(dim[0]*dpi/96))),
+		max(1, int(math.Round(dim[1]*dpi/96))),
+	}
+}
+
+// pdftocairoPngArgs returns the arguments for pdftocairo converting the given
+// page of a PDF to a PNG with the given size in pixels. The PNG is written to
+// outPrefix with the extension .png.
+func pdftocairoPngArgs(pdfPath string, page int, size [2]int, outPrefix string) []string {
+	return []string{
+		"-png",
+		"-singlefile",
+		"-f", strconv.Itoa(page),
+		"-l", strconv.Itoa(page),
+		"-scale-to-x", strconv.Itoa(size[0]),
+		"-scale-to-y", strconv.Itoa(size[1]),
+		pdfPath,
+		outPrefix,
+	}
+}
+
+// PngFromTikz compiles a TikZ- or pgfplots-environment into a PNG image with
+// the given resolution. The tmpDir argument is handled as in SvgFromTikz. If
+// s contains several pages, only the first one is converted.
+//
+// The dimensions reported by the resulting image match those of the SvgImage
+// produced by SvgFromTikz. Conversion requires pdftocairo to be installed.
+func PngFromTikz(s string, dpi float64, tmpDir string) (*BinaryImage, error) {
+	return NewTikzCompiler().PngFromTikz(s, dpi, tmpDir)
+}
+
+// PngFromTikz compiles a TikZ- or pgfplots-environment into a PNG image using
+// the settings of tc. See the function PngFromTikz for details.
+func (tc *TikzCompiler) PngFromTikz(s string, dpi float64, tmpDir string) (*BinaryImage, error) {
+	if dpi <= 0 {
+		return nil, fmt.Errorf("Resolution must be positive, but received %f", dpi)
+	}
+
+	img, err := tc.SvgFromTikz(s, tmpDir)
+	if err != nil {
+		return nil, err
+	}
+	return img.ToPng(dpi)
+}
+
+// pngFromFile reads a PNG file into a BinaryImage with the given dimensions.
+// If dim is zero, the dimensions are computed from the size of the PNG and its
+// resolution.
+func pngFromFile(path string, dim [2]float64, dpi float64) (*BinaryImage, error) {
+	content, err := fileAsBytes(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := png.DecodeConfig(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("Conversion produced an invalid PNG: %v", err)
+	}
+	if dim == [2]float64{} {
+		dim = [2]float64{float64(cfg.Width) * 96 / dpi, float64(cfg.Height) * 96 / dpi}
+	}
+
+	return &BinaryImage{
+		content:   content,
+		extension: "png",
+		dim:       dim,
+	}, nil
+}
@@ -0,0 +1,120 @@
+package graphics
+
+import (
+	"bytes"
+	"image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPngFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "everest.png")
+	if err := os.WriteFile(path, pngExample, 0o644); err != nil {
+		t.Fatalf("Writing test file caused error: %s", err)
+	}
+
+	img, err := pngFromFile(path, [2]float64{}, 192)
+	if err != nil {
+		t.Fatalf("Reading png caused error: %s", err)
+	}
+
+	// Doubling the resolution halves the displayed size
+	cfgImg, _ := pngFromFile(path, [2]float64{}, 96)
+	dim := cfgImg.GetDimension()
+	if img.GetDimension() != [2]float64{dim[0] / 2, dim[1] / 2} {
+		t.Errorf("Expected dimensions %v, but got %v", [2]float64{dim[0] / 2, dim[1] / 2}, img.GetDimension())
+	}
+
+	var b strings.Builder
+	img.ToHtml(&b)
+	if !strings.Contains(b.String(), `width="`) {
+		t.Errorf("HTML output does not contain the image dimensions")
+	}
+
+	if _, err := pngFromFile(path, [2]float64{10, 10}, 96); err != nil {
+		t.Errorf("Reading png with given dimensions caused error: %s", err)
+	}
+
+	if err := os.WriteFile(path, jpegExample, 0o644); err != nil {
+		t.Fatalf("Writing test file caused error: %s", err)
+	}
+	if _, err := pngFromFile(path, [2]float64{}, 96); err == nil {
+		t.Errorf("Reading invalid png failed to return an error")
+	}
+}
+
+func TestPngSize(t *testing.T) {
+	if size := pngSize([2]float64{75, 30.2}, 192); size != [2]int{150, 60} {
+		t.Errorf("Expected size [150 60], but got %v", size)
+	}
+	if size := pngSize([2]float64{0.1, 0.1}, 96); size != [2]int{1, 1} {
+		t.Errorf("Tiny image gave size %v", size)
+	}
+
+	args := strings.Join(pdftocairoPngArgs("in.pdf", 3, [2]int{150, 60}, "out"), " ")
+	if want := "-png -singlefile -f 3 -l 3 -scale-to-x 150 -scale-to-y 60 in.pdf out"; args != want {
+		t.Errorf("Expected arguments %q, but got %q", want, args)
+	}
+}
+
+// minimalPdf is a single-page PDF of 15pt by 7.5pt.
+const minimalPdf = `%PDF-1.4
+1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj
+2 0 obj << /Type /Pages /Kids [3 0 R] /Count 1 >> endobj
+3 0 obj << /Type /Page /Parent 2 0 R /MediaBox [0 0 15 7.5] >> endobj
+trailer << /Root 1 0 R >>
+%%EOF
+`
+
+func TestToPng(t *testing.T) {
+	// 15pt x 7.5pt is 20px x 10px
+	img, _ := SvgFromBytes([]byte(`<svg xmlns="http://www.w3.org/2000/svg" width="15pt" height="7.5pt" viewBox="0 0 20 10"><rect width="20" height="10"/></svg>`))
+	if _, err := img.ToPng(0); err == nil {
+		t.Errorf("Non-positive resolution failed to return an error")
+	}
+
+	if _, err := exec.LookPath("rsvg-convert"); err != nil {
+		t.Log("rsvg-convert is not installed; skipping conversion of plain svg")
+	} else {
+		raster, err := img.ToPng(192)
+		if err != nil {
+			t.Fatalf("Converting svg caused error: %s", err)
+		}
+		checkPngSize(t, raster, [2]int{40, 20})
+		if pxDim, _ := img.DimensionIn(Px); raster.GetDimension() != pxDim {
+			t.Errorf("PNG reports dimensions %v, but the svg is %v px", raster.GetDimension(), pxDim)
+		}
+	}
+
+	if _, err := exec.LookPath("pdftocairo"); err != nil {
+		t.Skip("pdftocairo is not installed")
+	}
+	path := filepath.Join(t.TempDir(), "tikz.pdf")
+	if err := os.WriteFile(path, []byte(minimalPdf), 0o644); err != nil {
+		t.Fatalf("Writing test PDF caused error: %s", err)
+	}
+	if err := attachPdf([]*SvgImage{img}, path); err != nil {
+		t.Fatalf("Attaching PDF caused error: %s", err)
+	}
+	raster, err := img.ToPng(96)
+	if err != nil {
+		t.Fatalf("Converting attached PDF caused error: %s", err)
+	}
+	checkPngSize(t, raster, [2]int{20, 10})
+}
+
+// checkPngSize reports an error if the PNG does not have the given size in
+// pixels.
+func checkPngSize(t *testing.T, img *BinaryImage, size [2]int) {
+	t.Helper()
+	cfg, err := png.DecodeConfig(bytes.NewReader(img.content))
+	if err != nil {
+		t.Fatalf("Decoding png caused error: %s", err)
+	}
+	if got := [2]int{cfg.Width, cfg.Height}; got != size {
+		t.Errorf("Expected a png of %v pixels, but got %v", size, got)
+	}
+}
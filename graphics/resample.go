@@ -0,0 +1,155 @@
+package graphics
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+)
+
+// Shrink reduces the size of img such that it fits within maxWidth x maxHeight
+// pixels, preserving its aspect ratio. If either bound is zero, that dimension
+// is unrestricted. Images that already fit are left unchanged.
+//
+// Shrinking is supported for png, jpeg, and gif images. Jpeg images are
+// re-encoded with the given quality (between 1 and 100), while other images are
+// converted to png.
+func (img *BinaryImage) Shrink(maxWidth, maxHeight int, quality int) error {
+	if maxWidth < 0 || maxHeight < 0 {
+		return fmt.Errorf("Invalid maximal size %d x %d", maxWidth, maxHeight)
+	}
+
+	src, err := img.decode()
+	if err != nil {
+		return err
+	}
+
+	size := src.Bounds().Size()
+	scale := 1.0
+	if maxWidth > 0 {
+		scale = min(scale, float64(maxWidth)/float64(size.X))
+	}
+	if maxHeight > 0 {
+		scale = min(scale, float64(maxHeight)/float64(size.Y))
+	}
+	if scale >= 1 {
+		return nil
+	}
+
+	dst := downscale(src,
+		max(1, int(math.Round(float64(size.X)*scale))),
+		max(1, int(math.Round(float64(size.Y)*scale))),
+	)
+
+	if img.extension == "jpeg" {
+		return img.encodeJpeg(dst, quality)
+	}
+	return img.encodePng(dst)
+}
+
+// ConvertToJpeg re-encodes img as a jpeg image with the given quality (between
+// 1 and 100). This can significantly reduce the size of photos stored in other
+// formats. Transparent areas are replaced by white.
+func (img *BinaryImage) ConvertToJpeg(quality int) error {
+	src, err := img.decode()
+	if err != nil {
+		return err
+	}
+	return img.encodeJpeg(src, quality)
+}
+
+// decode converts the contents of img into an image.Image.
+func (img *BinaryImage) decode() (image.Image, error) {
+	switch img.extension {
+	case "png", "jpeg", "gif":
+	default:
+		return nil, fmt.Errorf("Decoding %s images is not supported", img.extension)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(img.content))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to decode image: %v", err)
+	}
+	return src, nil
+}
+
+// encodeJpeg replaces the contents of img by m encoded as jpeg.
+func (img *BinaryImage) encodeJpeg(m image.Image, quality int) error {
+	if quality < 1 || quality > 100 {
+		return fmt.Errorf("Quality must be between 1 and 100, but received %d", quality)
+	}
+
+	// Jpeg does not support transparency, so draw on a white background
+	opaque := image.NewRGBA(m.Bounds())
+	draw.Draw(opaque, opaque.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(opaque, opaque.Bounds(), m, m.Bounds().Min, draw.Over)
+
+	var b bytes.Buffer
+	if err := jpeg.Encode(&b, opaque, &jpeg.Options{Quality: quality}); err != nil {
+		return err
+	}
+
+	img.setEncoded(b.Bytes(), "jpeg", m.Bounds().Size())
+	return nil
+}
+
+// encodePng replaces the contents of img by m encoded as png.
+func (img *BinaryImage) encodePng(m image.Image) error {
+	var b bytes.Buffer
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := enc.Encode(&b, m); err != nil {
+		return err
+	}
+
+	img.setEncoded(b.Bytes(), "png", m.Bounds().Size())
+	return nil
+}
+
+// setEncoded updates img with newly encoded contents of the given size.
+func (img *BinaryImage) setEncoded(content []byte, extension string, size image.Point) {
+	img.content = content
+	img.extension = extension
+	img.dim = [2]float64{float64(size.X), float64(size.Y)}
+}
+
+// downscale resizes src to width x height pixels by averaging the source pixels
+// covered by each destination pixel.
+func downscale(src image.Image, width, height int) *image.NRGBA {
+	b := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	sx := float64(b.Dx()) / float64(width)
+	sy := float64(b.Dy()) / float64(height)
+
+	for y := range height {
+		y0 := b.Min.Y + int(float64(y)*sy)
+		y1 := max(y0+1, b.Min.Y+int(float64(y+1)*sy))
+		for x := range width {
+			x0 := b.Min.X + int(float64(x)*sx)
+			x1 := max(x0+1, b.Min.X+int(float64(x+1)*sx))
+
+			// Sum premultiplied colors to weigh by transparency
+			var r, g, bl, a, n uint64
+			for py := y0; py < y1; py++ {
+				for px := x0; px < x1; px++ {
+					pr, pg, pb, pa := src.At(px, py).RGBA()
+					r, g, bl, a = r+uint64(pr), g+uint64(pg), bl+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+
+			c := color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(bl / n),
+				A: uint16(a / n),
+			}
+			dst.Set(x, y, c)
+		}
+	}
+
+	return dst
+}
@@ -2,30 +2,32 @@ package graphics
 
 import (
 	"bytes"
-	_ "embed"
 	"encoding/base64"
 	"fmt"
+	"hash/fnv"
+	"html"
 	"io"
+	"math"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 )
 
 type SvgImage struct {
-	content []byte
-	dim     [2]float64
+	description
+	content  []byte
+	dim      [2]float64
+	unit     Unit
+	optimize *OptimizeOptions
+	zones    map[string][2]float64 // Positions in user coordinates
+	pdf      *pdfSource
 }
 
 var _ Image = (*SvgImage)(nil) // Ensure that interface is satisfied
 
-//go:embed preamble.tex
-var preamble string
-
-var svgDims = regexp.MustCompile(`((?:width|height)="([0-9.]+))(?:pt|px)?`)
-
 // SvgFromFile reads an svg file into memory.
 func SvgFromFile(path string) (*SvgImage, error) {
 	// Read svg contents into memory
@@ -34,68 +36,44 @@ func SvgFromFile(path string) (*SvgImage, error) {
 		return nil, err
 	}
 
-	// Extract width and height
-	w, h := 0.0, 0.0
-	matches := svgDims.FindAllSubmatch(content, 2)
-	for _, v := range matches {
-		if bytes.HasPrefix(v[0], []byte("width")) {
-			w, err = strconv.ParseFloat(string(v[2]), 0)
-		} else {
-			h, err = strconv.ParseFloat(string(v[2]), 0)
-		}
-	}
-	if w == 0 || h == 0 || err != nil {
-		return nil, fmt.Errorf("Failed to extract dimensions of svg.")
+	return SvgFromBytes(content)
+}
+
+// SvgFromBytes creates an SvgImage from the contents of an svg file. An error
+// is returned if the dimensions of the image cannot be determined from the
+// root element.
+func SvgFromBytes(content []byte) (*SvgImage, error) {
+	dim, unit, err := svgDimensions(content)
+	if err != nil {
+		return nil, err
 	}
 
 	return &SvgImage{
 		content: content,
-		dim:     [2]float64{w, h},
+		dim:     dim,
+		unit:    unit,
 	}, nil
 }
 
-// SvgFromTikz compiles a TikZ- or pfgplots-environment into an SvgImage.
-// Intermediate results will be stored in tmpDir. If this argument is "", then
-// a temporary folder will be created and deleted automatically. If tmpDir is
-// specified, the called is responsible for deletion.
-func SvgFromTikz(s string, tmpDir string) (*SvgImage, error) {
-	svg, err := SvgFromMultipageTikz(s, tmpDir)
-	if svg != nil {
-		return svg[0], err
-	}
-
-	return nil, err
+// GetDimension returns the width and height of img as encoded in the svg file.
+// The values are measured in the unit returned by Unit.
+func (img *SvgImage) GetDimension() [2]float64 {
+	return img.dim
 }
 
-func SvgFromMultipageTikz(s string, tmpDir string) ([]*SvgImage, error) {
-	if tmpDir == "" {
-		var err error
-		tmpDir, err = os.MkdirTemp("", "moodleTikz-*")
-		if err != nil {
-			return nil, err
-		}
-		defer os.RemoveAll(tmpDir)
-	}
+// Unit returns the unit of the dimensions of img.
+func (img *SvgImage) Unit() Unit {
+	return img.unit
+}
 
-	svgPath, err := compileToSvg(s, tmpDir)
+// DimensionIn returns the width and height of img converted to the given unit.
+func (img *SvgImage) DimensionIn(unit Unit) ([2]float64, error) {
+	w, err := ConvertLength(img.dim[0], img.unit, unit)
 	if err != nil {
-		return nil, err
+		return [2]float64{}, err
 	}
-
-	svgs := make([]*SvgImage, len(svgPath), len(svgPath))
-	for i, v := range svgPath {
-		svgs[i], err = SvgFromFile(v)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	return svgs, nil
-}
-
-// GetDimension returns the width and height of img as encoded in the svg file.
-func (img *SvgImage) GetDimension() [2]float64 {
-	return img.dim
+	h, _ := ConvertLength(img.dim[1], img.unit, unit)
+	return [2]float64{w, h}, nil
 }
 
 // Scale changes the size of img by the given scaling factor. An error is
@@ -105,51 +83,120 @@ func (img *SvgImage) Scale(factor float64) error {
 		return fmt.Errorf("Scaling factor must be positive, but received %f", factor)
 	}
 
-	img.dim[0] *= factor
-	img.dim[1] *= factor
+	return img.ResizeTo(img.dim[0]*factor, img.dim[1]*factor, img.unit)
+}
+
+// ResizeTo changes the size of img to the given width and height measured in
+// unit. If either width or height is zero, it is chosen such that the aspect
+// ratio of img is preserved.
+func (img *SvgImage) ResizeTo(width, height float64, unit Unit) error {
+	if _, ok := pxPerUnit[unit]; !ok {
+		return fmt.Errorf("Unsupported unit %q", unit)
+	}
+	if width < 0 || height < 0 || (width == 0 && height == 0) {
+		return fmt.Errorf("Invalid size %f x %f", width, height)
+	}
+
+	if width == 0 {
+		width = height * img.dim[0] / img.dim[1]
+	} else if height == 0 {
+		height = width * img.dim[1] / img.dim[0]
+	}
+
+	attrs := map[string]string{
+		"width":  formatSvgNumber(width) + string(unit),
+		"height": formatSvgNumber(height) + string(unit),
+	}
 
-	matches := svgDims.FindAllSubmatch(img.content, 2)
-	for _, v := range matches {
-		if bytes.HasPrefix(v[0], []byte("width")) {
-			img.content = bytes.Replace(img.content, v[0], fmt.Appendf([]byte{}, `width="%.1fpx`, img.dim[0]), 1)
-		} else {
-			img.content = bytes.Replace(img.content, v[0], fmt.Appendf([]byte{}, `height="%.1fpx`, img.dim[1]), 1)
+	// Ensure that the contents are scaled along with the image
+	root, err := findSvgRoot(img.content)
+	if err != nil {
+		return err
+	}
+	if _, ok := root.viewBox(); !ok {
+		pxDim, err := img.DimensionIn(Px)
+		if err != nil {
+			return err
 		}
+		attrs["viewBox"] = fmt.Sprintf("0 0 %s %s",
+			formatSvgNumber(pxDim[0]), formatSvgNumber(pxDim[1]))
 	}
 
+	content, err := setRootAttrs(img.content, attrs)
+	if err != nil {
+		return err
+	}
+
+	// The attached PDF can still be scaled to the new size, unless the aspect
+	// ratio has changed
+	if math.Abs(width*img.dim[1]-height*img.dim[0]) > 1e-9*width*img.dim[1] {
+		img.pdf = nil
+	}
+	img.content = content
+	img.dim = [2]float64{width, height}
+	img.unit = unit
+
 	return nil
 }
 
 // CropToContent will crop the image size to match the svg contents.
+// The bounding box of the contents is computed directly from the svg. If this
+// fails, Inkscape is used instead (provided that it is installed).
 func (img *SvgImage) CropToContent() error {
-	// Create temporary folder
-	tmpDir, err := os.MkdirTemp("", "moodleTikz-*")
+	err := img.cropNative()
+	if err == nil {
+		return nil
+	}
+
+	if _, lookErr := exec.LookPath("inkscape"); lookErr != nil {
+		return err
+	}
+	return img.cropWithInkscape()
+}
+
+// cropNative crops img by computing the bounding box of its contents and
+// adjusting the viewBox of the root element accordingly.
+func (img *SvgImage) cropNative() error {
+	tree, err := parseSvgTree(img.content)
 	if err != nil {
 		return err
 	}
-	defer os.RemoveAll(tmpDir)
 
-	// Write image to disk
-	path := filepath.Join(tmpDir, "tmp.svg")
-	file, err := os.Create(path)
+	b, err := contentBounds(tree)
 	if err != nil {
 		return err
 	}
-	file.Write(img.content)
-	file.Close()
+	if !b.nonEmpty || b.width() <= 0 || b.height() <= 0 {
+		return fmt.Errorf("Failed to crop svg: No visible content found")
+	}
 
-	// Perform cropping
-	err = cropSvg(path)
+	// Determine the current mapping from user coordinates to image dimensions
+	viewBox, err := img.viewBox()
 	if err != nil {
 		return err
 	}
 
-	// Overwrite image contents with cropped image
-	cropped, err := SvgFromFile(file.Name())
+	dim := [2]float64{
+		b.width() * img.dim[0] / viewBox[2],
+		b.height() * img.dim[1] / viewBox[3],
+	}
+
+	content, err := setRootAttrs(img.content, map[string]string{
+		"viewBox": fmt.Sprintf("%s %s %s %s",
+			formatSvgNumber(b.minX), formatSvgNumber(b.minY),
+			formatSvgNumber(b.width()), formatSvgNumber(b.height()),
+		),
+		"width":  formatSvgNumber(dim[0]) + string(img.unit),
+		"height": formatSvgNumber(dim[1]) + string(img.unit),
+	})
 	if err != nil {
 		return err
 	}
-	*img = *cropped
+
+	// The attached PDF still contains the uncropped figure
+	img.pdf = nil
+	img.content = content
+	img.dim = dim
 
 	return nil
 }
@@ -158,12 +205,21 @@ func (img *SvgImage) CropToContent() error {
 func (img *SvgImage) ToHtml(w io.Writer) {
 	fmt.Fprintf(w, `<p>`)
 
-	htmlContent := make([]byte, len(img.content))
-	copy(htmlContent, img.content)
+	content := img.outputContent()
+	htmlContent := make([]byte, len(content))
+	copy(htmlContent, content)
 
 	// Remove the XML-tag
 	htmlContent = regexp.MustCompile(`<\?xml.*?\?>\s*`).ReplaceAll(htmlContent, []byte(""))
 
+	// Prevent clashes with ids of other svgs on the same page
+	htmlContent = namespaceIds(htmlContent, svgIdPrefix(img.content))
+
+	// Add descriptions for screen readers
+	if described, err := img.describe(htmlContent); err == nil {
+		htmlContent = described
+	}
+
 	// Add Moodle's responsive image CSS-class
 	htmlContent = regexp.MustCompile(`<svg`).ReplaceAll(htmlContent, []byte(`<svg class="img-responsive"`))
 
@@ -171,54 +227,88 @@ func (img *SvgImage) ToHtml(w io.Writer) {
 	fmt.Fprintf(w, "</p>\n")
 }
 
-// ToBase64 encodes img to base64 format.
-// This is for instance used to include graphics in the 'Drag and drop markers'
-// question type.
-func (img *SvgImage) ToBase64(w io.Writer) {
-	b64Content := make([]byte, len(img.content))
-	copy(b64Content, img.content)
+var (
+	reIdAttr  = regexp.MustCompile(`(\sid\s*=\s*["'])([^"']*["'])`)
+	reHrefRef = regexp.MustCompile(`(\s(?:xlink:)?href\s*=\s*["']#)([^"']*["'])`)
+	reUrlRef  = regexp.MustCompile(`(url\(\s*["']?#)([^)"'\s]*)`)
+)
 
-	// Change svg dimensions to px (to prevent bug in Moodle's implementation)
-	b64Content = svgDims.ReplaceAll(b64Content, []byte(`${1}px`))
+// svgIdPrefix returns a prefix for the ids of the svg in content. The prefix
+// depends on the content, so different images use different prefixes. Copies of
+// the same image share ids, but since their definitions are identical, this
+// does not affect the rendering.
+func svgIdPrefix(content []byte) string {
+	hash := fnv.New32a()
+	hash.Write(content)
+	return fmt.Sprintf("svg%X-", hash.Sum32())
+}
 
-	fmt.Fprint(w, base64.StdEncoding.EncodeToString(b64Content))
+// namespaceIds adds prefix to all ids in the svg in content as well as to all
+// references to them.
+func namespaceIds(content []byte, prefix string) []byte {
+	repl := []byte("${1}" + prefix + "${2}")
+	content = reIdAttr.ReplaceAll(content, repl)
+	content = reHrefRef.ReplaceAll(content, repl)
+	return reUrlRef.ReplaceAll(content, repl)
 }
 
-// compileToPdf compiles a TikZ-picture into a PDF file.
-// The output is the path of the resulting file.
-func compileToPdf(s string, dir string) (string, error) {
-	// Wrap tikzpicture in TeX-document
-	var b strings.Builder
-	fmt.Fprint(&b, preamble)
-	fmt.Fprint(&b, s)
-	fmt.Fprint(&b, "\n\\end{document}")
-
-	// Compile file to pdf
-	cmd := exec.Command("pdflatex", "--output-directory", dir, "--jobname", "tikz", "--")
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = strings.NewReader(b.String())
-	if err := cmd.Run(); err != nil {
-		return "", fmt.Errorf("pdflatex: %v", err)
+// describe adds title and desc elements to the svg in content, and refers to
+// them from the root element.
+func (img *SvgImage) describe(content []byte) ([]byte, error) {
+	title := img.alt
+	if title == "" {
+		title = img.title
+	}
+	if title == "" && img.long == "" {
+		return content, nil
 	}
 
-	return filepath.Join(dir, "tikz.pdf"), nil
-}
+	id := img.descriptionId(img.content)
+	attrs := map[string]string{"role": "img"}
+	var elements bytes.Buffer
+	if title != "" {
+		attrs["aria-labelledby"] = id + "-title"
+		fmt.Fprintf(&elements, `<title id="%s-title">%s</title>`, id, html.EscapeString(title))
+	}
+	if img.long != "" {
+		attrs["aria-describedby"] = id + "-desc"
+		fmt.Fprintf(&elements, `<desc id="%s-desc">%s</desc>`, id, html.EscapeString(img.long))
+	}
 
-// compileToSvg compiles a multipage TikZ-picture into individual SVG files.
-// The output is a slice containing the path of each file.
-func compileToSvg(s string, dir string) ([]string, error) {
-	pdfPath, err := compileToPdf(s, dir)
+	content, err := setRootAttrs(content, attrs)
 	if err != nil {
 		return nil, err
 	}
 
-	// Convert file to svg
-	err = convertPdfToSvg(pdfPath, filepath.Join(dir, "tikz.svg"))
+	// Insert the elements as the first children of the root
+	root, err := findSvgRoot(content)
 	if err != nil {
 		return nil, err
 	}
+	if bytes.HasSuffix(content[root.start:root.end], []byte("/>")) {
+		return nil, fmt.Errorf("Svg has no contents")
+	}
+	return slices.Concat(content[:root.end], elements.Bytes(), content[root.end:]), nil
+}
+
+// ToBase64 encodes img to base64 format.
+// This is for instance used to include graphics in the 'Drag and drop markers'
+// question type. The dimensions are converted to px, since Moodle does not
+// handle other units. This preserves the physical size of img.
+func (img *SvgImage) ToBase64(w io.Writer) {
+	content := img.outputContent()
+	b64Content := content
+	if pxDim, err := img.DimensionIn(Px); err == nil {
+		b64Content, err = setRootAttrs(content, map[string]string{
+			"width":  formatSvgNumber(pxDim[0]) + "px",
+			"height": formatSvgNumber(pxDim[1]) + "px",
+		})
+		if err != nil {
+			b64Content = content
+		}
+	}
 
-	return filepath.Glob(filepath.Join(dir, "tikz*.svg"))
+	fmt.Fprint(w, base64.StdEncoding.EncodeToString(b64Content))
 }
 
 // convertPdfToSvg will automatically call either pdftocairo or pdf2svg to convert given
@@ -0,0 +1,107 @@
+package graphics
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// TexError describes a failed LaTeX compilation.
+// It is returned by the TikZ-functions whenever the LaTeX-engine exits with an
+// error, and holds the information extracted from the log file.
+type TexError struct {
+	Message string // The error message (i.e. the log line starting with '!')
+	Line    int    // Line number in the TikZ source (0 if unknown)
+	Context string // The log lines following the error message
+	Snippet string // The offending lines of the TikZ source
+	Err     error  // The error returned when running the LaTeX-engine
+}
+
+// Error returns a description of the LaTeX error including the source
+// snippet, if available.
+func (e *TexError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "LaTeX error: %s", e.Message)
+	if e.Line > 0 {
+		fmt.Fprintf(&b, " (line %d)", e.Line)
+	}
+	if e.Snippet != "" {
+		fmt.Fprintf(&b, "\n%s", e.Snippet)
+	}
+	return b.String()
+}
+
+// Unwrap returns the error produced when running the LaTeX-engine.
+func (e *TexError) Unwrap() error {
+	return e.Err
+}
+
+var reTexLine = regexp.MustCompile(`^l\.([0-9]+) `)
+
+// parseTexLog extracts the first error from a LaTeX log file.
+// The document argument must contain the full document that was compiled, and
+// offset is the number of lines preceding the TikZ source in the document.
+// Line numbers in the returned error are relative to the TikZ source.
+func parseTexLog(log []byte, document string, offset int, err error) *TexError {
+	texErr := &TexError{Err: err}
+
+	var context []string
+	inError := false
+	sc := bufio.NewScanner(bytes.NewReader(log))
+	for sc.Scan() {
+		line := sc.Text()
+		if !inError {
+			if msg, ok := strings.CutPrefix(line, "! "); ok {
+				texErr.Message = msg
+				inError = true
+			}
+			continue
+		}
+
+		if match := reTexLine.FindStringSubmatch(line); match != nil {
+			n, _ := strconv.Atoi(match[1])
+			if n > offset {
+				texErr.Line = n - offset
+				texErr.Snippet = sourceSnippet(document, n, offset)
+			}
+			context = append(context, line)
+			break
+		}
+		if line == "" && len(context) > 0 {
+			break
+		}
+		context = append(context, line)
+	}
+
+	if texErr.Message == "" {
+		texErr.Message = "Unable to locate error in log"
+		if err != nil {
+			texErr.Message = err.Error()
+		}
+	}
+	texErr.Context = strings.Join(context, "\n")
+
+	return texErr
+}
+
+// sourceSnippet returns the lines surrounding line n in document. The lines are
+// numbered relative to the TikZ source, which begins after offset lines.
+func sourceSnippet(document string, n int, offset int) string {
+	lines := strings.Split(document, "\n")
+	if n <= offset || n > len(lines) {
+		return ""
+	}
+
+	var b strings.Builder
+	for i := max(n-2, offset+1); i <= min(n+1, len(lines)); i++ {
+		marker := " "
+		if i == n {
+			marker = ">"
+		}
+		fmt.Fprintf(&b, "%s%4d | %s\n", marker, i-offset, lines[i-1])
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
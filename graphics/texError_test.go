@@ -0,0 +1,60 @@
+package graphics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const exampleLog = `This is pdfTeX, Version 3.141592653-2.6-1.40.25 (TeX Live 2023) (preloaded format=pdflatex)
+**
+(Please type a command or say ` + "`\\end'" + `)
+*
+! Undefined control sequence.
+l.16   \drw
+           (0,0) circle (2cm);
+The control sequence at the end of the top line
+of your error message was never \def'ed.
+
+! Emergency stop.
+<*>
+
+*** (job aborted, no legal \end found)
+`
+
+func TestParseTexLog(t *testing.T) {
+	document := strings.Repeat("preamble\n", 13) +
+		"\\begin{tikzpicture}\n" +
+		"  \\draw(1,0) node{Test};\n" +
+		"  \\drw(0,0) circle (2cm);\n" +
+		"\\end{tikzpicture}\n" +
+		"\\end{document}"
+	runErr := errors.New("exit status 1")
+
+	texErr := parseTexLog([]byte(exampleLog), document, 13, runErr)
+
+	if texErr.Message != "Undefined control sequence." {
+		t.Errorf("Expected message %q, but got %q", "Undefined control sequence.", texErr.Message)
+	}
+	if texErr.Line != 3 {
+		t.Errorf("Expected error on line 3, but got line %d", texErr.Line)
+	}
+	if !strings.Contains(texErr.Snippet, `>   3 |   \drw(0,0) circle (2cm);`) {
+		t.Errorf("Snippet does not point to offending line:\n%s", texErr.Snippet)
+	}
+	if !strings.HasPrefix(texErr.Context, `l.16   \drw`) {
+		t.Errorf("Unexpected context %q", texErr.Context)
+	}
+	if !errors.Is(texErr, runErr) {
+		t.Errorf("TexError does not wrap the original error")
+	}
+}
+
+func TestParseTexLogPreamble(t *testing.T) {
+	log := "! LaTeX Error: File `missing.sty' not found.\n\nl.5 \\usepackage{missing}\n"
+	texErr := parseTexLog([]byte(log), "", 13, nil)
+
+	if texErr.Line != 0 || texErr.Snippet != "" {
+		t.Errorf("Error in preamble was attributed to line %d of the source", texErr.Line)
+	}
+}
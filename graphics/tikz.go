@@ -0,0 +1,298 @@
+package graphics
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+//go:embed preamble.tex
+var preamble string
+
+// Engine is a LaTeX-engine that can be used to compile TikZ graphics.
+type Engine string
+
+// Supported LaTeX-engines.
+const (
+	PdfLatex Engine = "pdflatex"
+	LuaLatex Engine = "lualatex"
+	XeLatex  Engine = "xelatex"
+)
+
+// TikzCompiler describes how TikZ graphics are compiled.
+// By default, pdflatex is used with a preamble loading pgfplots, xcolor (with
+// the svgnames option), and the calc library for TikZ.
+type TikzCompiler struct {
+	engine       Engine
+	classOptions []string
+	packages     []string
+	libraries    []string
+	macros       []string
+	preamble     string
+}
+
+// NewTikzCompiler creates a TikzCompiler with the default settings. This is the
+// configuration used by the functions SvgFromTikz and SvgFromMultipageTikz.
+func NewTikzCompiler() *TikzCompiler {
+	return &TikzCompiler{
+		engine: PdfLatex,
+	}
+}
+
+// SetEngine selects the LaTeX-engine used for compilation. An error is
+// returned if the engine is not supported.
+func (tc *TikzCompiler) SetEngine(e Engine) error {
+	switch e {
+	case PdfLatex, LuaLatex, XeLatex:
+		tc.engine = e
+		return nil
+	}
+	return fmt.Errorf("Unsupported LaTeX-engine %q", e)
+}
+
+// SetClassOptions sets additional options for the standalone document class.
+// The option multi=page is always included.
+func (tc *TikzCompiler) SetClassOptions(options ...string) {
+	tc.classOptions = options
+}
+
+// AddPackage loads the package name with the given options in the preamble.
+func (tc *TikzCompiler) AddPackage(name string, options ...string) {
+	if len(options) > 0 {
+		name = fmt.Sprintf("[%s]{%s}", strings.Join(options, ","), name)
+	} else {
+		name = fmt.Sprintf("{%s}", name)
+	}
+	tc.packages = append(tc.packages, name)
+}
+
+// AddTikzLibrary loads the given TikZ libraries in the preamble.
+func (tc *TikzCompiler) AddTikzLibrary(names ...string) {
+	tc.libraries = append(tc.libraries, names...)
+}
+
+// AddMacros includes s at the end of the preamble. This can for instance be
+// used for defining macros or TikZ styles shared by several figures.
+func (tc *TikzCompiler) AddMacros(s string) {
+	tc.macros = append(tc.macros, s)
+}
+
+// SetPreamble replaces the entire preamble (i.e. everything preceding
+// \begin{document}) by s. The settings given by SetClassOptions, AddPackage,
+// AddTikzLibrary, and AddMacros are ignored while a custom preamble is set.
+// Passing an empty string restores the default preamble.
+//
+// The preamble must load TikZ, and multipage figures require the standalone
+// document class with the option multi=page.
+func (tc *TikzCompiler) SetPreamble(s string) {
+	tc.preamble = s
+}
+
+// Preamble returns the preamble used when compiling figures.
+func (tc *TikzCompiler) Preamble() string {
+	if tc.preamble != "" {
+		return tc.preamble
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\\documentclass[%s]{standalone}\n",
+		strings.Join(append([]string{"multi=page"}, tc.classOptions...), ","))
+
+	if tc.engine == PdfLatex {
+		fmt.Fprint(&b, "\\usepackage[utf8]{inputenc}\n\\usepackage[T1]{fontenc}\n")
+	} else {
+		fmt.Fprint(&b, "\\usepackage{fontspec}\n")
+	}
+	fmt.Fprintf(&b, "\n%s", preamble)
+
+	for _, v := range tc.packages {
+		fmt.Fprintf(&b, "\\usepackage%s\n", v)
+	}
+	if len(tc.libraries) > 0 {
+		fmt.Fprintf(&b, "\\usetikzlibrary{%s}\n", strings.Join(tc.libraries, ","))
+	}
+	for _, v := range tc.macros {
+		fmt.Fprintln(&b, v)
+	}
+
+	return b.String()
+}
+
+// SvgFromTikz compiles a TikZ- or pfgplots-environment into an SvgImage.
+// Intermediate results will be stored in tmpDir. If this argument is "", then
+// a temporary folder will be created and deleted automatically. If tmpDir is
+// specified, the called is responsible for deletion. If the figure is retrieved
+// from a cache (see UseCache), nothing is written to tmpDir.
+//
+// If LaTeX fails to compile s, the returned error will be a *TexError
+// describing the problem.
+func SvgFromTikz(s string, tmpDir string) (*SvgImage, error) {
+	return NewTikzCompiler().SvgFromTikz(s, tmpDir)
+}
+
+// SvgFromMultipageTikz compiles a multipage TikZ-document into a slice of
+// SvgImage, one for each page. The pages should be wrapped in page-environments
+// (see the standalone package). The tmpDir argument is handled as in
+// SvgFromTikz.
+//
+// If a cache has been enabled by UseCache, previously compiled figures are
+// retrieved from there. In that case, LaTeX is not run and nothing is written
+// to tmpDir.
+func SvgFromMultipageTikz(s string, tmpDir string) ([]*SvgImage, error) {
+	return NewTikzCompiler().SvgFromMultipageTikz(s, tmpDir)
+}
+
+// SvgFromTikz compiles a TikZ- or pgfplots-environment into an SvgImage using
+// the settings of tc. See the function SvgFromTikz for details.
+func (tc *TikzCompiler) SvgFromTikz(s string, tmpDir string) (*SvgImage, error) {
+	svg, err := tc.SvgFromMultipageTikz(s, tmpDir)
+	if svg != nil {
+		return svg[0], err
+	}
+
+	return nil, err
+}
+
+// SvgFromMultipageTikz compiles a multipage TikZ-document into a slice of
+// SvgImage using the settings of tc. See the function SvgFromMultipageTikz for
+// details.
+func (tc *TikzCompiler) SvgFromMultipageTikz(s string, tmpDir string) ([]*SvgImage, error) {
+	cache := tikzCache
+	if cache != nil {
+		if svgs, ok := cache.load(tc.document(s), tc.engine); ok {
+			return svgs, nil
+		}
+	}
+
+	if tmpDir == "" {
+		var err error
+		tmpDir, err = os.MkdirTemp("", "moodleTikz-*")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(tmpDir)
+	}
+
+	svgPath, err := tc.compileToSvg(s, tmpDir)
+	if err != nil {
+		return nil, err
+	}
+
+	svgs := make([]*SvgImage, len(svgPath), len(svgPath))
+	for i, v := range svgPath {
+		svgs[i], err = SvgFromFile(v)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := placeZones(svgs, filepath.Join(tmpDir, "tikz.aux")); err != nil {
+		return nil, err
+	}
+	pdfPath := filepath.Join(tmpDir, "tikz.pdf")
+	if err := attachPdf(svgs, pdfPath); err != nil {
+		return nil, err
+	}
+
+	if cache != nil {
+		// Failing to store the figures does not affect the result
+		zones := make([]map[string][2]float64, len(svgs))
+		for i, v := range svgs {
+			zones[i] = v.zones
+		}
+		cache.store(tc.document(s), tc.engine, pdfPath, svgPath, zones)
+	}
+
+	return svgs, nil
+}
+
+// document wraps a TikZ-picture in a complete LaTeX document.
+func (tc *TikzCompiler) document(s string) string {
+	var b strings.Builder
+	fmt.Fprint(&b, tc.documentPrefix())
+	fmt.Fprint(&b, s)
+	fmt.Fprint(&b, "\n\\end{document}")
+	return b.String()
+}
+
+// documentPrefix returns everything preceding the TikZ-picture in the LaTeX
+// document.
+func (tc *TikzCompiler) documentPrefix() string {
+	return strings.TrimRight(tc.Preamble(), "\n") + "\n" + zoneMacros + "\\begin{document}\n"
+}
+
+// compileToPdf compiles a TikZ-picture into a PDF file.
+// The output is the path of the resulting file.
+func (tc *TikzCompiler) compileToPdf(s string, dir string) (string, error) {
+	offset := strings.Count(tc.documentPrefix(), "\n")
+	return runLatex(tc.engine, tc.document(s), dir, "tikz", "", offset)
+}
+
+// CompileLatex compiles the complete LaTeX document using pdflatex. The output
+// is written to dir using the given job name, and the path of the resulting PDF
+// is returned. LaTeX runs in dir, so files included by document may be given
+// relative to dir.
+//
+// If LaTeX fails to compile document, the returned error will be a *TexError
+// describing the problem.
+func CompileLatex(document, jobname, dir string) (string, error) {
+	return NewTikzCompiler().CompileLatex(document, jobname, dir)
+}
+
+// CompileLatex compiles the complete LaTeX document using the engine of tc. See
+// the function CompileLatex for details. The preamble of tc is not used.
+func (tc *TikzCompiler) CompileLatex(document, jobname, dir string) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	return runLatex(tc.engine, document, absDir, jobname, absDir, 0)
+}
+
+// runLatex compiles document using engine, writing the output to dir with the
+// given job name. If workDir is non-empty, LaTeX runs in that folder. The first
+// offset lines of document are skipped when reporting errors.
+// The output is the path of the resulting PDF.
+func runLatex(engine Engine, document, dir, jobname, workDir string, offset int) (string, error) {
+	cmd := exec.Command(
+		string(engine),
+		"-interaction=nonstopmode",
+		"--output-directory", dir,
+		"--jobname", jobname,
+		"--",
+	)
+	cmd.Dir = workDir
+	cmd.Stdin = strings.NewReader(document)
+	if err := cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			// The engine did not run, so no log is available
+			return "", fmt.Errorf("%s: %v", engine, err)
+		}
+		log, logErr := os.ReadFile(filepath.Join(dir, jobname+".log"))
+		if logErr != nil {
+			return "", fmt.Errorf("%s: %v", engine, err)
+		}
+		return "", parseTexLog(log, document, offset, err)
+	}
+
+	return filepath.Join(dir, jobname+".pdf"), nil
+}
+
+// compileToSvg compiles a multipage TikZ-picture into individual SVG files.
+// The output is a slice containing the path of each file.
+func (tc *TikzCompiler) compileToSvg(s string, dir string) ([]string, error) {
+	pdfPath, err := tc.compileToPdf(s, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert file to svg
+	err = convertPdfToSvg(pdfPath, filepath.Join(dir, "tikz.svg"))
+	if err != nil {
+		return nil, err
+	}
+
+	return filepath.Glob(filepath.Join(dir, "tikz*.svg"))
+}
@@ -54,7 +54,7 @@ func TestPdf2svg(t *testing.T) {
 		t.Fatalf("Failed to create temporary folder: %s", err)
 	}
 
-	path, err := compileToPdf(exampleMulti, tmpDir)
+	path, err := NewTikzCompiler().compileToPdf(exampleMulti, tmpDir)
 	if err != nil {
 		t.Fatalf("Failed to compile PDF: %s", err)
 	}
@@ -94,3 +94,47 @@ func TestHtmlValid(t *testing.T) {
 		}
 	}
 }
+
+func TestTikzCompilerPreamble(t *testing.T) {
+	tc := NewTikzCompiler()
+	if err := tc.SetEngine("latex"); err == nil {
+		t.Errorf("Unsupported engine failed to return an error")
+	}
+	if err := tc.SetEngine(LuaLatex); err != nil {
+		t.Fatalf("Setting engine caused error: %s", err)
+	}
+	tc.SetClassOptions("border=2pt")
+	tc.AddPackage("amsmath")
+	tc.AddPackage("siunitx", "per-mode=fraction")
+	tc.AddTikzLibrary("arrows.meta", "positioning")
+	tc.AddMacros(`\newcommand{\R}{\mathbb{R}}`)
+
+	p := tc.Preamble()
+	for _, v := range []string{
+		`\documentclass[multi=page,border=2pt]{standalone}`,
+		`\usepackage{fontspec}`,
+		`\usepackage{pgfplots}`,
+		`\usepackage{amsmath}`,
+		`\usepackage[per-mode=fraction]{siunitx}`,
+		`\usetikzlibrary{arrows.meta,positioning}`,
+		`\newcommand{\R}{\mathbb{R}}`,
+	} {
+		if !strings.Contains(p, v) {
+			t.Errorf("Preamble does not contain %q:\n%s", v, p)
+		}
+	}
+	if strings.Contains(p, "inputenc") {
+		t.Errorf("Preamble for %s loads inputenc", LuaLatex)
+	}
+
+	custom := `\documentclass{standalone}` + "\n" + `\usepackage{tikz}`
+	tc.SetPreamble(custom)
+	if p := tc.Preamble(); p != custom {
+		t.Errorf("Expected custom preamble %q, but got %q", custom, p)
+	}
+
+	doc := tc.document(example)
+	if !strings.HasPrefix(doc, custom+"\n"+zoneMacros+"\\begin{document}\n"+example) {
+		t.Errorf("Unexpected document:\n%s", doc)
+	}
+}
@@ -0,0 +1,269 @@
+package graphics
+
+import (
+	"strings"
+	"unicode"
+)
+
+// MathToUnicode converts a LaTeX math expression into plain text using Unicode
+// symbols. For instance, \alpha^2 \leq \frac{1}{2} becomes α² ≤ 1/2. This is
+// useful where Moodle only allows plain text, such as the markers of
+// DropMarker and DropText questions.
+//
+// The conversion is an approximation. Superscripts and subscripts that have
+// no Unicode equivalent are written as ^(...) and _(...), and unknown macros
+// are kept unchanged.
+func MathToUnicode(math string) string {
+	c := mathConverter{src: []rune(stripMathDelimiters(math))}
+	return strings.Join(strings.Fields(c.convert(0)), " ")
+}
+
+// mathSymbols maps macros to their Unicode equivalent.
+var mathSymbols = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ",
+	"varepsilon": "ε", "zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ",
+	"iota": "ι", "kappa": "κ", "lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ",
+	"pi": "π", "varpi": "ϖ", "rho": "ρ", "varrho": "ϱ", "sigma": "σ",
+	"varsigma": "ς", "tau": "τ", "upsilon": "υ", "phi": "ϕ", "varphi": "φ",
+	"chi": "χ", "psi": "ψ", "omega": "ω",
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ",
+	"Pi": "Π", "Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ",
+	"Omega": "Ω",
+
+	"cdot": "·", "times": "×", "div": "÷", "pm": "±", "mp": "∓", "ast": "∗",
+	"circ": "∘", "bullet": "•", "leq": "≤", "le": "≤", "geq": "≥", "ge": "≥",
+	"neq": "≠", "ne": "≠", "approx": "≈", "equiv": "≡", "sim": "∼",
+	"simeq": "≃", "cong": "≅", "propto": "∝", "ll": "≪", "gg": "≫",
+	"lt": "<", "gt": ">", "mid": "∣", "parallel": "∥", "perp": "⊥",
+
+	"infty": "∞", "partial": "∂", "nabla": "∇", "sum": "∑", "prod": "∏",
+	"int": "∫", "iint": "∬", "oint": "∮", "prime": "′", "hbar": "ℏ",
+	"ell": "ℓ", "Re": "ℜ", "Im": "ℑ", "aleph": "ℵ", "angle": "∠",
+	"degree": "°",
+
+	"in": "∈", "notin": "∉", "ni": "∋", "subset": "⊂", "subseteq": "⊆",
+	"supset": "⊃", "supseteq": "⊇", "cup": "∪", "cap": "∩", "setminus": "∖",
+	"emptyset": "∅", "varnothing": "∅", "forall": "∀", "exists": "∃",
+	"neg": "¬", "lnot": "¬", "land": "∧", "wedge": "∧", "lor": "∨",
+	"vee": "∨", "oplus": "⊕", "otimes": "⊗",
+
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "gets": "←",
+	"leftrightarrow": "↔", "Rightarrow": "⇒", "implies": "⇒",
+	"Leftarrow": "⇐", "Leftrightarrow": "⇔", "iff": "⇔", "mapsto": "↦",
+	"uparrow": "↑", "downarrow": "↓",
+
+	"ldots": "…", "dots": "…", "cdots": "⋯", "vdots": "⋮", "ddots": "⋱",
+	"langle": "⟨", "rangle": "⟩", "lfloor": "⌊", "rfloor": "⌋",
+	"lceil": "⌈", "rceil": "⌉", "vert": "|", "|": "‖", "lbrace": "{",
+	"rbrace": "}", "{": "{", "}": "}", "%": "%", "$": "$", "&": "&", "#": "#",
+	"_": "_",
+
+	",": " ", ":": " ", ";": " ", " ": " ", "quad": " ", "qquad": " ",
+	"!": "", "left": "", "right": "", "big": "", "Big": "", "bigg": "",
+	"Bigg": "", "displaystyle": "", "limits": "", "\\": " ",
+}
+
+// mathFunctions lists macros that are typeset as their name.
+var mathFunctions = map[string]bool{
+	"sin": true, "cos": true, "tan": true, "cot": true, "sec": true,
+	"csc": true, "arcsin": true, "arccos": true, "arctan": true, "sinh": true,
+	"cosh": true, "tanh": true, "log": true, "ln": true, "lg": true,
+	"exp": true, "lim": true, "min": true, "max": true, "sup": true,
+	"inf": true, "det": true, "dim": true, "ker": true, "deg": true,
+	"gcd": true, "arg": true, "Pr": true,
+}
+
+// mathDoubleStruck maps letters to their blackboard bold variants.
+var mathDoubleStruck = map[rune]rune{
+	'C': 'ℂ', 'H': 'ℍ', 'N': 'ℕ', 'P': 'ℙ', 'Q': 'ℚ', 'R': 'ℝ', 'Z': 'ℤ',
+}
+
+// mathSuperscripts maps characters to their superscript variants.
+var mathSuperscripts = map[rune]rune{
+	'0': '⁰', '1': '¹', '2': '²', '3': '³', '4': '⁴', '5': '⁵', '6': '⁶',
+	'7': '⁷', '8': '⁸', '9': '⁹', '+': '⁺', '-': '⁻', '−': '⁻', '=': '⁼',
+	'(': '⁽', ')': '⁾', 'a': 'ᵃ', 'b': 'ᵇ', 'c': 'ᶜ', 'd': 'ᵈ', 'e': 'ᵉ',
+	'f': 'ᶠ', 'g': 'ᵍ', 'h': 'ʰ', 'i': 'ⁱ', 'j': 'ʲ', 'k': 'ᵏ', 'l': 'ˡ',
+	'm': 'ᵐ', 'n': 'ⁿ', 'o': 'ᵒ', 'p': 'ᵖ', 'r': 'ʳ', 's': 'ˢ', 't': 'ᵗ',
+	'u': 'ᵘ', 'v': 'ᵛ', 'w': 'ʷ', 'x': 'ˣ', 'y': 'ʸ', 'z': 'ᶻ', 'T': 'ᵀ',
+	'*': '*', '′': '′',
+}
+
+// mathSubscripts maps characters to their subscript variants.
+var mathSubscripts = map[rune]rune{
+	'0': '₀', '1': '₁', '2': '₂', '3': '₃', '4': '₄', '5': '₅', '6': '₆',
+	'7': '₇', '8': '₈', '9': '₉', '+': '₊', '-': '₋', '−': '₋', '=': '₌',
+	'(': '₍', ')': '₎', 'a': 'ₐ', 'e': 'ₑ', 'h': 'ₕ', 'i': 'ᵢ', 'j': 'ⱼ',
+	'k': 'ₖ', 'l': 'ₗ', 'm': 'ₘ', 'n': 'ₙ', 'o': 'ₒ', 'p': 'ₚ', 'r': 'ᵣ',
+	's': 'ₛ', 't': 'ₜ', 'u': 'ᵤ', 'v': 'ᵥ', 'x': 'ₓ',
+}
+
+// mathConverter converts LaTeX math into Unicode text.
+type mathConverter struct {
+	src []rune
+	pos int
+}
+
+// convert converts the source until the rune stop is reached. The stop rune is
+// consumed. If stop is 0, the entire source is converted.
+func (c *mathConverter) convert(stop rune) string {
+	var b strings.Builder
+	for c.pos < len(c.src) {
+		r := c.src[c.pos]
+		c.pos++
+
+		switch r {
+		case stop:
+			return b.String()
+		case '{':
+			b.WriteString(c.convert('}'))
+		case '^':
+			b.WriteString(script(c.argument(), mathSuperscripts, "^"))
+		case '_':
+			b.WriteString(script(c.argument(), mathSubscripts, "_"))
+		case '\\':
+			b.WriteString(c.macro())
+		case '~':
+			b.WriteRune(' ')
+		case '\'':
+			b.WriteRune('′')
+		case '$', '&':
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// argument converts the next argument of a macro, superscript or subscript.
+// This is either a group in braces or a single token.
+func (c *mathConverter) argument() string {
+	for c.pos < len(c.src) && unicode.IsSpace(c.src[c.pos]) {
+		c.pos++
+	}
+	if c.pos >= len(c.src) {
+		return ""
+	}
+
+	r := c.src[c.pos]
+	c.pos++
+	switch r {
+	case '{':
+		return c.convert('}')
+	case '\\':
+		return c.macro()
+	}
+	return string(r)
+}
+
+// optionalArgument returns the contents of an optional argument in square
+// brackets, if present.
+func (c *mathConverter) optionalArgument() string {
+	if c.pos >= len(c.src) || c.src[c.pos] != '[' {
+		return ""
+	}
+	c.pos++
+	return c.convert(']')
+}
+
+// macro converts a macro. The leading backslash must already be consumed.
+func (c *mathConverter) macro() string {
+	if c.pos >= len(c.src) {
+		return ""
+	}
+
+	// Read the name, which is either a sequence of letters or a single symbol
+	start := c.pos
+	for c.pos < len(c.src) && unicode.IsLetter(c.src[c.pos]) {
+		c.pos++
+	}
+	if c.pos == start {
+		c.pos++
+		return mathSymbols[string(c.src[start:c.pos])]
+	}
+	name := string(c.src[start:c.pos])
+
+	if s, ok := mathSymbols[name]; ok {
+		return s
+	}
+	if mathFunctions[name] {
+		return name
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac":
+		num := c.argument()
+		return parenthesize(num) + "/" + parenthesize(c.argument())
+	case "sqrt":
+		root := "√"
+		switch n := c.optionalArgument(); n {
+		case "", "2":
+		case "3":
+			root = "∛"
+		case "4":
+			root = "∜"
+		default:
+			root = script(n, mathSuperscripts, "") + root
+		}
+		return root + parenthesize(c.argument())
+	case "text", "textrm", "mathrm", "mathit", "mathbf", "mathsf", "mathtt",
+		"boldsymbol", "operatorname", "mbox":
+		return c.argument()
+	case "mathbb":
+		arg := []rune(c.argument())
+		for i, r := range arg {
+			if d, ok := mathDoubleStruck[r]; ok {
+				arg[i] = d
+			}
+		}
+		return string(arg)
+	case "overline", "bar":
+		return combine(c.argument(), '̅')
+	case "hat", "widehat":
+		return combine(c.argument(), '̂')
+	case "vec":
+		return combine(c.argument(), '⃗')
+	case "dot":
+		return combine(c.argument(), '̇')
+	case "tilde", "widetilde":
+		return combine(c.argument(), '̃')
+	}
+
+	return "\\" + name
+}
+
+// script converts s to superscript or subscript using table. If some
+// character has no equivalent, s is written after prefix instead.
+func script(s string, table map[rune]rune, prefix string) string {
+	var b strings.Builder
+	for _, r := range s {
+		v, ok := table[r]
+		if !ok {
+			return prefix + parenthesize(s)
+		}
+		b.WriteRune(v)
+	}
+	return b.String()
+}
+
+// combine adds the combining character mark after each character of s.
+func combine(s string, mark rune) string {
+	var b strings.Builder
+	for _, r := range s {
+		b.WriteRune(r)
+		b.WriteRune(mark)
+	}
+	return b.String()
+}
+
+// parenthesize wraps s in parentheses unless it is a single character or
+// number.
+func parenthesize(s string) string {
+	s = strings.TrimSpace(s)
+	if len([]rune(s)) <= 1 || strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsDigit(r) && r != '.'
+	}) < 0 {
+		return s
+	}
+	return "(" + s + ")"
+}
@@ -0,0 +1,40 @@
+package graphics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMathToUnicode(t *testing.T) {
+	testCases := []struct {
+		math, expected string
+	}{
+		{`\alpha^2`, "α²"},
+		{`$x_{i+1} \leq \frac{1}{2}$`, "xᵢ₊₁ ≤ 1/2"},
+		{`\(e^{i\pi} = -1\)`, "e^(iπ) = -1"},
+		{`\frac{a+b}{c}`, "(a+b)/c"},
+		{`\sqrt{x^2+1}`, "√(x²+1)"},
+		{`\sqrt[3]{8}`, "∛8"},
+		{`\sqrt[n]{x}`, "ⁿ√x"},
+		{`f\colon \mathbb{R}\to\mathbb R`, `f\colon ℝ→ℝ`},
+		{`\sin\theta \cdot \left(a\right)`, "sinθ · (a)"},
+		{`\text{if } x \in A`, "if x ∈ A"},
+		{`f'(x)`, "f′(x)"},
+		{`10^{-3}`, "10⁻³"},
+		{`\{1,\ldots,n\}`, "{1,…,n}"},
+	}
+
+	for _, v := range testCases {
+		if got := MathToUnicode(v.math); got != v.expected {
+			t.Errorf("Converting %q produced %q, but expected %q", v.math, got, v.expected)
+		}
+	}
+}
+
+func TestMathPicture(t *testing.T) {
+	for _, v := range []string{`x^2`, `$x^2$`, ` \(x^2\) `, `$$x^2$$`, `\[x^2\]`} {
+		if got := mathPicture(v); !strings.Contains(got, "{$x^2$}") {
+			t.Errorf("Picture for %q does not contain the expression:\n%s", v, got)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package graphics
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// zoneMacros defines the \moodlezone macro. It is included after the preamble
+// of every figure.
+//
+//go:embed zones.tex
+var zoneMacros string
+
+// texPtPerBp is the number of TeX points per big point (the pt of svg and PDF).
+const texPtPerBp = 72.27 / 72
+
+// ZoneNames returns the names of the zones defined in the TikZ source of img
+// using \moodlezone. The names are sorted alphabetically.
+func (img *SvgImage) ZoneNames() []string {
+	names := make([]string, 0, len(img.zones))
+	for k := range img.zones {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ZonePosition returns the position of the zone called name in pixels relative
+// to the top left corner of img. This is the coordinate system used for drop
+// zones in Moodle. Cropping and scaling of img are taken into account. The
+// size of img in px matches the image written by ToBase64.
+//
+// Zones are defined in the TikZ source using \moodlezone{name}{coordinate},
+// which also creates a TikZ coordinate with the given name. For instance,
+//
+//	\moodlezone{peak}{2,3}
+//	\moodlezone{base}{$(peak)+(0,-3)$}
+func (img *SvgImage) ZonePosition(name string) ([2]float64, error) {
+	p, ok := img.zones[name]
+	if !ok {
+		return [2]float64{}, fmt.Errorf("Image has no zone called %q", name)
+	}
+
+	viewBox, err := img.viewBox()
+	if err != nil {
+		return [2]float64{}, err
+	}
+	pxDim, err := img.DimensionIn(Px)
+	if err != nil {
+		return [2]float64{}, err
+	}
+
+	return [2]float64{
+		(p[0] - viewBox[0]) * pxDim[0] / viewBox[2],
+		(p[1] - viewBox[1]) * pxDim[1] / viewBox[3],
+	}, nil
+}
+
+// viewBox returns the viewBox of img. If it is missing, the viewBox implied by
+// the dimensions is returned.
+func (img *SvgImage) viewBox() ([]float64, error) {
+	root, err := findSvgRoot(img.content)
+	if err != nil {
+		return nil, err
+	}
+	if viewBox, ok := root.viewBox(); ok {
+		return viewBox, nil
+	}
+
+	// Without a viewBox, user coordinates are measured in px
+	pxDim, err := img.DimensionIn(Px)
+	if err != nil {
+		return nil, err
+	}
+	return []float64{0, 0, pxDim[0], pxDim[1]}, nil
+}
+
+// texZones contains the zones recorded on a single page during compilation.
+type texZones struct {
+	points map[string][2]float64 // Canvas coordinates in TeX points
+	bounds bbox                  // Bounding box of the pictures on the page
+}
+
+var (
+	reZoneAux = regexp.MustCompile(`(?m)^%moodlezone (\d+) (.*)=(-?[0-9.]+)pt,(-?[0-9.]+)pt\s*$`)
+	reBboxAux = regexp.MustCompile(`(?m)^%moodlebbox (\d+) (-?[0-9.]+)pt,(-?[0-9.]+)pt,(-?[0-9.]+)pt,(-?[0-9.]+)pt\s*$`)
+)
+
+// parseZoneAux extracts the zones written to the aux file by \moodlezone. The
+// result maps page numbers to the zones on that page.
+func parseZoneAux(aux []byte) map[int]*texZones {
+	pages := make(map[int]*texZones)
+	page := func(s string) *texZones {
+		n, _ := strconv.Atoi(s)
+		if pages[n] == nil {
+			pages[n] = &texZones{points: make(map[string][2]float64)}
+		}
+		return pages[n]
+	}
+	number := func(s string) float64 {
+		f, _ := strconv.ParseFloat(s, 64)
+		return f
+	}
+
+	for _, m := range reBboxAux.FindAllStringSubmatch(string(aux), -1) {
+		p := page(m[1])
+		p.bounds.add(number(m[2]), number(m[3]))
+		p.bounds.add(number(m[4]), number(m[5]))
+	}
+	for _, m := range reZoneAux.FindAllStringSubmatch(string(aux), -1) {
+		page(m[1]).points[m[2]] = [2]float64{number(m[3]), number(m[4])}
+	}
+
+	return pages
+}
+
+// placeZones reads the zones recorded in the aux file at auxPath and assigns
+// them to the svgs of the corresponding pages.
+func placeZones(svgs []*SvgImage, auxPath string) error {
+	aux, err := os.ReadFile(auxPath)
+	if err != nil {
+		// Without an aux file, no zones were recorded
+		return nil
+	}
+
+	for n, z := range parseZoneAux(aux) {
+		if len(z.points) == 0 {
+			continue
+		}
+		if n < 1 || n > len(svgs) {
+			return fmt.Errorf("Zones recorded on page %d, but only %d pages were produced", n, len(svgs))
+		}
+		if err := svgs[n-1].placeZones(z); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// placeZones converts the zones in z to user coordinates of img.
+//
+// The page produced by the standalone class is the bounding box of the picture
+// surrounded by a border. The border is assumed to be equal on opposite sides.
+func (img *SvgImage) placeZones(z *texZones) error {
+	if !z.bounds.nonEmpty {
+		return fmt.Errorf("Zones were recorded, but the bounding box of the picture is unknown")
+	}
+
+	viewBox, err := img.viewBox()
+	if err != nil {
+		return err
+	}
+	ptDim, err := img.DimensionIn(Pt)
+	if err != nil {
+		return err
+	}
+
+	// Number of user units per TeX point
+	scale := viewBox[2] / ptDim[0] / texPtPerBp
+	border := [2]float64{
+		(viewBox[2] - z.bounds.width()*scale) / 2,
+		(viewBox[3] - z.bounds.height()*scale) / 2,
+	}
+
+	img.zones = make(map[string][2]float64, len(z.points))
+	for name, p := range z.points {
+		img.zones[name] = [2]float64{
+			viewBox[0] + border[0] + (p[0]-z.bounds.minX)*scale,
+			viewBox[1] + border[1] + (z.bounds.maxY-p[1])*scale,
+		}
+	}
+	return nil
+}
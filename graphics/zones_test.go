@@ -0,0 +1,108 @@
+package graphics
+
+import (
+	"encoding/base64"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const exampleZoneAux = `\relax
+%moodlebbox 1 0.0pt,0.0pt,72.27pt,36.135pt
+%moodlezone 1 top=36.135pt,36.135pt
+%moodlezone 1 a=b=0.0pt,0.0pt
+%moodlezone 2 other=1.0pt,2.0pt
+`
+
+func TestParseZoneAux(t *testing.T) {
+	pages := parseZoneAux([]byte(exampleZoneAux))
+	if len(pages) != 2 {
+		t.Fatalf("Expected zones on 2 pages, but got %d", len(pages))
+	}
+	if p := pages[1].points["top"]; p != [2]float64{36.135, 36.135} {
+		t.Errorf("Unexpected position %v of zone top", p)
+	}
+	if _, ok := pages[1].points["a=b"]; !ok {
+		t.Errorf("Zone name containing '=' was not parsed")
+	}
+	if b := pages[1].bounds; b.width() != 72.27 || b.height() != 36.135 {
+		t.Errorf("Unexpected bounding box %v", b)
+	}
+	if pages[2].bounds.nonEmpty {
+		t.Errorf("Page without bounding box has bounds %v", pages[2].bounds)
+	}
+}
+
+func TestZonePosition(t *testing.T) {
+	approx := func(a, b [2]float64) bool {
+		return math.Abs(a[0]-b[0]) < 1e-9 && math.Abs(a[1]-b[1]) < 1e-9
+	}
+
+	// A 72bp x 36bp picture surrounded by a border of 0.5bp
+	svg := `<svg width="73pt" height="37pt" viewBox="0 0 73 37">` +
+		`<rect x="0.5" y="0.5" width="72" height="36"/></svg>`
+	img, err := SvgFromBytes([]byte(svg))
+	if err != nil {
+		t.Fatalf("Reading svg caused error: %s", err)
+	}
+	if err := img.placeZones(parseZoneAux([]byte(exampleZoneAux))[1]); err != nil {
+		t.Fatalf("Placing zones caused error: %s", err)
+	}
+
+	if names := img.ZoneNames(); strings.Join(names, " ") != "a=b top" {
+		t.Errorf("Unexpected zone names %q", names)
+	}
+
+	// Positions are measured in px, i.e. 4/3 of a pt
+	pos, err := img.ZonePosition("top")
+	if err != nil {
+		t.Fatalf("Zone position caused error: %s", err)
+	}
+	if expected := [2]float64{36.5 * 4 / 3, 0.5 * 4 / 3}; !approx(pos, expected) {
+		t.Errorf("Expected position %v, but got %v", expected, pos)
+	}
+
+	// Moodle shows the image with the same size in px
+	var b strings.Builder
+	img.ToBase64(&b)
+	if decoded, _ := base64.StdEncoding.DecodeString(b.String()); !strings.Contains(string(decoded), `width="97.333px"`) {
+		t.Errorf("Background does not match the zone coordinates:\n%s", decoded)
+	}
+
+	img.Scale(2)
+	pos, _ = img.ZonePosition("top")
+	if expected := [2]float64{36.5 * 8 / 3, 0.5 * 8 / 3}; !approx(pos, expected) {
+		t.Errorf("Expected position %v after scaling, but got %v", expected, pos)
+	}
+
+	if err := img.cropNative(); err != nil {
+		t.Fatalf("Cropping caused error: %s", err)
+	}
+	pos, _ = img.ZonePosition("top")
+	if expected := [2]float64{36 * 8 / 3, 0}; !approx(pos, expected) {
+		t.Errorf("Expected position %v after cropping, but got %v", expected, pos)
+	}
+
+	if _, err := img.ZonePosition("bottom"); err == nil {
+		t.Errorf("Unknown zone failed to return an error")
+	}
+}
+
+func TestPlaceZones(t *testing.T) {
+	tmpDir := t.TempDir()
+	img, _ := SvgFromBytes([]byte(`<svg width="73pt" height="37pt" viewBox="0 0 73 37"></svg>`))
+	svgs := []*SvgImage{img}
+
+	if err := placeZones(svgs, filepath.Join(tmpDir, "missing.aux")); err != nil {
+		t.Errorf("Missing aux file caused error: %s", err)
+	}
+
+	// Page 2 does not exist
+	auxPath := filepath.Join(tmpDir, "tikz.aux")
+	os.WriteFile(auxPath, []byte(exampleZoneAux), 0o644)
+	if err := placeZones(svgs, auxPath); err == nil {
+		t.Errorf("Zones on missing page failed to return an error")
+	}
+}
@@ -0,0 +1,92 @@
+package moodle
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// AccessibilityWarning describes an image in a question bank that lacks a
+// textual description.
+type AccessibilityWarning struct {
+	Question int    // Number of the question in the bank, starting from 1
+	Field    string // The part of the question containing the image
+	Message  string
+}
+
+// String returns a human-readable description of the warning.
+func (w AccessibilityWarning) String() string {
+	return fmt.Sprintf("Question %d, %s: %s", w.Question, w.Field, w.Message)
+}
+
+var (
+	reImgTag     = regexp.MustCompile(`(?i)<img\b[^>]*>`)
+	reSvgTag     = regexp.MustCompile(`(?i)<svg\b[^>]*>`)
+	reSrcAttr    = regexp.MustCompile(`(?i)\ssrc\s*=\s*("[^"]*"|'[^']*')`)
+	reAltAttr    = regexp.MustCompile(`(?i)\salt\s*=`)
+	reLabelAttr  = regexp.MustCompile(`(?i)\saria-label(?:ledby)?\s*=`)
+	reHiddenAttr = regexp.MustCompile(`(?i)\s(?:aria-hidden\s*=\s*["']true|role\s*=\s*["'](?:presentation|none))`)
+)
+
+// CheckAccessibility reports images in qb that lack a textual description. This
+// includes images without an alt attribute, inline svgs without a title, and
+// background images of 'Drag and drop markers' questions without a
+// description. Images explicitly marked as decorative (using an empty alt
+// attribute, aria-hidden, or role="presentation") are accepted.
+func (qb *QuestionBank) CheckAccessibility() []AccessibilityWarning {
+	var warnings []AccessibilityWarning
+	for i, q := range qb.questions {
+		for _, f := range textFields(q) {
+			for _, msg := range htmlAccessibility(f.text) {
+				warnings = append(warnings, AccessibilityWarning{i + 1, f.name, msg})
+			}
+		}
+
+		if dm, ok := q.(*DropMarker); ok {
+			if dm.img.AltDescription() == "" && dm.img.LongDescription() == "" {
+				warnings = append(warnings, AccessibilityWarning{
+					i + 1,
+					"background image",
+					"Image has no alt or long description",
+				})
+			}
+		}
+	}
+	return warnings
+}
+
+// htmlAccessibility checks the images in the HTML code s.
+func htmlAccessibility(s string) []string {
+	var msgs []string
+	for _, tag := range reImgTag.FindAllString(s, -1) {
+		if reAltAttr.MatchString(tag) || reHiddenAttr.MatchString(tag) {
+			continue
+		}
+
+		src := "with unknown source"
+		if match := reSrcAttr.FindStringSubmatch(tag); match != nil {
+			src = strings.Trim(match[1], `"'`)
+			if len(src) > 40 {
+				src = src[:37] + "..."
+			}
+		}
+		msgs = append(msgs, fmt.Sprintf("Image %s has no alt attribute", src))
+	}
+
+	for _, loc := range reSvgTag.FindAllStringIndex(s, -1) {
+		tag := s[loc[0]:loc[1]]
+		if reLabelAttr.MatchString(tag) || reHiddenAttr.MatchString(tag) {
+			continue
+		}
+
+		// Look for a title element before the svg ends
+		body := s[loc[1]:]
+		if end := strings.Index(strings.ToLower(body), "</svg"); end >= 0 {
+			body = body[:end]
+		}
+		if !strings.Contains(strings.ToLower(body), "<title") {
+			msgs = append(msgs, "Inline svg has no title")
+		}
+	}
+	return msgs
+}
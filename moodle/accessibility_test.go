@@ -0,0 +1,67 @@
+package moodle
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ReneBoedker/MoodlishInquisition/graphics"
+)
+
+func TestHtmlAccessibility(t *testing.T) {
+	testCases := []struct {
+		html      string
+		nWarnings int
+	}{
+		{`<img src="a.png">`, 1},
+		{`<img src="a.png" alt="">`, 0},
+		{`<IMG SRC="a.png" ALT="A">`, 0},
+		{`<img src="a.png" aria-hidden="true">`, 0},
+		{`<svg width="1"><path d="M 0 0"/></svg>`, 1},
+		{`<svg width="1"><title>Plot</title></svg>`, 0},
+		{`<svg aria-label="Plot"></svg>`, 0},
+		{`<svg></svg><svg><title>Plot</title></svg><img src="b.png">`, 2},
+	}
+
+	for _, v := range testCases {
+		if msgs := htmlAccessibility(v.html); len(msgs) != v.nWarnings {
+			t.Errorf("Expected %d warnings for %q, but got %q", v.nWarnings, v.html, msgs)
+		}
+	}
+}
+
+func TestCheckAccessibility(t *testing.T) {
+	img, _ := graphics.ImageFromBytes([]byte("GIF89a"), "gif")
+	f, _ := NewFile("figure", img)
+
+	ans := NewAnswerWithFeedback("True", 100, "See "+f.Html())
+	ans.AddFeedbackFiles(f)
+	mc := NewMultiChoice("Is this true?", 1, []*Answer{ans, NewAnswer("False", 0)})
+
+	dm := NewDropMarker("Place the marker", img, 1, []*Mark{NewMark("A", 1)}, nil)
+
+	qb := NewQuestionBank("Accessibility", []Question{mc, dm})
+	warnings := qb.CheckAccessibility()
+	if len(warnings) != 2 {
+		t.Fatalf("Expected 2 warnings, but got %v", warnings)
+	}
+	if w := warnings[0]; w.Question != 1 || w.Field != "feedback of answer 1" {
+		t.Errorf("Unexpected warning %q", w)
+	}
+	if w := warnings[1]; w.Question != 2 || w.Field != "background image" {
+		t.Errorf("Unexpected warning %q", w)
+	}
+
+	// Describing the image removes the warnings, and the feedback must be
+	// regenerated since it contains the image tag
+	img.SetAltDescription("A figure")
+	ans.SetFeedback("See " + f.Html())
+	if warnings := qb.CheckAccessibility(); len(warnings) != 0 {
+		t.Errorf("Described images produced warnings %v", warnings)
+	}
+
+	var b strings.Builder
+	dm.ToXml(&b)
+	if !strings.Contains(b.String(), `Place the marker<span class="sr-only">A figure</span>`) {
+		t.Errorf("Description of background image was not included in question text")
+	}
+}
@@ -8,10 +8,12 @@ import (
 // Answer describes a possible answer to a question.
 // The object contains related information such as grading and feedback.
 type Answer struct {
-	text     string
-	grade    float64
-	feedback string
-	options  map[string]string
+	text          string
+	files         []*File
+	grade         float64
+	feedback      string
+	feedbackFiles []*File
+	options       map[string]string
 }
 
 // NewAnswer creates a new Answer object.
@@ -34,13 +36,19 @@ func NewAnswerWithFeedback(response string, grade float64, feedback string) *Ans
 	}
 }
 
-// SetGrade sets the answer grade to the given value. It returns an error if
-// grade is not in the interval [-100, 100].
+// SetGrade sets the answer grade to the given value. Moodle only accepts
+// certain grades, such as 50, 33.33333 and 25 (and their negations). If grade
+// only differs from one of these by rounding, the allowed grade is used.
+// Otherwise, an error is returned and the grade is left unchanged.
 func (a *Answer) SetGrade(grade float64) error {
 	if grade < -100 || grade > 100 {
-		return fmt.Errorf("Grade must be between")
+		return fmt.Errorf("Grade must be between -100 and 100, but received %g", grade)
 	}
-	a.grade = grade
+	snapped, ok := snapGrade(grade)
+	if !ok {
+		return fmt.Errorf("Grade %g is not allowed by Moodle (the nearest allowed grade is %g)", grade, snapped)
+	}
+	a.grade = snapped
 	return nil
 }
 
@@ -74,6 +82,18 @@ func (a *Answer) GetFeedback() string {
 	return a.feedback
 }
 
+// AddFiles attaches files to the answer text of a. The files must be referenced
+// in the answer text using their Html method.
+func (a *Answer) AddFiles(files ...*File) {
+	a.files = append(a.files, files...)
+}
+
+// AddFeedbackFiles attaches files to the feedback of a. The files must be
+// referenced in the feedback using their Html method.
+func (a *Answer) AddFeedbackFiles(files ...*File) {
+	a.feedbackFiles = append(a.feedbackFiles, files...)
+}
+
 // ToXml writes an Answer object to Moodle XML format.
 // Note that this XML cannot be imported into Moodle on its own. It should be
 // included in a QuestionBank to do so.
@@ -81,13 +101,16 @@ func (a *Answer) ToXml(w io.Writer) {
 	fmt.Fprintf(w, `
 	<answer fraction="%f">
 		<text><![CDATA[%s]]></text>`, a.grade, a.text)
+	writeFiles(w, a.files, "\t\t")
 	defer fmt.Fprint(w, "\n\t</answer>")
 
 	if a.feedback != "" {
 		fmt.Fprintf(w, `
 		<feedback format="html">
-			<text><![CDATA[%s]]></text>
-		</feedback>`, a.feedback)
+			<text><![CDATA[%s]]></text>`, a.feedback)
+		writeFiles(w, a.feedbackFiles, "\t\t\t")
+		fmt.Fprint(w, `
+		</feedback>`)
 	}
 
 	for k, v := range a.options {
@@ -1,8 +1,10 @@
 package moodle
 
 import (
+	"errors"
 	"fmt"
 	"hash/fnv"
+	"html"
 	"io"
 	"strings"
 
@@ -26,42 +28,18 @@ func NewMark(text string, nDrags uint) *Mark {
 	}
 }
 
-// Zone describes drop zones in the 'Drag and drop markers' question type.
-type Zone struct {
-	shape       string
-	coords      string
-	correctMark int
-}
-
-// NewZone defines a new drop zone with the given parameters.
-// Supported shapes are 'circle' and 'rectangle'. The given coordinates describe
-// the center of the zone. When defining a circle, its diameter will be
-// max(width, height).
-//
-// Note that coords should be specified in relation to the top left corner of
-// the image.
-func NewZone(shape string, coords [2]float64, width, height float64, correctMark int) (*Zone, error) {
-	var coordSpec string
-	shape = strings.ToLower(shape)
-	switch shape {
-	case "circle":
-		coordSpec = fmt.Sprintf("%.0f,%.0f;%.0f", coords[0], coords[1], max(width, height)/2)
-	case "rectangle":
-		coordSpec = fmt.Sprintf("%.0f,%.0f;%.0f,%.0f", coords[0]-width/2, coords[1]-height/2, width, height)
-	default:
-		return nil, fmt.Errorf("Unsupported shape %q", shape)
-	}
-	return &Zone{
-		shape:       shape,
-		coords:      coordSpec,
-		correctMark: correctMark,
-	}, nil
+// NewMathMark creates a marker showing a LaTeX math expression. Markers can
+// only contain plain text, so the expression is converted using
+// graphics.MathToUnicode.
+func NewMathMark(math string, nDrags uint) *Mark {
+	return NewMark(graphics.MathToUnicode(math), nDrags)
 }
 
 // DropMarker implements the 'Drag and drop marker' question type.
 type DropMarker struct {
 	name    string
 	text    string
+	files   []*File
 	img     graphics.Image
 	points  uint
 	shuffle bool
@@ -93,12 +71,83 @@ func (dm *DropMarker) MoodleName() string {
 	return "Drag and drop markers"
 }
 
+// AddFiles attaches files to the question text of dm. The files must be
+// referenced in the question text using their Html method.
+func (dm *DropMarker) AddFiles(files ...*File) {
+	dm.files = append(dm.files, files...)
+}
+
 // SetShuffleAnswers allows enabling or disabling shuffling of answers. The
 // default is to shuffle.
 func (dm *DropMarker) SetShuffleAnswers(b bool) {
 	dm.shuffle = b
 }
 
+// Validate checks that dm can be imported into Moodle, including its geometry.
+// It reports zones that lie outside the image, refer to nonexistent markers, or
+// overlap zones expecting a different marker, as well as markers that cannot be
+// dragged to all their zones. All problems are returned together.
+//
+// Zones are only compared to the image if its size is known.
+func (dm *DropMarker) Validate() error {
+	errs := validateText(dm.text)
+	if len(dm.markers) == 0 || len(dm.zones) == 0 {
+		errs = append(errs, fmt.Errorf("At least one marker and one zone are required"))
+	}
+	for i, m := range dm.markers {
+		if strings.TrimSpace(m.text) == "" {
+			errs = append(errs, fmt.Errorf("Marker %d has no text", i+1))
+		}
+	}
+
+	dim, knownSize := pixelSize(dm.img)
+	needed := make([]uint, len(dm.markers))
+	for i, z := range dm.zones {
+		if knownSize {
+			topLeft, bottomRight := z.bounds()
+			if topLeft[0] < 0 || topLeft[1] < 0 || bottomRight[0] > dim[0] || bottomRight[1] > dim[1] {
+				errs = append(errs, fmt.Errorf("Zone %d is not within the %.0fx%.0f image", i+1, dim[0], dim[1]))
+			}
+		}
+
+		if z.correctMark < 0 || z.correctMark >= len(dm.markers) {
+			errs = append(errs, fmt.Errorf("Zone %d refers to marker %d, but there are %d markers", i+1, z.correctMark+1, len(dm.markers)))
+		} else {
+			needed[z.correctMark]++
+		}
+
+		for j, o := range dm.zones[:i] {
+			if o.correctMark != z.correctMark && z.overlaps(o) {
+				errs = append(errs, fmt.Errorf("Zone %d overlaps zone %d, which expects a different marker", i+1, j+1))
+			}
+		}
+	}
+
+	for i, m := range dm.markers {
+		if m.nDrags != 0 && m.nDrags < needed[i] {
+			errs = append(errs, fmt.Errorf("Marker %d can be dragged %d times, but is needed in %d zones", i+1, m.nDrags, needed[i]))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// backgroundDescription returns HTML describing img to screen readers. Moodle
+// provides no alt attribute for the background image, so the description is
+// added to the question text instead.
+func backgroundDescription(img graphics.Image) string {
+	parts := make([]string, 0, 2)
+	for _, v := range []string{img.AltDescription(), img.LongDescription()} {
+		if v != "" {
+			parts = append(parts, html.EscapeString(v))
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(`<span class="sr-only">%s</span>`, strings.Join(parts, ". "))
+}
+
 // ToXml writes a DropMarker object to Moodle XML format.
 // Note that this XML cannot be imported into Moodle on its own. It should be
 // included in a QuestionBank to do so.
@@ -110,12 +159,15 @@ func (dm *DropMarker) ToXml(w io.Writer) {
 		<text>%s</text>
 	</name>
 	<questiontext format="html">
-		<text><![CDATA[`+"%s"+`]]></text>
+		<text><![CDATA[`+"%s%s"+`]]></text>`,
+		dm.name, dm.text, backgroundDescription(dm.img))
+	writeFiles(w, dm.files, "\t\t")
+	fmt.Fprintf(w, `
 	</questiontext>
-	<defaultgrade>`+"%d"+`</defaultgrade>
+	<defaultgrade>%d</defaultgrade>
 	<showmisplaced/>
 	<file name="figure.%s" encoding="base64">`,
-		dm.name, dm.text, dm.points, dm.img.Filetype())
+		dm.points, dm.img.Filetype())
 	dm.img.ToBase64(w)
 	fmt.Fprint(w, `</file>`)
 	defer fmt.Fprint(w, `
@@ -156,6 +208,6 @@ func (dm *DropMarker) ToXml(w io.Writer) {
 		<coords>%s</coords>
 		<choice>%d</choice>
 	</drop>`,
-			i+1, v.shape, v.coords, v.correctMark+1)
+			i+1, v.shape, v.coords(), v.correctMark+1)
 	}
 }
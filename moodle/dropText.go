@@ -1,9 +1,14 @@
 package moodle
 
 import (
+	"errors"
 	"fmt"
 	"hash/fnv"
 	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ReneBoedker/MoodlishInquisition/graphics"
 )
 
 var _ Question = (*DropText)(nil) // Ensure interface is satisfied
@@ -24,10 +29,18 @@ func NewTextMark(text string, group uint, unlimited bool) *TextMark {
 	}
 }
 
+// NewMathTextMark creates a text marker showing a LaTeX math expression.
+// Markers can only contain plain text, so the expression is converted using
+// graphics.MathToUnicode.
+func NewMathTextMark(math string, group uint, unlimited bool) *TextMark {
+	return NewTextMark(graphics.MathToUnicode(math), group, unlimited)
+}
+
 // DropText implements the 'Drag and drop into text' question type.
 type DropText struct {
 	name    string
 	text    string
+	files   []*File
 	points  uint
 	shuffle bool
 	markers []*TextMark
@@ -57,12 +70,57 @@ func (dt *DropText) MoodleName() string {
 	return "Drag and drop into text"
 }
 
+// AddFiles attaches files to the question text of dt. The files must be
+// referenced in the question text using their Html method.
+func (dt *DropText) AddFiles(files ...*File) {
+	dt.files = append(dt.files, files...)
+}
+
 // SetShuffleAnswers allows enabling or disabling shuffling of answers. The
 // default is to shuffle.
 func (dt *DropText) SetShuffleAnswers(b bool) {
 	dt.shuffle = b
 }
 
+// Validate checks that dt can be imported into Moodle. The description must
+// contain at least one [[n]] placeholder, and each placeholder must refer to an
+// existing marker. Markers used in several placeholders must be unlimited.
+func (dt *DropText) Validate() error {
+	errs := validateText(dt.text)
+	for i, m := range dt.markers {
+		if strings.TrimSpace(m.text) == "" {
+			errs = append(errs, fmt.Errorf("Marker %d has no text", i+1))
+		}
+	}
+
+	uses := make([]int, len(dt.markers))
+	nPlaceholders := 0
+	for _, seg := range segments(dt.text) {
+		if seg.kind != placeholderSegment {
+			continue
+		}
+		nPlaceholders++
+		n, _ := strconv.Atoi(strings.Trim(seg.text, "[]"))
+		if n < 1 || n > len(dt.markers) {
+			errs = append(errs, fmt.Errorf("Placeholder %s refers to a nonexistent marker (there are %d markers)",
+				seg.text, len(dt.markers)))
+			continue
+		}
+		uses[n-1]++
+	}
+	if nPlaceholders == 0 {
+		errs = append(errs, fmt.Errorf("Question text contains no [[n]] placeholders"))
+	}
+
+	for i, m := range dt.markers {
+		if uses[i] > 1 && !m.unlimited {
+			errs = append(errs, fmt.Errorf("Marker %d is used in %d placeholders, but is not unlimited", i+1, uses[i]))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
 // ToXml writes a DropText object to Moodle XML format.
 // Note that this XML cannot be imported into Moodle on its own. It should be
 // included in a QuestionBank to do so.
@@ -74,10 +132,12 @@ func (dt *DropText) ToXml(w io.Writer) {
 		<text>%s</text>
 	</name>
 	<questiontext format="html">
-		<text><![CDATA[`+"%s"+`]]></text>
+		<text><![CDATA[`+"%s"+`]]></text>`,
+		dt.name, dt.text)
+	writeFiles(w, dt.files, "\t\t")
+	fmt.Fprintf(w, `
 	</questiontext>
-	<defaultgrade>`+"%d"+`</defaultgrade>`,
-		dt.name, dt.text, dt.points)
+	<defaultgrade>%d</defaultgrade>`, dt.points)
 	defer fmt.Fprint(w, `
 </question>`)
 
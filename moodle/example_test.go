@@ -327,7 +327,7 @@ func ExampleNewDropMarker() {
 	zones := make([]*moodle.Zone, 2)
 	for i := range zones {
 		zones[i], _ = moodle.NewZone( // Ignoring error-handling for brevity
-			"circle",
+			moodle.Circle,
 			coords[i],
 			xScale*0.4, // Allow some tolerance...
 			yScale*0.4, // ...in both x and y
@@ -380,3 +380,47 @@ func ExampleNewDropMarker() {
 	// 	</drop>
 	// </question>
 }
+
+// Templates allow variants of a question to be generated without building the
+// texts with fmt.Sprintf. Here, the values are drawn using SetData, but they
+// may also be drawn inside the templates using the functions randInt and set.
+func ExampleQuestionTemplate() {
+	qt, err := moodle.NewNumericalTemplate(
+		`Solve \({{.a}}x {{signed .b}} = 0\).`,
+		1,
+	)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	qt.AddAnswer("{{div (mul -1 .b) .a}}", 100, `\(x={{frac (mul -1 .b) .a}}\)`)
+
+	// In practice, use for instance unif.IntInInterval to draw values
+	qt.SetData(func() map[string]any {
+		return map[string]any{"a": 4, "b": -6}
+	})
+
+	q, err := qt.Instantiate()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	q.ToXml(os.Stdout)
+	// Output:
+	// <question type="numerical">
+	// 	<name>
+	// 		<text>EC723B53</text>
+	// 	</name>
+	// 	<questiontext format="html">
+	// 		<text><![CDATA[Solve \(4x -6 = 0\).]]></text>
+	// 	</questiontext>
+	// 	<defaultgrade>1</defaultgrade>
+	// 	<answer fraction="100.000000">
+	// 		<text><![CDATA[1.5]]></text>
+	// 		<feedback format="html">
+	// 			<text><![CDATA[\(x=\frac{3}{2}\)]]></text>
+	// 		</feedback>
+	// 	</answer>
+	// 	<unitgradingtype>0</unitgradingtype>
+	// </question>
+}
@@ -0,0 +1,46 @@
+package moodle
+
+import "fmt"
+
+// textField is a text in a question that may contain HTML, along with the files
+// attached to it.
+type textField struct {
+	name  string // Description of the field, e.g. "answer 2"
+	text  string
+	files []*File
+}
+
+// textFields returns the HTML text fields of q. Question types defined outside
+// this package have no known fields.
+func textFields(q Question) []textField {
+	switch q := q.(type) {
+	case *MultiChoice:
+		return append([]textField{{"question text", q.text, q.files}}, answerFields(q.answers)...)
+	case *Numerical:
+		return append([]textField{{"question text", q.text, q.files}}, answerFields(q.answers)...)
+	case *ShortText:
+		return append([]textField{{"question text", q.text, q.files}}, answerFields(q.answers)...)
+	case *DropText:
+		return []textField{{"question text", q.text, q.files}}
+	case *DropMarker:
+		return []textField{{"question text", q.text, q.files}}
+	}
+	return nil
+}
+
+// answerFields returns the text fields of the given answers, including their
+// feedback.
+func answerFields(answers []*Answer) []textField {
+	fields := make([]textField, 0, 2*len(answers))
+	for i, a := range answers {
+		fields = append(fields, textField{fmt.Sprintf("answer %d", i+1), a.text, a.files})
+		if a.feedback != "" {
+			fields = append(fields, textField{
+				fmt.Sprintf("feedback of answer %d", i+1),
+				a.feedback,
+				a.feedbackFiles,
+			})
+		}
+	}
+	return fields
+}
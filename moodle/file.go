@@ -0,0 +1,99 @@
+package moodle
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/ReneBoedker/MoodlishInquisition/graphics"
+)
+
+// File is an image attached to a text field of a question, such as the
+// question text, an answer, or feedback. Rather than embedding the image in
+// the HTML code, the text refers to the attachment via Moodle's @@PLUGINFILE@@
+// mechanism. This keeps the text small and avoids problems with Moodle's
+// sanitization of inline images.
+//
+// A File must be attached to the text field in which it is referenced. See for
+// instance MultiChoice.AddFiles and Answer.AddFiles.
+type File struct {
+	name string
+	img  graphics.Image
+}
+
+// NewFile creates a new attachment containing img. The file extension is added
+// to name unless it is already present. An error is returned if name is empty
+// or contains a slash or characters reserved in XML.
+func NewFile(name string, img graphics.Image) (*File, error) {
+	if name == "" || strings.ContainsAny(name, `/\"<>&`) {
+		return nil, fmt.Errorf("Invalid file name %q", name)
+	}
+
+	ext := "." + img.Filetype()
+	if !strings.HasSuffix(strings.ToLower(name), ext) {
+		name += ext
+	}
+
+	return &File{
+		name: name,
+		img:  img,
+	}, nil
+}
+
+// Name returns the file name of f.
+func (f *File) Name() string {
+	return f.name
+}
+
+// Html returns an HTML image tag referring to f. Include this in the text field
+// to which f is attached.
+func (f *File) Html() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<img src="@@PLUGINFILE@@/%s"`, url.PathEscape(f.name))
+	if alt := f.img.AltDescription(); alt != "" {
+		fmt.Fprintf(&b, ` alt="%s"`, html.EscapeString(alt))
+	}
+	if title := f.img.Title(); title != "" {
+		fmt.Fprintf(&b, ` title="%s"`, html.EscapeString(title))
+	}
+
+	if dim, ok := pixelSize(f.img); ok {
+		fmt.Fprintf(&b, ` width="%.1f" height="%.1f"`, dim[0], dim[1])
+	}
+
+	fmt.Fprint(&b, ` />`)
+	return b.String()
+}
+
+// pixelSize returns the width and height of img in pixels. If the size is
+// unknown, ok is false. The size of svg images is converted to px, as in
+// SvgImage.ToBase64.
+func pixelSize(img graphics.Image) (dim [2]float64, ok bool) {
+	switch img := img.(type) {
+	case *graphics.SvgImage:
+		dim, err := img.DimensionIn(graphics.Px)
+		return dim, err == nil
+	case interface{ GetDimension() [2]float64 }:
+		dim = img.GetDimension()
+	}
+	return dim, dim[0] > 0 && dim[1] > 0
+}
+
+// ToXml writes f to Moodle XML format.
+// Note that this XML cannot be imported into Moodle on its own. It is included
+// in the text field to which f is attached.
+func (f *File) ToXml(w io.Writer) {
+	fmt.Fprintf(w, `<file name="%s" path="/" encoding="base64">`, f.name)
+	f.img.ToBase64(w)
+	fmt.Fprint(w, `</file>`)
+}
+
+// writeFiles writes the given attachments using the specified indentation.
+func writeFiles(w io.Writer, files []*File, indent string) {
+	for _, f := range files {
+		fmt.Fprintf(w, "\n%s", indent)
+		f.ToXml(w)
+	}
+}
@@ -0,0 +1,81 @@
+package moodle
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/ReneBoedker/MoodlishInquisition/graphics"
+)
+
+func TestFiles(t *testing.T) {
+	img, _ := graphics.ImageFromBytes([]byte("GIF89a"), "gif")
+
+	if _, err := NewFile("../figure", img); err == nil {
+		t.Errorf("Invalid file name failed to return an error")
+	}
+
+	f, err := NewFile("figure", img)
+	if err != nil {
+		t.Fatalf("Creating file caused error: %s", err)
+	}
+	if f.Name() != "figure.gif" {
+		t.Errorf("Expected file name %q, but got %q", "figure.gif", f.Name())
+	}
+	if f.Html() != `<img src="@@PLUGINFILE@@/figure.gif" />` {
+		t.Errorf("Unexpected HTML %q", f.Html())
+	}
+
+	// Svg sizes are converted to px, matching the attached file
+	for _, v := range []struct{ svg, size string }{
+		{`<svg width="75pt" height="30pt"></svg>`, ` width="100.0" height="40.0"`},
+		{`<svg width="50.8mm" height="1in"></svg>`, ` width="192.0" height="96.0"`},
+	} {
+		svg, _ := graphics.SvgFromBytes([]byte(v.svg))
+		if svgFile, _ := NewFile("plot", svg); !strings.Contains(svgFile.Html(), v.size) {
+			t.Errorf("Unexpected HTML %q for %s", svgFile.Html(), v.svg)
+		}
+	}
+
+	ans := NewAnswerWithFeedback(f.Html(), 100, "See "+f.Html())
+	ans.AddFiles(f)
+	ans.AddFeedbackFiles(f)
+	q := NewMultiChoice("Which figure? "+f.Html(), 1, []*Answer{ans})
+	q.AddFiles(f)
+
+	var b strings.Builder
+	q.ToXml(&b)
+
+	var parsed struct {
+		QuestionText struct {
+			Files []string `xml:"file"`
+		} `xml:"questiontext"`
+		Answer struct {
+			Files    []string `xml:"file"`
+			Feedback struct {
+				Files []string `xml:"file"`
+			} `xml:"feedback"`
+		} `xml:"answer"`
+	}
+	if err := xml.Unmarshal([]byte(b.String()), &parsed); err != nil {
+		t.Fatalf("Decoding XML output produced error: %s", err)
+	}
+
+	for field, files := range map[string][]string{
+		"question text": parsed.QuestionText.Files,
+		"answer":        parsed.Answer.Files,
+		"feedback":      parsed.Answer.Feedback.Files,
+	} {
+		if len(files) != 1 || files[0] != "R0lGODlh" {
+			t.Errorf("Unexpected files %q attached to the %s", files, field)
+		}
+	}
+}
+
+func TestFileAttacher(t *testing.T) {
+	for _, q := range []Question{&MultiChoice{}, &ShortText{}, &Numerical{}, &DropText{}, &DropMarker{}} {
+		if _, ok := q.(FileAttacher); !ok {
+			t.Errorf("%T does not implement FileAttacher", q)
+		}
+	}
+}
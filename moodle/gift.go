@@ -0,0 +1,470 @@
+package moodle
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// giftEscaper escapes the characters with a special meaning in GIFT.
+var giftEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`~`, `\~`,
+	`=`, `\=`,
+	`#`, `\#`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`:`, `\:`,
+	"\n", `\n`,
+)
+
+// giftEscape escapes s for inclusion in a GIFT file.
+func giftEscape(s string) string {
+	return giftEscaper.Replace(s)
+}
+
+// giftUnescape reverses giftEscape.
+func giftUnescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case '\\', '~', '=', '#', '{', '}', ':', '%':
+			b.WriteByte(s[i])
+		case 'n':
+			b.WriteByte('\n')
+		default:
+			// Not an escape sequence, e.g. a LaTeX macro
+			b.WriteByte('\\')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// ToGift writes the questions of qb in the GIFT format. Only the question types
+// MultiChoice, ShortText and Numerical can be written. GIFT has no notion of
+// points or attached files, so an error is returned if qb contains other
+// question types or files. In that case, nothing is written.
+func (qb *QuestionBank) ToGift(w io.Writer) error {
+	for i, q := range qb.questions {
+		switch q.(type) {
+		case *MultiChoice, *ShortText, *Numerical:
+		default:
+			return fmt.Errorf("Question %d: Question type %q cannot be written in GIFT", i+1, q.MoodleName())
+		}
+		for _, f := range textFields(q) {
+			if len(f.files) > 0 {
+				return fmt.Errorf("Question %d: Files attached to the %s cannot be written in GIFT", i+1, f.name)
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "$CATEGORY: $module$/%s\n", qb.name)
+	for _, q := range qb.questions {
+		fmt.Fprint(w, "\n")
+		switch q := q.(type) {
+		case *MultiChoice:
+			single := !q.forceMultiple && q.NCorrect() == 1
+			writeGiftQuestion(w, q.name, q.text, q.answers, "", func(a *Answer) string {
+				return giftAnswer(a, single && a.grade == 100, giftEscape(a.text))
+			})
+		case *ShortText:
+			writeGiftQuestion(w, q.name, q.text, q.answers, "", func(a *Answer) string {
+				return giftAnswer(a, true, giftEscape(a.text))
+			})
+		case *Numerical:
+			writeGiftQuestion(w, q.name, q.text, q.answers, "#", func(a *Answer) string {
+				text := giftEscape(a.text)
+				if tol, ok := a.GetOption("tolerance"); ok {
+					text += ":" + giftEscape(tol)
+				}
+				return giftAnswer(a, true, text)
+			})
+		}
+	}
+	return nil
+}
+
+// writeGiftQuestion writes a question in the GIFT format. The answers are
+// formatted by answer and written after the given prefix.
+func writeGiftQuestion(w io.Writer, name, text string, answers []*Answer, prefix string, answer func(*Answer) string) {
+	fmt.Fprintf(w, "::%s::[html]%s {%s\n", giftEscape(html.UnescapeString(name)), giftEscape(text), prefix)
+	for _, a := range answers {
+		fmt.Fprintf(w, "\t%s\n", answer(a))
+	}
+	fmt.Fprint(w, "}\n")
+}
+
+// giftAnswer formats a in the GIFT format using the given text, which must
+// already be escaped. If equals is set, the answer is marked by =, and its
+// grade is given unless it is 100. Otherwise, it is marked by ~, and its grade
+// is given unless it is 0.
+func giftAnswer(a *Answer, equals bool, text string) string {
+	var b strings.Builder
+	switch {
+	case equals && a.grade == 100:
+		b.WriteString("=")
+	case equals:
+		fmt.Fprintf(&b, "=%%%g%%", a.grade)
+	case a.grade == 0:
+		b.WriteString("~")
+	default:
+		fmt.Fprintf(&b, "~%%%g%%", a.grade)
+	}
+	if strings.HasPrefix(text, "%") {
+		// Otherwise, the text would be read as a grade
+		b.WriteString(`\`)
+	}
+	b.WriteString(text)
+
+	if a.feedback != "" {
+		fmt.Fprintf(&b, "#%s", giftEscape(a.feedback))
+	}
+	return b.String()
+}
+
+// ParseGift reads questions in the GIFT format from r. The question types
+// multiple choice, short answer and numerical are supported. Questions of
+// other types, such as true/false and matching questions, have no equivalent
+// in this package and are skipped. The returned error describes each
+// question that could not be read.
+//
+// Question texts in other formats than HTML are escaped. Missing word
+// questions, where the answers are placed inside the text, get a blank line
+// in place of the answers.
+func ParseGift(r io.Reader) ([]Question, error) {
+	var questions []Question
+	var errs []error
+
+	blocks, err := giftBlocks(r)
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range blocks {
+		q, err := parseGiftQuestion(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("Question %d: %w", i+1, err))
+			continue
+		}
+		questions = append(questions, q)
+	}
+	return questions, errors.Join(errs...)
+}
+
+// giftBlocks splits the GIFT file read from r into questions, which are
+// separated by blank lines. Comments and category commands are removed.
+func giftBlocks(r io.Reader) ([]string, error) {
+	var blocks []string
+	var current strings.Builder
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "//"), strings.HasPrefix(trimmed, "$CATEGORY:"):
+			continue
+		case trimmed == "":
+			if strings.TrimSpace(current.String()) != "" {
+				blocks = append(blocks, current.String())
+			}
+			current.Reset()
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+	}
+	if strings.TrimSpace(current.String()) != "" {
+		blocks = append(blocks, current.String())
+	}
+	return blocks, scanner.Err()
+}
+
+// indexUnescaped returns the index of the first occurrence of substr in s that
+// is not escaped by a backslash, or -1 if there is none.
+func indexUnescaped(s, substr string) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.HasPrefix(s[i:], substr) {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseGiftQuestion parses a single question in the GIFT format.
+func parseGiftQuestion(s string) (Question, error) {
+	s = strings.TrimSpace(s)
+
+	// Read the title
+	var title string
+	if strings.HasPrefix(s, "::") {
+		end := indexUnescaped(s[2:], "::")
+		if end < 0 {
+			return nil, fmt.Errorf("Title is not terminated by ::")
+		}
+		title = strings.TrimSpace(giftUnescape(s[2 : 2+end]))
+		s = strings.TrimSpace(s[4+end:])
+	}
+
+	// Read the text format
+	isHtml := false
+	if strings.HasPrefix(s, "[") {
+		if end := strings.Index(s, "]"); end > 0 {
+			isHtml = s[1:end] == "html"
+			s = s[end+1:]
+		}
+	}
+
+	start := indexUnescaped(s, "{")
+	if start < 0 {
+		return nil, fmt.Errorf("Question has no answers")
+	}
+	end := indexUnescaped(s[start:], "}")
+	if end < 0 {
+		return nil, fmt.Errorf("Answers are not terminated by }")
+	}
+	end += start
+
+	text := strings.TrimSpace(s[:start])
+	if after := strings.TrimSpace(s[end+1:]); after != "" {
+		// Missing word format
+		text += " _____ " + after
+	}
+	text = giftUnescape(text)
+	if !isHtml {
+		text = html.EscapeString(text)
+	}
+
+	body := strings.TrimSpace(s[start+1 : end])
+	if i := indexUnescaped(body, "####"); i >= 0 {
+		// Remove general feedback
+		body = strings.TrimSpace(body[:i])
+	}
+
+	var q Question
+	var err error
+	switch upper := strings.ToUpper(body); {
+	case body == "":
+		return nil, fmt.Errorf("Essay questions are not supported")
+	case upper == "T" || upper == "F" || upper == "TRUE" || upper == "FALSE" ||
+		strings.HasPrefix(upper, "T#") || strings.HasPrefix(upper, "F#") ||
+		strings.HasPrefix(upper, "TRUE#") || strings.HasPrefix(upper, "FALSE#"):
+		return nil, fmt.Errorf("True/false questions are not supported")
+	case indexUnescaped(body, "->") >= 0:
+		return nil, fmt.Errorf("Matching questions are not supported")
+	case body[0] == '#':
+		q, err = parseGiftNumerical(text, body[1:], isHtml)
+	case indexUnescaped(body, "~") >= 0:
+		q, err = parseGiftMultiChoice(text, body, isHtml)
+	default:
+		q, err = parseGiftShortText(text, body, isHtml)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if title != "" {
+		setQuestionName(q, html.EscapeString(title))
+	}
+	return q, nil
+}
+
+// setQuestionName sets the name of q, which must be one of the types returned
+// by parseGiftQuestion.
+func setQuestionName(q Question, name string) {
+	switch q := q.(type) {
+	case *MultiChoice:
+		q.name = name
+	case *ShortText:
+		q.name = name
+	case *Numerical:
+		q.name = name
+	}
+}
+
+// giftAnswerPart is an answer as written in a GIFT file.
+type giftAnswerPart struct {
+	equals   bool   // Whether the answer is marked by = rather than ~
+	grade    string // The grade given by %n%, if any
+	text     string // Still escaped
+	feedback string
+}
+
+// parseGiftAnswers splits the answers of a question into their parts.
+func parseGiftAnswers(s string) ([]giftAnswerPart, error) {
+	var parts []giftAnswerPart
+	s = strings.TrimSpace(s)
+	for s != "" {
+		if s[0] != '=' && s[0] != '~' {
+			return nil, fmt.Errorf("Expected answer starting with = or ~, but found %q", s)
+		}
+		p := giftAnswerPart{equals: s[0] == '='}
+		s = s[1:]
+
+		end := len(s)
+		for _, v := range []string{"=", "~"} {
+			if i := indexUnescaped(s, v); i >= 0 && i < end {
+				end = i
+			}
+		}
+		answer := strings.TrimSpace(s[:end])
+		s = strings.TrimSpace(s[end:])
+
+		if strings.HasPrefix(answer, "%") {
+			i := strings.Index(answer[1:], "%")
+			if i < 0 {
+				return nil, fmt.Errorf("Grade of answer %q is not terminated by %%", answer)
+			}
+			p.grade = answer[1 : 1+i]
+			answer = answer[2+i:]
+		}
+		if i := indexUnescaped(answer, "#"); i >= 0 {
+			p.feedback = strings.TrimSpace(giftUnescape(answer[i+1:]))
+			answer = answer[:i]
+		}
+		p.text = strings.TrimSpace(answer)
+		parts = append(parts, p)
+	}
+	return parts, nil
+}
+
+// giftGrade returns the grade of p. If no grade is given, answers marked by =
+// get grade 100, and answers marked by ~ get grade 0.
+func (p giftAnswerPart) giftGrade() (float64, error) {
+	if p.grade == "" {
+		if p.equals {
+			return 100, nil
+		}
+		return 0, nil
+	}
+	grade, err := strconv.ParseFloat(p.grade, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid grade %q", p.grade)
+	}
+	return grade, nil
+}
+
+// toAnswer converts p into an Answer with the given text. Unless isHtml is
+// set, the feedback is escaped.
+func (p giftAnswerPart) toAnswer(text string, isHtml bool) (*Answer, error) {
+	grade, err := p.giftGrade()
+	if err != nil {
+		return nil, err
+	}
+	feedback := p.feedback
+	if !isHtml {
+		feedback = html.EscapeString(feedback)
+	}
+	return NewAnswerWithFeedback(text, grade, feedback), nil
+}
+
+// parseGiftMultiChoice creates a multiple choice question from the answers in
+// body. As in Moodle, multiple answers are allowed unless an answer is marked
+// by =. The answers and feedback are treated as HTML if isHtml is set.
+func parseGiftMultiChoice(text, body string, isHtml bool) (*MultiChoice, error) {
+	parts, err := parseGiftAnswers(body)
+	if err != nil {
+		return nil, err
+	}
+
+	answers := make([]*Answer, len(parts))
+	single := false
+	for i, p := range parts {
+		single = single || p.equals
+		answer := giftUnescape(p.text)
+		if !isHtml {
+			answer = html.EscapeString(answer)
+		}
+		if answers[i], err = p.toAnswer(answer, isHtml); err != nil {
+			return nil, err
+		}
+	}
+
+	q := NewMultiChoice(text, 1, answers)
+	q.ForceAllowMultiple(!single)
+	return q, nil
+}
+
+// parseGiftShortText creates a short answer question from the answers in body.
+// The feedback is treated as HTML if isHtml is set.
+func parseGiftShortText(text, body string, isHtml bool) (*ShortText, error) {
+	parts, err := parseGiftAnswers(body)
+	if err != nil {
+		return nil, err
+	}
+
+	answers := make([]*Answer, len(parts))
+	for i, p := range parts {
+		if answers[i], err = p.toAnswer(giftUnescape(p.text), isHtml); err != nil {
+			return nil, err
+		}
+	}
+	return NewShortText(text, 1, answers), nil
+}
+
+// parseGiftNumerical creates a numerical question from the answers in body,
+// which follows the initial #. Answers may be given as value:tolerance or as
+// ranges min..max. The feedback is treated as HTML if isHtml is set.
+func parseGiftNumerical(text, body string, isHtml bool) (*Numerical, error) {
+	body = strings.TrimSpace(body)
+	if body != "" && body[0] != '=' && body[0] != '~' {
+		// A single answer without =
+		body = "=" + body
+	}
+	parts, err := parseGiftAnswers(body)
+	if err != nil {
+		return nil, err
+	}
+
+	answers := make([]*Answer, len(parts))
+	for i, p := range parts {
+		value, tol, err := parseGiftNumber(p.text)
+		if err != nil {
+			return nil, err
+		}
+		if answers[i], err = p.toAnswer(value, isHtml); err != nil {
+			return nil, err
+		}
+		if tol != "" {
+			answers[i].SetOption("tolerance", tol)
+		}
+	}
+	return NewNumerical(text, 1, answers), nil
+}
+
+// parseGiftNumber parses a numerical answer of the form value, value:tolerance
+// or min..max.
+func parseGiftNumber(s string) (value, tol string, err error) {
+	s = strings.TrimSpace(giftUnescape(s))
+	if min, max, ok := strings.Cut(s, ".."); ok {
+		lo, err1 := strconv.ParseFloat(strings.TrimSpace(min), 64)
+		hi, err2 := strconv.ParseFloat(strings.TrimSpace(max), 64)
+		if err1 != nil || err2 != nil {
+			return "", "", fmt.Errorf("Invalid range %q", s)
+		}
+		return strconv.FormatFloat((lo+hi)/2, 'g', -1, 64), strconv.FormatFloat((hi-lo)/2, 'g', -1, 64), nil
+	}
+
+	value, tol, _ = strings.Cut(s, ":")
+	value, tol = strings.TrimSpace(value), strings.TrimSpace(tol)
+	if _, err := strconv.ParseFloat(value, 64); err != nil {
+		return "", "", fmt.Errorf("Invalid number %q", value)
+	}
+	if _, err := strconv.ParseFloat(tol, 64); tol != "" && err != nil {
+		return "", "", fmt.Errorf("Invalid tolerance %q", tol)
+	}
+	return value, tol, nil
+}
@@ -0,0 +1,136 @@
+package moodle
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGiftEscape(t *testing.T) {
+	s := "a~b=c#d{e}f:g\\(x\\)\nh"
+	escaped := giftEscape(s)
+	if escaped != `a\~b\=c\#d\{e\}f\:g\\(x\\)\nh` {
+		t.Errorf("Unexpected escaping %q", escaped)
+	}
+	if unescaped := giftUnescape(escaped); unescaped != s {
+		t.Errorf("Unescaping gave %q, but expected %q", unescaped, s)
+	}
+	if got := giftUnescape(`\frac{1}{2}`); got != `\frac{1}{2}` {
+		t.Errorf("Unescaping changed LaTeX macro into %q", got)
+	}
+}
+
+func TestToGift(t *testing.T) {
+	mc := NewMultiChoice("What is 1+1?", 1, []*Answer{
+		NewAnswerWithFeedback("2", 100, "Yes: correct"),
+		NewAnswer("3", 0),
+		NewAnswer("1", -50),
+	})
+	multi := NewMultiChoice("Which are even?", 1, []*Answer{
+		NewAnswer("2", 50),
+		NewAnswer("4", 50),
+		NewAnswer("5", 0),
+	})
+	st := NewShortText("Name a {colour}", 1, []*Answer{NewAnswer("red", 100), NewAnswer("pink", 50), NewAnswer("%50 red", 0)})
+	num := NewNumerical(`Compute \(\pi\)`, 1, []*Answer{NewAnswer("3.14", 100), NewAnswer("3", 50)})
+	num.answers[0].SetOption("tolerance", "0.01")
+
+	var b strings.Builder
+	if err := NewQuestionBank("Test", []Question{mc, multi, st, num}).ToGift(&b); err != nil {
+		t.Fatalf("Writing GIFT caused error: %s", err)
+	}
+	out := b.String()
+	for _, v := range []string{
+		"$CATEGORY: $module$/Test\n",
+		"[html]What is 1+1? {\n\t=2#Yes\\: correct\n\t~3\n\t~%-50%1\n}\n",
+		"[html]Which are even? {\n\t~%50%2\n\t~%50%4\n\t~5\n}\n",
+		"[html]Name a \\{colour\\} {\n\t=red\n\t=%50%pink\n\t=%0%\\%50 red\n}\n",
+		"[html]Compute \\\\(\\\\pi\\\\) {#\n\t=3.14:0.01\n\t=%50%3\n}\n",
+	} {
+		if !strings.Contains(out, v) {
+			t.Errorf("GIFT does not contain %q:\n%s", v, out)
+		}
+	}
+
+	// Read the questions back
+	questions, err := ParseGift(strings.NewReader(out))
+	if err != nil {
+		t.Fatalf("Parsing GIFT caused error: %s", err)
+	}
+	if len(questions) != 4 {
+		t.Fatalf("Expected 4 questions, but got %d", len(questions))
+	}
+	for i, q := range []Question{mc, multi, st, num} {
+		var want, got strings.Builder
+		q.ToXml(&want)
+		questions[i].ToXml(&got)
+		if want.String() != got.String() {
+			t.Errorf("Question %d changed when written to GIFT and read back:\n%s\n%s", i+1, want.String(), got.String())
+		}
+	}
+
+	dt := NewDropText("A [[1]]", 1, []*TextMark{NewTextMark("cat", 0, false)})
+	if err := NewQuestionBank("Test", []Question{dt}).ToGift(&b); err == nil {
+		t.Errorf("Unsupported question type failed to return an error")
+	}
+}
+
+func TestParseGift(t *testing.T) {
+	input := `// A comment
+::Capital::What is the capital of France? {
+	=Paris#Correct
+	~%-50%Lyon
+	~Marseille
+}
+
+Who's buried in Grant's tomb?{=Grant =%50%Ulysses S. Grant}
+
+::Pi:: Give \(\pi\) to two decimals {#3.14:0.005}
+
+::Range:: Pick a number between 1 and 5 {#1..5}
+
+The sky is {~red ~%50%grey ~%50%blue} on Earth.
+
+Grant is buried in Grant's tomb.{F}
+
+Match {=a -> 1 =b -> 2}
+`
+	questions, err := ParseGift(strings.NewReader(input))
+	if err == nil {
+		t.Errorf("Unsupported questions failed to return an error")
+	} else {
+		for _, v := range []string{"Question 6: True/false", "Question 7: Matching"} {
+			if !strings.Contains(err.Error(), v) {
+				t.Errorf("Error does not contain %q:\n%s", v, err)
+			}
+		}
+	}
+	if len(questions) != 5 {
+		t.Fatalf("Expected 5 questions, but got %d", len(questions))
+	}
+
+	mc, ok := questions[0].(*MultiChoice)
+	if !ok || mc.name != "Capital" || mc.text != "What is the capital of France?" ||
+		len(mc.answers) != 3 || mc.answers[0].feedback != "Correct" || mc.answers[1].grade != -50 || mc.forceMultiple {
+		t.Errorf("Unexpected multiple choice question %+v", questions[0])
+	}
+
+	st, ok := questions[1].(*ShortText)
+	if !ok || st.text != "Who&#39;s buried in Grant&#39;s tomb?" || st.answers[1].text != "Ulysses S. Grant" || st.answers[1].grade != 50 {
+		t.Errorf("Unexpected short answer question %+v", questions[1])
+	}
+
+	num, ok := questions[2].(*Numerical)
+	if tol, _ := num.answers[0].GetOption("tolerance"); !ok || num.text != `Give \(\pi\) to two decimals` || num.answers[0].text != "3.14" || tol != "0.005" {
+		t.Errorf("Unexpected numerical question %+v", questions[2])
+	}
+
+	num, ok = questions[3].(*Numerical)
+	if tol, _ := num.answers[0].GetOption("tolerance"); !ok || num.answers[0].text != "3" || tol != "2" {
+		t.Errorf("Range was not converted: %+v", num.answers[0])
+	}
+
+	mc, ok = questions[4].(*MultiChoice)
+	if !ok || mc.text != "The sky is _____ on Earth." || !mc.forceMultiple {
+		t.Errorf("Unexpected missing word question %+v", questions[4])
+	}
+}
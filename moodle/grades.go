@@ -0,0 +1,134 @@
+package moodle
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// moodleGrades lists the positive grades (in percent) that Moodle accepts for
+// answers. Negative grades must be the negation of one of these.
+var moodleGrades = [...]float64{
+	100, 90, 83.33333, 80, 75, 70, 66.66667, 60, 50, 40, 33.33333, 30, 25, 20,
+	16.66667, 14.28571, 12.5, 11.11111, 10, 5,
+}
+
+// gradeTolerance is the largest difference (in percent) between a grade and an
+// allowed grade for which the grade is snapped to the allowed one.
+const gradeTolerance = 1e-3
+
+// nearestGrade returns the grade allowed by Moodle that is closest to grade.
+func nearestGrade(grade float64) float64 {
+	nearest := 0.0
+	for _, v := range moodleGrades {
+		if math.Abs(math.Abs(grade)-v) < math.Abs(math.Abs(grade)-nearest) {
+			nearest = v
+		}
+	}
+	return math.Copysign(nearest, grade)
+}
+
+// snapGrade returns the allowed grade matching grade up to rounding. If no
+// such grade exists, ok is false.
+func snapGrade(grade float64) (snapped float64, ok bool) {
+	snapped = nearestGrade(grade)
+	return snapped, math.Abs(snapped-grade) <= gradeTolerance
+}
+
+// validateAnswers checks that the answers have a text and a grade accepted by
+// Moodle. Grades that only differ from an allowed grade by rounding are
+// snapped to the allowed grade. If allowNegative is false, negative grades are
+// rejected.
+func validateAnswers(answers []*Answer, allowNegative bool) []error {
+	var errs []error
+	for i, a := range answers {
+		if strings.TrimSpace(a.text) == "" {
+			errs = append(errs, fmt.Errorf("Answer %d has no text", i+1))
+		}
+
+		if a.grade < 0 && !allowNegative {
+			errs = append(errs, fmt.Errorf("Answer %d has negative grade %g", i+1, a.grade))
+			continue
+		}
+		if g, ok := snapGrade(a.grade); ok {
+			a.grade = g
+		} else {
+			errs = append(errs, fmt.Errorf("Grade %g of answer %d is not allowed by Moodle (the nearest allowed grade is %g)",
+				a.grade, i+1, g))
+		}
+	}
+	return errs
+}
+
+// hasFullGrade reports whether one of the answers has grade 100.
+func hasFullGrade(answers []*Answer) bool {
+	for _, a := range answers {
+		if a.grade == 100 {
+			return true
+		}
+	}
+	return false
+}
+
+// validateText checks that the question text s is not empty.
+func validateText(s string) []error {
+	if strings.TrimSpace(s) == "" {
+		return []error{fmt.Errorf("Question text is empty")}
+	}
+	return nil
+}
+
+// GradeRounding describes an answer whose grade was rounded to a value allowed
+// by Moodle.
+type GradeRounding struct {
+	Answer    int     // Index of the answer
+	Requested float64 // The exact grade
+	Grade     float64 // The grade used instead
+}
+
+// String returns a human-readable description of the rounding.
+func (r GradeRounding) String() string {
+	return fmt.Sprintf("Answer %d: Grade %g was rounded to %g", r.Answer+1, r.Requested, r.Grade)
+}
+
+// splitGrade returns n grades allowed by Moodle that add up to 100 and are as
+// equal as possible. The grades are sorted in decreasing order.
+func splitGrade(n int) ([]float64, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("Cannot split the grade between %d answers", n)
+	}
+
+	// Use the allowed grades just above and below the exact share
+	exact := 100 / float64(n)
+	lower, upper := 0.0, 100.0
+	for _, v := range moodleGrades {
+		if v <= exact+gradeTolerance {
+			lower = max(lower, v)
+		}
+		if v >= exact-gradeTolerance {
+			upper = min(upper, v)
+		}
+	}
+
+	grades := make([]float64, n)
+	if upper-lower <= 2*gradeTolerance {
+		for i := range grades {
+			grades[i] = upper
+		}
+		return grades, nil
+	}
+
+	// Find k such that k*upper + (n-k)*lower = 100
+	k := (100 - float64(n)*lower) / (upper - lower)
+	if lower == 0 || math.Abs(k-math.Round(k)) > 1e-6 {
+		return nil, fmt.Errorf("Moodle allows no grades for splitting 100 between %d answers", n)
+	}
+	for i := range grades {
+		if i < int(math.Round(k)) {
+			grades[i] = upper
+		} else {
+			grades[i] = lower
+		}
+	}
+	return grades, nil
+}
@@ -1,13 +1,21 @@
 package moodle
 
-import "strings"
+import (
+	"html"
+	"regexp"
+	"strings"
+)
 
 // EscapeMath ensures that LaTeX math is not interpreted as HTML code when
 // imported into Moodle.
+//
+// The entire string is escaped, so it should only contain math. To escape the
+// math in a text that also contains HTML or [[n]] placeholders, use
+// EscapeMathIn.
 func EscapeMath(s string) string {
 	replacements := [...][2]string{
-		{`[`, `&#91`},
-		{`]`, `&#93`},
+		{`[`, `&#91;`},
+		{`]`, `&#93;`},
 		{`<`, `\lt `},
 		{`>`, `\gt `},
 	}
@@ -17,3 +25,107 @@ func EscapeMath(s string) string {
 	}
 	return s
 }
+
+// EscapeMathIn applies EscapeMath to the math in s, which must be delimited by
+// $$...$$, \(...\) or \[...\]. HTML tags, [[n]] placeholders and other text are
+// left unchanged.
+func EscapeMathIn(s string) string {
+	var b strings.Builder
+	for _, seg := range segments(s) {
+		if seg.kind != mathSegment {
+			b.WriteString(seg.text)
+			continue
+		}
+		// All delimiters consist of two characters
+		n := len(seg.text)
+		b.WriteString(seg.text[:2])
+		b.WriteString(EscapeMath(seg.text[2 : n-2]))
+		b.WriteString(seg.text[n-2:])
+	}
+	return b.String()
+}
+
+// EscapeText escapes the plain text s for inclusion in HTML. Besides the
+// special characters of HTML, characters that Moodle or MathJax would
+// interpret as placeholders or math are escaped, so s is displayed literally.
+func EscapeText(s string) string {
+	return strings.NewReplacer(
+		`$`, `&#36;`,
+		`\`, `&#92;`,
+		`[`, `&#91;`,
+		`]`, `&#93;`,
+	).Replace(html.EscapeString(s))
+}
+
+// segmentKind is the type of a segment of a text.
+type segmentKind int
+
+const (
+	textSegment segmentKind = iota
+	htmlSegment
+	mathSegment
+	placeholderSegment
+)
+
+// segment is a part of a text, as split by segments.
+type segment struct {
+	kind segmentKind
+	text string // Including delimiters
+}
+
+var reSegmentStart = regexp.MustCompile(`\\\$|\$\$|\\\(|\\\[|<[a-zA-Z/!?]|\[\[\d+\]\]`)
+
+// mathClosers maps opening math delimiters to the closing ones.
+var mathClosers = map[string]string{`$$`: `$$`, `\(`: `\)`, `\[`: `\]`}
+
+// segments splits s into HTML tags, math, [[n]] placeholders and other text.
+// Math that is not closed is treated as text.
+func segments(s string) []segment {
+	var segs []segment
+	add := func(kind segmentKind, text string) {
+		if text == "" {
+			return
+		}
+		if n := len(segs); n > 0 && kind == textSegment && segs[n-1].kind == textSegment {
+			segs[n-1].text += text
+			return
+		}
+		segs = append(segs, segment{kind, text})
+	}
+
+	for len(s) > 0 {
+		loc := reSegmentStart.FindStringIndex(s)
+		if loc == nil {
+			add(textSegment, s)
+			break
+		}
+		add(textSegment, s[:loc[0]])
+		token, rest := s[loc[0]:loc[1]], s[loc[0]:]
+
+		kind, n := textSegment, len(token)
+		switch {
+		case token == `\$`:
+		case token[0] == '[':
+			kind = placeholderSegment
+		case token[0] == '<':
+			kind, n = htmlSegment, len(rest)
+			end := ">"
+			if strings.HasPrefix(rest, "<!--") {
+				end = "-->"
+			}
+			if i := strings.Index(rest[1:], end); i >= 0 {
+				n = 1 + i + len(end)
+			}
+		default:
+			closer := mathClosers[token]
+			if i := strings.Index(rest[len(token):], closer); i >= 0 {
+				kind, n = mathSegment, len(token)+i+len(closer)
+			}
+		}
+
+		add(kind, rest[:n])
+		s = rest[n:]
+	}
+
+	return segs
+}
@@ -0,0 +1,511 @@
+package moodle
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ReneBoedker/MoodlishInquisition/graphics"
+)
+
+// latexPreamble is the beginning of the documents written by ToLatex. The
+// macros \lt and \gt are inserted by EscapeMath.
+const latexPreamble = `\documentclass[11pt]{exam}
+\usepackage[utf8]{inputenc}
+\usepackage[T1]{fontenc}
+\usepackage{amsmath,amssymb}
+\usepackage{graphicx}
+\usepackage{tikz}
+\providecommand{\lt}{<}
+\providecommand{\gt}{>}
+`
+
+// ToLatex writes qb as a LaTeX document using the exam class, e.g. for printing
+// the questions for a paper exam. The HTML of the question texts is converted
+// into LaTeX, while math is copied unchanged.
+//
+// Images are written to the folder dir as separate files, which are included by
+// the document using their names. Hence, the document must be compiled in dir.
+// Images compiled from TikZ are included as the PDF produced by LaTeX (see
+// graphics.SvgImage.ToPdf).
+//
+// If answerKey is set, the document is an answer key showing the correct
+// answers along with their grades and feedback, as well as the drop zones of
+// 'Drag and drop markers' questions.
+//
+// An error is returned if qb contains questions or images that cannot be
+// exported.
+func (qb *QuestionBank) ToLatex(w io.Writer, dir string, answerKey bool) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	e := &latexExporter{dir: dir, answerKey: answerKey}
+
+	fmt.Fprint(w, latexPreamble)
+	if answerKey {
+		fmt.Fprint(w, "\\printanswers\n")
+	}
+	title := latexEscape(qb.name)
+	if answerKey {
+		title += ` -- Answer key`
+	}
+	fmt.Fprintf(w, `\begin{document}
+\begin{center}
+\Large %s
+\end{center}
+
+\begin{questions}`, title)
+
+	for i, q := range qb.questions {
+		if err := e.question(w, q); err != nil {
+			return fmt.Errorf("Question %d: %w", i+1, err)
+		}
+	}
+
+	fmt.Fprint(w, `
+\end{questions}
+\end{document}
+`)
+	return nil
+}
+
+// CompileExam writes qb to dir as the LaTeX document exam.tex (see ToLatex) and
+// compiles it into exam.pdf using graphics.CompileLatex. If answerKey is set, a
+// separate answer key is written to key.tex and compiled into key.pdf. The
+// paths of the resulting PDFs are returned.
+//
+// If LaTeX fails to compile a document, the returned error wraps a
+// *graphics.TexError describing the problem.
+func (qb *QuestionBank) CompileExam(dir string, answerKey bool) ([]string, error) {
+	jobs := []string{"exam"}
+	if answerKey {
+		jobs = append(jobs, "key")
+	}
+
+	paths := make([]string, 0, len(jobs))
+	for _, job := range jobs {
+		var b strings.Builder
+		if err := qb.ToLatex(&b, dir, job == "key"); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(filepath.Join(dir, job+".tex"), []byte(b.String()), 0o644); err != nil {
+			return nil, err
+		}
+
+		path, err := graphics.CompileLatex(b.String(), job, dir)
+		if err != nil {
+			return nil, fmt.Errorf("Compiling %s.tex failed: %w", job, err)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// latexExporter keeps track of the images written while exporting a question
+// bank to LaTeX.
+type latexExporter struct {
+	dir       string
+	answerKey bool
+	nImages   int
+	err       error // The first error encountered
+}
+
+// question writes q in the format of the exam class.
+func (e *latexExporter) question(w io.Writer, q Question) error {
+	switch q := q.(type) {
+	case *MultiChoice:
+		fmt.Fprintf(w, "\n\n\\question[%d] %s", q.points, e.html(q.text, q.files, nil))
+		env := "choices"
+		if q.forceMultiple || q.NCorrect() != 1 {
+			env = "checkboxes"
+		}
+		fmt.Fprintf(w, "\n\\begin{%s}", env)
+		for _, a := range q.answers {
+			choice := `\choice`
+			if a.grade > 0 {
+				choice = `\CorrectChoice`
+			}
+			fmt.Fprintf(w, "\n%s %s%s", choice, e.html(a.text, a.files, nil), e.answerNote(a))
+		}
+		fmt.Fprintf(w, "\n\\end{%s}", env)
+	case *ShortText:
+		fmt.Fprintf(w, "\n\n\\question[%d] %s", q.points, e.html(q.text, q.files, nil))
+		e.solution(w, q.answers)
+	case *Numerical:
+		fmt.Fprintf(w, "\n\n\\question[%d] %s", q.points, e.html(q.text, q.files, nil))
+		e.solution(w, q.answers)
+	case *DropText:
+		e.dropText(w, q)
+	case *DropMarker:
+		e.dropMarker(w, q)
+	default:
+		return fmt.Errorf("Question type %q cannot be exported to LaTeX", q.MoodleName())
+	}
+	return e.err
+}
+
+// answerNote returns the grade and feedback of a for the answer key. If no
+// answer key is written, or if a is fully correct or incorrect and has no
+// feedback, the empty string is returned.
+func (e *latexExporter) answerNote(a *Answer) string {
+	if !e.answerKey {
+		return ""
+	}
+
+	var notes []string
+	if a.grade != 0 && a.grade != 100 {
+		notes = append(notes, fmt.Sprintf(`%g\%%`, a.grade))
+	}
+	if a.feedback != "" {
+		notes = append(notes, e.html(a.feedback, a.feedbackFiles, nil))
+	}
+	if len(notes) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(` \hfill\textit{(%s)}`, strings.Join(notes, ": "))
+}
+
+// solution writes the accepted answers in a solution environment. The exam
+// class leaves space for the answer when the solutions are not printed.
+func (e *latexExporter) solution(w io.Writer, answers []*Answer) {
+	fmt.Fprint(w, "\n\\begin{solution}[2cm]\n\\begin{itemize}")
+	for _, a := range answers {
+		text := latexEscape(a.text)
+		if strings.TrimSpace(a.text) == "*" {
+			text = `\textit{Any answer}`
+		}
+		if tol, ok := a.GetOption("tolerance"); ok {
+			text += ` $\pm$ ` + latexEscape(tol)
+		}
+		fmt.Fprintf(w, "\n\\item %s (%g\\%%)", text, a.grade)
+		if a.feedback != "" {
+			fmt.Fprintf(w, ": \\textit{%s}", e.html(a.feedback, a.feedbackFiles, nil))
+		}
+	}
+	fmt.Fprint(w, "\n\\end{itemize}\n\\end{solution}")
+}
+
+// dropText writes dt with each [[n]] placeholder replaced by a blank. The
+// markers are listed below the text.
+func (e *latexExporter) dropText(w io.Writer, dt *DropText) {
+	gap := func(n int) string {
+		if n < 1 || n > len(dt.markers) {
+			return `\fillin`
+		}
+		return fmt.Sprintf(`\fillin[%s]`, latexEscape(dt.markers[n-1].text))
+	}
+	fmt.Fprintf(w, "\n\n\\question[%d] %s", dt.points, e.html(dt.text, dt.files, gap))
+
+	markers := make([]string, len(dt.markers))
+	for i, m := range dt.markers {
+		markers[i] = fmt.Sprintf(`\fbox{%s}`, latexEscape(m.text))
+	}
+	fmt.Fprintf(w, "\n\n\\medskip\\noindent %s", strings.Join(markers, `\quad `))
+}
+
+// dropMarker writes dm with its background image and markers. In the answer
+// key, the drop zones are drawn on top of the image.
+func (e *latexExporter) dropMarker(w io.Writer, dm *DropMarker) {
+	fmt.Fprintf(w, "\n\n\\question[%d] %s", dm.points, e.html(dm.text, dm.files, nil))
+
+	_, ok := pixelSize(dm.img)
+	fmt.Fprint(w, "\n\n\\medskip\\noindent\n")
+	if !ok {
+		fmt.Fprint(w, e.includeGraphics(dm.img))
+	} else {
+		// Use pixels as units, so the zones can be drawn using their coordinates
+		scale, _ := graphics.ConvertLength(1, graphics.Px, graphics.Pt)
+		fmt.Fprintf(w, `\begin{tikzpicture}[x=%gbp, y=-%gbp]
+\node[anchor=north west, inner sep=0] at (0,0) {%s};`, scale, scale, e.includeGraphics(dm.img))
+		if e.answerKey {
+			for _, z := range dm.zones {
+				fmt.Fprintf(w, "\n%s", latexZone(z, dm.markers))
+			}
+		}
+		fmt.Fprint(w, "\n\\end{tikzpicture}")
+	}
+
+	markers := make([]string, len(dm.markers))
+	for i, m := range dm.markers {
+		markers[i] = fmt.Sprintf(`\fbox{%s}`, latexEscape(m.text))
+	}
+	fmt.Fprintf(w, "\n\n\\medskip\\noindent %s", strings.Join(markers, `\quad `))
+}
+
+// latexZone returns TikZ code drawing z labelled by the text of its correct
+// marker. Coordinates are measured in pixels.
+func latexZone(z *Zone, markers []*Mark) string {
+	var path string
+	var center [2]float64
+	switch z.shape {
+	case Circle:
+		center = z.points[0]
+		path = fmt.Sprintf("(%g,%g) circle[radius=%g]", center[0], center[1], z.size[0])
+	case Rectangle:
+		p := z.points[0]
+		center = [2]float64{p[0] + z.size[0]/2, p[1] + z.size[1]/2}
+		path = fmt.Sprintf("(%g,%g) rectangle (%g,%g)", p[0], p[1], p[0]+z.size[0], p[1]+z.size[1])
+	default:
+		center = centroid(z.points)
+		vertices := make([]string, len(z.points))
+		for i, v := range z.points {
+			vertices[i] = fmt.Sprintf("(%g,%g)", v[0], v[1])
+		}
+		path = strings.Join(vertices, " -- ") + " -- cycle"
+	}
+
+	label := fmt.Sprintf("Marker %d", z.correctMark+1)
+	if z.correctMark >= 0 && z.correctMark < len(markers) {
+		label = markers[z.correctMark].text
+	}
+	return fmt.Sprintf(`\draw[blue, thick, fill=blue, fill opacity=0.2] %s;
+\node[font=\small] at (%g,%g) {%s};`, path, center[0], center[1], latexEscape(label))
+}
+
+// includeGraphics writes img to the export folder and returns the LaTeX code
+// including it. If the image cannot be written, e.err is set.
+func (e *latexExporter) includeGraphics(img graphics.Image) string {
+	ext := strings.ToLower(img.Filetype())
+	var content bytes.Buffer
+	switch ext {
+	case "svg":
+		ext = "pdf"
+		if svg, ok := img.(*graphics.SvgImage); !ok {
+			e.setErr(fmt.Errorf("Svg image of type %T cannot be converted to PDF", img))
+			return ""
+		} else if err := svg.ToPdf(&content); err != nil {
+			e.setErr(err)
+			return ""
+		}
+	case "png", "jpg", "jpeg", "pdf":
+		var b64 strings.Builder
+		img.ToBase64(&b64)
+		decoded, err := base64.StdEncoding.DecodeString(b64.String())
+		if err != nil {
+			e.setErr(err)
+			return ""
+		}
+		content.Write(decoded)
+	default:
+		e.setErr(fmt.Errorf("Images of type %q cannot be included in LaTeX", ext))
+		return ""
+	}
+
+	e.nImages++
+	name := fmt.Sprintf("figure%d.%s", e.nImages, ext)
+	if err := os.WriteFile(filepath.Join(e.dir, name), content.Bytes(), 0o644); err != nil {
+		e.setErr(err)
+		return ""
+	}
+
+	if dim, ok := pixelSize(img); ok {
+		width, _ := graphics.ConvertLength(dim[0], graphics.Px, graphics.Pt)
+		return fmt.Sprintf(`\includegraphics[width=%.2fbp]{%s}`, width, name)
+	}
+	return fmt.Sprintf(`\includegraphics{%s}`, name)
+}
+
+// setErr records err unless an error has already been recorded.
+func (e *latexExporter) setErr(err error) {
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+var reTagName = regexp.MustCompile(`^</?([a-zA-Z][a-zA-Z0-9]*)`)
+
+// latexTags maps HTML tags to the LaTeX code inserted at their start and end.
+// Tags that are not listed are removed, keeping their contents.
+var latexTags = map[string][2]string{
+	"b":      {`\textbf{`, `}`},
+	"strong": {`\textbf{`, `}`},
+	"i":      {`\emph{`, `}`},
+	"em":     {`\emph{`, `}`},
+	"u":      {`\underline{`, `}`},
+	"sub":    {`\textsubscript{`, `}`},
+	"sup":    {`\textsuperscript{`, `}`},
+	"code":   {`\texttt{`, `}`},
+	"tt":     {`\texttt{`, `}`},
+	"p":      {"\n\n", "\n\n"},
+	"div":    {"\n\n", "\n\n"},
+	"ul":     {"\n\\begin{itemize}", "\n\\end{itemize}\n"},
+	"ol":     {"\n\\begin{enumerate}", "\n\\end{enumerate}\n"},
+	"li":     {"\n\\item ", ""},
+}
+
+// html converts the HTML code s into LaTeX. Images attached as files or
+// embedded in s are written to the export folder. Text is escaped, while math
+// is copied after replacing HTML entities. Placeholders of the form [[n]] are
+// replaced using gap if it is non-nil.
+func (e *latexExporter) html(s string, files []*File, gap func(n int) string) string {
+	var b strings.Builder
+	type openTag struct {
+		name string
+		end  string
+	}
+	var stack []openTag
+
+	// Contents of inline svgs and text for screen readers are collected
+	// separately, as they are not printed as text
+	var skip struct {
+		name  string
+		depth int
+		raw   strings.Builder
+	}
+
+	for _, seg := range segments(s) {
+		if skip.depth > 0 {
+			skip.raw.WriteString(seg.text)
+			if seg.kind != htmlSegment {
+				continue
+			}
+			if match := reTagName.FindStringSubmatch(seg.text); match != nil && strings.EqualFold(match[1], skip.name) {
+				if strings.HasPrefix(seg.text, "</") {
+					skip.depth--
+				} else if !strings.HasSuffix(seg.text, "/>") {
+					skip.depth++
+				}
+			}
+			if skip.depth == 0 && skip.name == "svg" {
+				b.WriteString(e.inlineSvg(skip.raw.String()))
+			}
+			continue
+		}
+
+		switch seg.kind {
+		case textSegment:
+			b.WriteString(latexEscape(html.UnescapeString(strings.ReplaceAll(seg.text, `\$`, `$`))))
+			continue
+		case mathSegment:
+			n := len(seg.text)
+			open, body, close := seg.text[:2], seg.text[2:n-2], seg.text[n-2:]
+			if open == "$$" {
+				open, close = `\[`, `\]`
+			}
+			b.WriteString(open + html.UnescapeString(body) + close)
+			continue
+		case placeholderSegment:
+			if gap == nil {
+				b.WriteString(latexEscape(seg.text))
+			} else {
+				n, _ := strconv.Atoi(strings.Trim(seg.text, "[]"))
+				b.WriteString(gap(n))
+			}
+			continue
+		}
+
+		match := reTagName.FindStringSubmatch(seg.text)
+		if match == nil {
+			// Comments and declarations
+			continue
+		}
+		name := strings.ToLower(match[1])
+
+		if strings.HasPrefix(seg.text, "</") {
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].name != name {
+					continue
+				}
+				for j := len(stack) - 1; j >= i; j-- {
+					b.WriteString(stack[j].end)
+				}
+				stack = stack[:i]
+				break
+			}
+			continue
+		}
+
+		switch {
+		case name == "br":
+			b.WriteString("\\newline\n")
+		case name == "img":
+			b.WriteString(e.imgTag(seg.text, files))
+		case name == "svg" || (name == "span" && strings.Contains(seg.text, "sr-only")):
+			skip.name, skip.depth = name, 1
+			skip.raw.Reset()
+			skip.raw.WriteString(seg.text)
+		case strings.HasSuffix(seg.text, "/>"):
+		default:
+			tag := latexTags[name]
+			b.WriteString(tag[0])
+			stack = append(stack, openTag{name, tag[1]})
+		}
+	}
+
+	// Close tags left open
+	for i := len(stack) - 1; i >= 0; i-- {
+		b.WriteString(stack[i].end)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// imgTag returns LaTeX code including the image referred to by the HTML image
+// tag. The image may be an attached file or a data URI.
+func (e *latexExporter) imgTag(tag string, files []*File) string {
+	match := reSrcAttr.FindStringSubmatch(tag)
+	if match == nil {
+		return ""
+	}
+	src := html.UnescapeString(strings.Trim(match[1], `"'`))
+
+	for _, f := range files {
+		if src == "@@PLUGINFILE@@/"+url.PathEscape(f.name) {
+			return e.includeGraphics(f.img)
+		}
+	}
+
+	if mediaType, data, ok := strings.Cut(strings.TrimPrefix(src, "data:"), ";base64,"); ok && strings.HasPrefix(src, "data:image/") {
+		content, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			e.setErr(fmt.Errorf("Failed to decode image: %v", err))
+			return ""
+		}
+		if mediaType == "image/svg+xml" {
+			return e.inlineSvg(string(content))
+		}
+		img, err := graphics.ImageFromBytes(content, strings.TrimPrefix(mediaType, "image/"))
+		if img == nil {
+			e.setErr(err)
+			return ""
+		}
+		return e.includeGraphics(img)
+	}
+
+	e.setErr(fmt.Errorf("Image %q is neither attached nor embedded", src))
+	return ""
+}
+
+// inlineSvg returns LaTeX code including the svg with the given content.
+func (e *latexExporter) inlineSvg(content string) string {
+	img, err := graphics.SvgFromBytes([]byte(content))
+	if err != nil {
+		e.setErr(err)
+		return ""
+	}
+	return e.includeGraphics(img)
+}
+
+// latexEscape escapes the characters of s that have a special meaning in
+// LaTeX.
+func latexEscape(s string) string {
+	return strings.NewReplacer(
+		`\`, `\textbackslash{}`,
+		`{`, `\{`,
+		`}`, `\}`,
+		`$`, `\$`,
+		`&`, `\&`,
+		`#`, `\#`,
+		`%`, `\%`,
+		`_`, `\_`,
+		`^`, `\^{}`,
+		`~`, `\~{}`,
+	).Replace(s)
+}
@@ -0,0 +1,122 @@
+package moodle
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ReneBoedker/MoodlishInquisition/graphics"
+)
+
+func testPng(t *testing.T, width, height int) *graphics.BinaryImage {
+	var b bytes.Buffer
+	if err := png.Encode(&b, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatalf("Encoding png caused error: %s", err)
+	}
+	img, err := graphics.ImageFromBytes(b.Bytes(), "png")
+	if err != nil {
+		t.Fatalf("Reading png caused error: %s", err)
+	}
+	return img
+}
+
+func TestHtmlToLatex(t *testing.T) {
+	e := &latexExporter{dir: t.TempDir()}
+	tests := [][2]string{
+		{`Is <b>this</b> 50% &amp; $5?`, `Is \textbf{this} 50\% \& \$5?`},
+		{`Compute $$\frac{a}{b} &lt; 1$$ and \(x_1 \lt 2\)`, `Compute \[\frac{a}{b} < 1\] and \(x_1 \lt 2\)`},
+		{`<p>First</p><p>Second<br>line</p>`, "First\n\n\n\nSecond\\newline\nline"},
+		{`<ul><li>A</li><li><em>B</em></li></ul>`, "\\begin{itemize}\n\\item A\n\\item \\emph{B}\n\\end{itemize}"},
+		{`Unclosed <i>tag`, `Unclosed \emph{tag}`},
+		{`Seen<span class="sr-only">unseen <b>text</b></span>`, `Seen`},
+		{`Array \$a[[1]]\$`, `Array \$a[[1]]\$`},
+	}
+	for _, v := range tests {
+		if got := e.html(v[0], nil, nil); got != v[1] {
+			t.Errorf("Converting %q gave %q, but expected %q", v[0], got, v[1])
+		}
+	}
+	if e.err != nil {
+		t.Errorf("Converting HTML caused error: %s", e.err)
+	}
+}
+
+func TestToLatex(t *testing.T) {
+	dir := t.TempDir()
+	f, _ := NewFile("figure", testPng(t, 40, 20))
+	mc := NewMultiChoice("Which figure? "+f.Html(), 2, []*Answer{
+		NewAnswerWithFeedback("This", 100, "Well done"),
+		NewAnswer("That", 0),
+	})
+	mc.AddFiles(f)
+
+	num := NewNumerical("Compute $$1/3$$", 1, []*Answer{NewAnswer("0.333", 100)})
+	num.answers[0].SetOption("tolerance", "0.001")
+
+	dt := NewDropText("A [[1]] of [[2]]", 1, []*TextMark{
+		NewTextMark("cat", 0, false),
+		NewTextMark("#1", 0, false),
+	})
+
+	circle, _ := NewZone(Circle, [2]float64{20, 20}, 20, 20, 0)
+	dm := NewDropMarker("Place A", testPng(t, 100, 80), 1, []*Mark{NewMark("A", 0)}, []*Zone{circle})
+
+	qb := NewQuestionBank("Exam_1", []Question{mc, num, dt, dm})
+
+	var b strings.Builder
+	if err := qb.ToLatex(&b, dir, false); err != nil {
+		t.Fatalf("Exporting to LaTeX caused error: %s", err)
+	}
+	exam := b.String()
+	for _, v := range []string{
+		`\documentclass[11pt]{exam}`,
+		`\Large Exam\_1`,
+		`\question[2] Which figure? \includegraphics[width=30.00bp]{figure1.png}`,
+		"\\begin{choices}\n\\CorrectChoice This\n\\choice That\n\\end{choices}",
+		`\question[1] Compute \[1/3\]`,
+		`\item 0.333 $\pm$ 0.001 (100\%)`,
+		`A \fillin[cat] of \fillin[\#1]`,
+		`\fbox{cat}\quad \fbox{\#1}`,
+		`{\includegraphics[width=75.00bp]{figure2.png}}`,
+		`\fbox{A}`,
+	} {
+		if !strings.Contains(exam, v) {
+			t.Errorf("LaTeX does not contain %q:\n%s", v, exam)
+		}
+	}
+	if strings.Contains(exam, `\printanswers`) || strings.Contains(exam, `\draw`) || strings.Contains(exam, "Well done") {
+		t.Errorf("Exam contains answers:\n%s", exam)
+	}
+	for _, v := range []string{"figure1.png", "figure2.png"} {
+		if _, err := os.Stat(filepath.Join(dir, v)); err != nil {
+			t.Errorf("Image %s was not written: %s", v, err)
+		}
+	}
+
+	b.Reset()
+	if err := qb.ToLatex(&b, dir, true); err != nil {
+		t.Fatalf("Exporting answer key caused error: %s", err)
+	}
+	key := b.String()
+	for _, v := range []string{
+		`\printanswers`,
+		`Exam\_1 -- Answer key`,
+		`\CorrectChoice This \hfill\textit{(Well done)}`,
+		`\draw[blue, thick, fill=blue, fill opacity=0.2] (20,20) circle[radius=10];`,
+		`\node[font=\small] at (20,20) {A};`,
+	} {
+		if !strings.Contains(key, v) {
+			t.Errorf("Answer key does not contain %q:\n%s", v, key)
+		}
+	}
+
+	gif, _ := graphics.ImageFromBytes([]byte("GIF89a"), "gif")
+	dm = NewDropMarker("Place A", gif, 1, []*Mark{NewMark("A", 0)}, []*Zone{circle})
+	if err := NewQuestionBank("Gif", []Question{dm}).ToLatex(&b, dir, false); err == nil {
+		t.Errorf("Gif image failed to return an error")
+	}
+}
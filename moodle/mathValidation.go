@@ -0,0 +1,331 @@
+package moodle
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// SyntaxError describes a problem with the math markup in a question.
+type SyntaxError struct {
+	Question int    // Number of the question in the bank, starting from 1
+	Field    string // The part of the question containing the error
+	Message  string
+}
+
+// Error returns a human-readable description of the error.
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("Question %d, %s: %s", e.Question, e.Field, e.Message)
+}
+
+// mathJaxMacros lists the macros supported by MathJax's TeX input in the
+// configuration used by Moodle. This includes the base macros, the macros of
+// the AMS packages, and common extensions such as cancel, braket and mhchem.
+var mathJaxMacros = make(map[string]bool)
+
+func init() {
+	RegisterMathMacros(strings.Fields(`
+		alpha beta gamma delta epsilon varepsilon zeta eta theta vartheta iota
+		kappa varkappa lambda mu nu xi omicron pi varpi rho varrho sigma varsigma
+		tau upsilon phi varphi chi psi omega digamma
+		Gamma Delta Theta Lambda Xi Pi Sigma Upsilon Phi Psi Omega
+		varGamma varDelta varTheta varLambda varXi varPi varSigma varUpsilon
+		varPhi varPsi varOmega
+
+		aleph beth gimel daleth hbar hslash ell wp Re Im partial nabla infty
+		imath jmath emptyset varnothing forall exists nexists neg lnot top bot
+		angle measuredangle sphericalangle triangle triangledown
+		blacktriangle blacktriangledown vartriangle square blacksquare Box
+		Diamond lozenge blacklozenge star bigstar clubsuit diamondsuit
+		heartsuit spadesuit flat natural sharp prime backprime surd complement
+		eth mho Finv Game Bbbk degree checkmark dagger ddagger S P yen
+		circledS circledR maltese diagup diagdown
+
+		pm mp times div cdot cdotp ldotp ast circ bullet oplus ominus otimes
+		oslash odot bigcirc cap cup Cap Cup sqcap sqcup uplus vee wedge lor
+		land setminus smallsetminus wr amalg diamond bigtriangleup
+		bigtriangledown triangleleft triangleright lhd rhd unlhd unrhd ltimes
+		rtimes leftthreetimes rightthreetimes dotplus divideontimes boxplus
+		boxminus boxtimes boxdot circledast circledcirc circleddash barwedge
+		veebar doublebarwedge curlywedge curlyvee doublecap doublecup
+		centerdot intercal And
+
+		sum prod coprod int iint iiint iiiint idotsint oint bigcap bigcup
+		bigsqcup bigvee bigwedge bigodot bigoplus bigotimes biguplus intop
+		smallint
+
+		leq le geq ge neq ne lt gt ll gg lll llless ggg gggtr approx approxeq
+		sim simeq cong equiv propto varpropto asymp doteq Doteq doteqdot
+		fallingdotseq risingdotseq prec succ preceq succeq precsim succsim
+		precapprox succapprox preccurlyeq succcurlyeq curlyeqprec curlyeqsucc
+		nprec nsucc npreceq nsucceq precneqq succneqq precnsim succnsim
+		precnapprox succnapprox subset supset subseteq supseteq subseteqq
+		supseteqq Subset Supset subsetneq supsetneq subsetneqq supsetneqq
+		varsubsetneq varsupsetneq varsubsetneqq varsupsetneqq nsubseteq
+		nsupseteq nsubseteqq nsupseteqq sqsubset sqsupset sqsubseteq
+		sqsupseteq in ni notin owns backepsilon mid nmid shortmid nshortmid
+		parallel nparallel shortparallel nshortparallel perp models vdash
+		dashv Vdash vDash Vvdash nvdash nvDash nVdash nVDash smile frown
+		smallsmile smallfrown bowtie Join leqq geqq leqslant geqslant
+		eqslantless eqslantgtr lesssim gtrsim lessapprox gtrapprox lessdot
+		gtrdot lessgtr gtrless lesseqgtr gtreqless lesseqqgtr gtreqqless nless
+		ngtr nleq ngeq nleqslant ngeqslant nleqq ngeqq lneq gneq lneqq gneqq
+		lvertneqq gvertneqq lnsim gnsim lnapprox gnapprox nsim ncong thicksim
+		thickapprox backsim backsimeq eqsim eqcirc circeq triangleq bumpeq
+		Bumpeq therefore because between pitchfork vartriangleleft
+		vartriangleright trianglelefteq trianglerighteq ntriangleleft
+		ntriangleright ntrianglelefteq ntrianglerighteq blacktriangleleft
+		blacktriangleright not notChar
+
+		leftarrow rightarrow to gets leftrightarrow Leftarrow Rightarrow
+		Leftrightarrow longleftarrow longrightarrow longleftrightarrow
+		Longleftarrow Longrightarrow Longleftrightarrow implies impliedby iff
+		mapsto longmapsto hookleftarrow hookrightarrow leftharpoonup
+		leftharpoondown rightharpoonup rightharpoondown rightleftharpoons
+		leftrightharpoons upharpoonleft upharpoonright downharpoonleft
+		downharpoonright uparrow downarrow updownarrow Uparrow Downarrow
+		Updownarrow nearrow searrow swarrow nwarrow leadsto nleftarrow
+		nrightarrow nLeftarrow nRightarrow nleftrightarrow nLeftrightarrow
+		twoheadrightarrow twoheadleftarrow leftarrowtail rightarrowtail
+		rightrightarrows leftleftarrows rightleftarrows leftrightarrows
+		Lleftarrow Rrightarrow circlearrowleft circlearrowright curvearrowleft
+		curvearrowright looparrowleft looparrowright rightsquigarrow
+		leftrightsquigarrow dashrightarrow dashleftarrow multimap Lsh Rsh
+		upuparrows downdownarrows restriction xleftarrow xrightarrow
+
+		ldots cdots vdots ddots dots dotsb dotsc dotsi dotsm dotso colon
+
+		left right middle big Big bigg Bigg bigl bigr Bigl Bigr biggl biggr
+		Biggl Biggr bigm Bigm biggm Biggm langle rangle lfloor rfloor lceil
+		rceil lbrace rbrace lbrack rbrack vert Vert lvert rvert lVert rVert
+		backslash ulcorner urcorner llcorner lrcorner lgroup rgroup lmoustache
+		rmoustache arrowvert Arrowvert bracevert
+
+		frac dfrac tfrac cfrac sqrt root uproot leftroot binom dbinom tbinom
+		choose over atop above overwithdelims atopwithdelims abovewithdelims
+		brace brack genfrac overline underline overbrace underbrace
+		overparen underparen overleftarrow overrightarrow overleftrightarrow
+		underleftarrow underrightarrow underleftrightarrow widehat widetilde
+		hat tilde bar vec dot ddot dddot ddddot acute grave breve check
+		mathring skew stackrel buildrel overset underset overunderset
+		substack sideset boxed cancel bcancel xcancel cancelto phantom
+		hphantom vphantom smash bbox enclose
+
+		sin cos tan cot sec csc arcsin arccos arctan sinh cosh tanh coth log
+		ln lg exp lim liminf limsup min max sup inf det dim ker deg gcd hom
+		arg Pr injlim projlim varliminf varlimsup varinjlim varprojlim mod
+		bmod pmod pod operatorname limits nolimits displaylimits
+
+		text textrm textit textbf textsf texttt textnormal textup mbox hbox
+		mathrm mathit mathbf mathsf mathtt mathcal mathscr mathfrak mathbb
+		mathnormal mathup boldsymbol bm bf it rm sf tt cal frak scr mit
+		oldstyle Bbb pmb
+
+		displaystyle textstyle scriptstyle scriptscriptstyle tiny Tiny
+		scriptsize small normalsize large Large LARGE huge Huge color
+		textcolor colorbox fcolorbox definecolor fbox framebox style class
+		cssId href unicode mmlToken verb
+
+		quad qquad enspace thinspace medspace thickspace negthinspace
+		negmedspace negthickspace nobreakspace hspace kern mkern mskip hskip
+		mspace space nobreak allowbreak newline cr hline hdashline cline hfil
+		hfill hfilll label ref eqref tag notag nonumber shoveleft shoveright
+
+		begin end newcommand renewcommand newenvironment renewenvironment def
+		let DeclareMathOperator require rule Rule Space strut mathstrut raise
+		lower moveleft moveright raisebox vcenter mathchoice mathop mathbin
+		mathrel mathopen mathclose mathpunct mathinner mathord llap rlap
+		clap mathllap mathrlap mathclap nonscript TeX LaTeX
+
+		ce pu bra ket braket Bra Ket Braket set Set
+	`)...)
+}
+
+// mathJaxEnvironments lists the environments supported by MathJax.
+var mathJaxEnvironments = map[string]bool{
+	"array": true, "matrix": true, "pmatrix": true, "bmatrix": true,
+	"Bmatrix": true, "vmatrix": true, "Vmatrix": true, "smallmatrix": true,
+	"cases": true, "aligned": true, "alignedat": true, "gathered": true,
+	"split": true, "align": true, "align*": true, "alignat": true,
+	"alignat*": true, "gather": true, "gather*": true, "equation": true,
+	"equation*": true, "eqnarray": true, "eqnarray*": true, "multline": true,
+	"multline*": true, "subarray": true, "CD": true,
+}
+
+// RegisterMathMacros adds names (without backslash) to the macros accepted by
+// the validation in GenerateQuestionBank. Use this if the MathJax
+// configuration of the Moodle site defines additional macros.
+func RegisterMathMacros(names ...string) {
+	for _, v := range names {
+		mathJaxMacros[strings.TrimPrefix(v, `\`)] = true
+	}
+}
+
+// validateSyntax checks the math markup in all text fields of q, which is the
+// question with the given number. All problems are returned together.
+func validateSyntax(number int, q Question) error {
+	var errs []error
+	for _, f := range textFields(q) {
+		for _, msg := range validateMath(f.text) {
+			errs = append(errs, &SyntaxError{number, f.name, msg})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+var (
+	reMathDelim   = regexp.MustCompile(`\$\$|\\\(|\\\)|\\\[|\\\]|\\\$|\$`)
+	reDefinedMath = regexp.MustCompile(`\\(?:(?:re)?newcommand\s*\{?|def\s*|DeclareMathOperator\*?\s*\{)\\([a-zA-Z]+)`)
+	reMathMacro   = regexp.MustCompile(`\\([a-zA-Z]+|.)`)
+	reBeginEnd    = regexp.MustCompile(`\\(begin|end)\s*\{([^}]*)\}`)
+	reTexLike     = regexp.MustCompile(`\\[a-zA-Z]|[\^_]`)
+)
+
+// validateMath checks the math in the HTML text s. Math must be delimited by
+// $$...$$, \(...\) or \[...\]. The returned messages describe any problems.
+func validateMath(s string) []string {
+	var msgs []string
+
+	// Macros defined in the text itself are accepted
+	defined := make(map[string]bool)
+	for _, m := range reDefinedMath.FindAllStringSubmatch(s, -1) {
+		defined[m[1]] = true
+	}
+
+	closers := map[string]string{`$$`: `$$`, `\(`: `\)`, `\[`: `\]`}
+	var single []int // Positions of single dollar signs
+	for pos := 0; pos < len(s); {
+		loc := reMathDelim.FindStringIndex(s[pos:])
+		if loc == nil {
+			break
+		}
+		start, end := pos+loc[0], pos+loc[1]
+		delim := s[start:end]
+
+		switch delim {
+		case `\$`:
+			pos = end
+			continue
+		case `$`:
+			single = append(single, start)
+			pos = end
+			continue
+		case `\)`, `\]`:
+			msgs = append(msgs, fmt.Sprintf("Closing delimiter %s without opening delimiter", delim))
+			pos = end
+			continue
+		}
+
+		closer := closers[delim]
+		n := strings.Index(s[end:], closer)
+		if n < 0 {
+			msgs = append(msgs, fmt.Sprintf("Math starting with %s at %q is not closed by %s",
+				delim, excerpt(s[start:]), closer))
+			break
+		}
+		math := s[end : end+n]
+		if m := reMathDelim.FindString(math); m != "" && m != `\$` && m != `$` {
+			msgs = append(msgs, fmt.Sprintf("Math %q contains the delimiter %s", excerpt(math), m))
+		}
+		msgs = append(msgs, checkMath(math, defined)...)
+		pos = end + n + len(closer)
+	}
+
+	// MathJax in Moodle does not treat $...$ as math, so TeX between single
+	// dollar signs is likely a mistake
+	for i := 0; i+1 < len(single); i += 2 {
+		if content := s[single[i]+1 : single[i+1]]; reTexLike.MatchString(content) {
+			msgs = append(msgs, fmt.Sprintf("Math %q is delimited by single dollar signs; use \\(...\\) instead",
+				excerpt(content)))
+		}
+	}
+
+	return msgs
+}
+
+// checkMath checks the contents of a single math expression. Macros in
+// defined are accepted in addition to those known by MathJax.
+func checkMath(math string, defined map[string]bool) []string {
+	var msgs []string
+
+	// Braces must be balanced, ignoring escaped braces
+	depth := 0
+	for i := 0; i < len(math) && depth >= 0; i++ {
+		switch math[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		}
+	}
+	if depth != 0 {
+		msgs = append(msgs, fmt.Sprintf("Math %q has unbalanced braces", excerpt(math)))
+	}
+
+	// Environments must be known and properly nested
+	var envs []string
+	for _, m := range reBeginEnd.FindAllStringSubmatch(math, -1) {
+		name := strings.TrimSpace(m[2])
+		if m[1] == "begin" {
+			if !mathJaxEnvironments[name] {
+				msgs = append(msgs, fmt.Sprintf("Unknown environment %q in math %q", name, excerpt(math)))
+			}
+			envs = append(envs, name)
+			continue
+		}
+		if len(envs) == 0 || envs[len(envs)-1] != name {
+			msgs = append(msgs, fmt.Sprintf("Unexpected \\end{%s} in math %q", name, excerpt(math)))
+			continue
+		}
+		envs = envs[:len(envs)-1]
+	}
+	for _, v := range envs {
+		msgs = append(msgs, fmt.Sprintf("Environment %q is not closed in math %q", v, excerpt(math)))
+	}
+
+	// Macros must be known
+	unknown := make(map[string]bool)
+	for _, m := range reMathMacro.FindAllStringSubmatch(math, -1) {
+		name := m[1]
+		if len(name) == 1 && !isLetter(name[0]) {
+			// Escaped characters and spacing such as \, and \{
+			continue
+		}
+		if !mathJaxMacros[name] && !defined[name] && !unknown[name] {
+			unknown[name] = true
+			msgs = append(msgs, fmt.Sprintf("Unknown macro \\%s in math %q", name, excerpt(math)))
+		}
+	}
+
+	// Moodle may interpret < and > as HTML
+	for i := 0; i < len(math); i++ {
+		if math[i] == '\\' {
+			i++
+			continue
+		}
+		if math[i] == '<' || math[i] == '>' {
+			msgs = append(msgs, fmt.Sprintf("Math %q contains %q, which should be escaped using EscapeMath",
+				excerpt(math), math[i]))
+			break
+		}
+	}
+
+	return msgs
+}
+
+// isLetter reports whether c is an ASCII letter.
+func isLetter(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+// excerpt shortens s for use in error messages.
+func excerpt(s string) string {
+	const maxLength = 30
+	if r := []rune(s); len(r) > maxLength {
+		return string(r[:maxLength-3]) + "..."
+	}
+	return s
+}
@@ -0,0 +1,81 @@
+package moodle
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateMath(t *testing.T) {
+	testCases := []struct {
+		s       string
+		nErrors int
+	}{
+		{`$$f(t)=\int \cos(x)\, dx$$`, 0},
+		{`What is \(\pi\) and \[\sum_{i=1}^n i\]?`, 0},
+		{`It costs $5 or \$6`, 0},
+		{`\(\begin{pmatrix} 1 & 0 \\ 0 & 1 \end{pmatrix}\)`, 0},
+		{`\(\newcommand{\R}{\mathbb{R}} x \in \R\)`, 0},
+		{`\(a \lt b\) and \(c \> d\)`, 0},
+		{`\(f\colon \mathbb{R}\setminus\{0\} \to \mathbb{R}\)`, 0},
+		{`\(\ce{H2O} \overset{!}{=} \bra{\psi}\), \(\cancel{x}\)`, 0},
+		{`$$x^2`, 1},
+		{`\(x^2`, 1},
+		{`x^2\)`, 1},
+		{`\(\frac{1}{2\)`, 1},
+		{`\(\foo x + \foo y\)`, 1},
+		{`\(\begin{foo} x \end{foo}\)`, 1},
+		{`\(\begin{matrix} x \end{pmatrix}\)`, 2},
+		{`\(a < b\)`, 1},
+		{`Let $x^2$ be`, 1},
+		{`$$x \( y$$`, 1},
+	}
+
+	for _, v := range testCases {
+		if msgs := validateMath(v.s); len(msgs) != v.nErrors {
+			t.Errorf("Expected %d errors for %q, but got %q", v.nErrors, v.s, msgs)
+		}
+	}
+}
+
+func TestRegisterMathMacros(t *testing.T) {
+	s := `\(\myMacro{x}\)`
+	if len(validateMath(s)) != 1 {
+		t.Fatalf("Unknown macro was accepted")
+	}
+	RegisterMathMacros(`\myMacro`)
+	defer delete(mathJaxMacros, "myMacro")
+	if msgs := validateMath(s); len(msgs) != 0 {
+		t.Errorf("Registered macro was rejected: %q", msgs)
+	}
+}
+
+func TestGenerateQuestionBankValidation(t *testing.T) {
+	fName := filepath.Join(t.TempDir(), "bank.xml")
+
+	n := 0
+	err := GenerateQuestionBank(fName, 3, func() Question {
+		n++
+		if n < 3 {
+			return NewShortText(`What is \(1+1\)?`, 1, []*Answer{NewAnswer("2", 100)})
+		}
+		ans := NewAnswerWithFeedback("2", 100, `Since \(1+1=2`)
+		return NewShortText(`What is \(1+1\)?`, 1, []*Answer{ans})
+	})
+
+	var syntaxErr *SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("Expected a *SyntaxError, but got %v", err)
+	}
+	if syntaxErr.Question != 3 || syntaxErr.Field != "feedback of answer 1" {
+		t.Errorf("Error points to question %d, %s", syntaxErr.Question, syntaxErr.Field)
+	}
+	if !strings.HasPrefix(err.Error(), "Question 3, feedback of answer 1: ") {
+		t.Errorf("Unexpected error message %q", err)
+	}
+	if _, err := os.Stat(fName); err == nil {
+		t.Errorf("File was written despite invalid math")
+	}
+}
@@ -1,9 +1,11 @@
 package moodle
 
 import (
+	"errors"
 	"fmt"
 	"hash/fnv"
 	"io"
+	"math"
 )
 
 var _ Question = (*MultiChoice)(nil) // Ensure interface is satisfied
@@ -15,6 +17,7 @@ type MultiChoice struct {
 	shuffle       bool
 	forceMultiple bool
 	text          string
+	files         []*File
 	answers       []*Answer
 }
 
@@ -55,17 +58,77 @@ func (mc *MultiChoice) NCorrect() (n uint) {
 // this function will change their grades to 100/n.
 // If withPenalty is set, incorrect answers will be given grade -100/n.
 // Otherwise, they will be left unchanged
-func (mc *MultiChoice) BalanceGrades(withPenalty bool) {
-	n := float64(mc.NCorrect())
+//
+// Moodle only accepts certain grades, so if 100/n is not among them, the
+// correct answers are given the nearest allowed grades that add up to 100. For
+// instance, with 11 correct answers, 9 answers are given grade 10 and 2 are
+// given grade 5. Penalties are rounded to the nearest allowed grade. The
+// answers whose grades differ from the exact values are reported. An error is
+// returned if no answers are correct or if the grade cannot be split (this
+// happens for more than 20 correct answers). In that case, the grades are
+// left unchanged.
+func (mc *MultiChoice) BalanceGrades(withPenalty bool) ([]GradeRounding, error) {
+	n := mc.NCorrect()
+	if n == 0 {
+		return nil, fmt.Errorf("No answer has a positive grade")
+	}
+	grades, err := splitGrade(int(n))
+	if err != nil {
+		return nil, err
+	}
+
+	exact := 100 / float64(n)
+	penalty := nearestGrade(-exact)
+
+	var rounded []GradeRounding
 	for i, v := range mc.answers {
-		if v.grade > 0 {
-			mc.answers[i].grade = 100 / n
+		requested := exact
+		switch {
+		case v.grade > 0:
+			v.grade, grades = grades[0], grades[1:]
+		case withPenalty:
+			requested = -exact
+			v.grade = penalty
+		default:
 			continue
 		}
-		if withPenalty {
-			mc.answers[i].grade = -100 / n
+		if math.Abs(v.grade-requested) > gradeTolerance {
+			rounded = append(rounded, GradeRounding{i, requested, v.grade})
+		}
+	}
+	return rounded, nil
+}
+
+// Validate checks that mc can be imported into Moodle. It must have at least
+// two answers, and the grades must be allowed by Moodle. If only one answer may
+// be chosen, one answer must have grade 100. Otherwise, the positive grades
+// must add up to 100. Grades that only differ from an allowed grade by rounding
+// are snapped to the allowed grade.
+func (mc *MultiChoice) Validate() error {
+	errs := validateText(mc.text)
+	if len(mc.answers) < 2 {
+		errs = append(errs, fmt.Errorf("At least 2 answers are required, but %d were given", len(mc.answers)))
+	}
+	errs = append(errs, validateAnswers(mc.answers, true)...)
+
+	switch n := mc.NCorrect(); {
+	case n == 0:
+		errs = append(errs, fmt.Errorf("No answer has a positive grade"))
+	case n == 1 && !mc.forceMultiple:
+		if !hasFullGrade(mc.answers) {
+			errs = append(errs, fmt.Errorf("The correct answer must have grade 100"))
+		}
+	default:
+		sum := 0.0
+		for _, a := range mc.answers {
+			sum += max(a.grade, 0)
+		}
+		if math.Abs(sum-100) > 0.01 {
+			errs = append(errs, fmt.Errorf("The positive grades add up to %g, but must add up to 100", sum))
 		}
 	}
+
+	return errors.Join(errs...)
 }
 
 // GetDescription returns the description (i.e. the question text) of mc.
@@ -73,6 +136,12 @@ func (mc *MultiChoice) GetDescription() string {
 	return mc.text
 }
 
+// AddFiles attaches files to the question text of mc. The files must be
+// referenced in the question text using their Html method.
+func (mc *MultiChoice) AddFiles(files ...*File) {
+	mc.files = append(mc.files, files...)
+}
+
 // SetShuffleAnswers allows enabling or disabling shuffling of answers. The
 // default is to shuffle.
 func (mc *MultiChoice) SetShuffleAnswers(b bool) {
@@ -97,10 +166,12 @@ func (mc *MultiChoice) ToXml(w io.Writer) {
 		<text>%s</text>
 	</name>
 	<questiontext format="html">
-		<text><![CDATA[%s]]></text>
+		<text><![CDATA[%s]]></text>`,
+		mc.name, mc.text)
+	writeFiles(w, mc.files, "\t\t")
+	fmt.Fprintf(w, `
 	</questiontext>
-	<defaultgrade>%d</defaultgrade>`,
-		mc.name, mc.text, mc.points)
+	<defaultgrade>%d</defaultgrade>`, mc.points)
 	defer fmt.Fprint(w, `
 </question>`)
 
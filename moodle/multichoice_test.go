@@ -1,6 +1,7 @@
 package moodle
 
 import (
+	"math"
 	"testing"
 )
 
@@ -33,3 +34,78 @@ func TestBalanceGrades(t *testing.T) {
 		}
 	}
 }
+
+func TestBalanceGradesRounding(t *testing.T) {
+	answers := make([]*Answer, 12)
+	for i := range answers {
+		answers[i] = NewAnswer("true", 1)
+	}
+	answers[11] = NewAnswer("false", 0)
+
+	mc := NewMultiChoice("Which are true?", 1, answers)
+	rounded, err := mc.BalanceGrades(true)
+	if err != nil {
+		t.Fatalf("Balancing grades caused error: %s", err)
+	}
+
+	// 11 correct answers: 9 get grade 10 and 2 get grade 5
+	sum := 0.0
+	for _, v := range answers[:11] {
+		sum += v.grade
+	}
+	if sum != 100 || answers[0].grade != 10 || answers[10].grade != 5 {
+		t.Errorf("Unexpected grades %v", mc.answers)
+	}
+	if answers[11].grade != -10 {
+		t.Errorf("Expected penalty -10, but got %v", answers[11].grade)
+	}
+	if len(rounded) != 12 || rounded[0].Answer != 0 || rounded[0].Grade != 10 {
+		t.Errorf("Unexpected rounding report %v", rounded)
+	}
+	if err := mc.Validate(); err != nil {
+		t.Errorf("Balanced question produced error: %s", err)
+	}
+
+	mc = NewMultiChoice("", 1, []*Answer{NewAnswer("false", 0)})
+	if _, err := mc.BalanceGrades(false); err == nil {
+		t.Errorf("Question without correct answers failed to return an error")
+	}
+}
+
+func TestSplitGrade(t *testing.T) {
+	for n := 1; n <= 20; n++ {
+		grades, err := splitGrade(n)
+		if err != nil {
+			t.Errorf("Splitting between %d answers caused error: %s", n, err)
+			continue
+		}
+		sum := 0.0
+		for _, g := range grades {
+			if _, ok := snapGrade(g); !ok {
+				t.Errorf("Splitting between %d answers produced invalid grade %g", n, g)
+			}
+			sum += g
+		}
+		if math.Abs(sum-100) > 0.01 {
+			t.Errorf("Grades for %d answers add up to %g", n, sum)
+		}
+	}
+	if _, err := splitGrade(21); err == nil {
+		t.Errorf("Splitting between 21 answers failed to return an error")
+	}
+}
+
+func TestSetGrade(t *testing.T) {
+	a := NewAnswer("A", 0)
+	if err := a.SetGrade(100.0 / 3); err != nil || a.GetGrade() != 33.33333 {
+		t.Errorf("Grade 100/3 was not snapped: %v, %v", a.GetGrade(), err)
+	}
+	for _, v := range []float64{35, 101, -150} {
+		if err := a.SetGrade(v); err == nil {
+			t.Errorf("Grade %g failed to return an error", v)
+		}
+	}
+	if a.GetGrade() != 33.33333 {
+		t.Errorf("Invalid grade changed the grade to %v", a.GetGrade())
+	}
+}
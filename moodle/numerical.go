@@ -1,9 +1,12 @@
 package moodle
 
 import (
+	"errors"
 	"fmt"
 	"hash/fnv"
 	"io"
+	"strconv"
+	"strings"
 )
 
 var _ Question = (*Numerical)(nil) // Ensure interface is satisfied
@@ -13,6 +16,7 @@ type Numerical struct {
 	name    string
 	points  uint
 	text    string
+	files   []*File
 	answers []*Answer
 }
 
@@ -37,6 +41,36 @@ func NewNumerical(description string, points uint, answers []*Answer) *Numerical
 	}
 }
 
+// AddFiles attaches files to the question text of q. The files must be
+// referenced in the question text using their Html method.
+func (q *Numerical) AddFiles(files ...*File) {
+	q.files = append(q.files, files...)
+}
+
+// Validate checks that q can be imported into Moodle. In addition to the checks
+// of ShortText.Validate, the answers must be numbers (or * to match any
+// response), and tolerances must be numbers.
+func (q *Numerical) Validate() error {
+	errs := validateText(q.text)
+	errs = append(errs, validateAnswers(q.answers, false)...)
+	if !hasFullGrade(q.answers) {
+		errs = append(errs, fmt.Errorf("No answer has grade 100"))
+	}
+
+	for i, a := range q.answers {
+		if _, err := strconv.ParseFloat(strings.TrimSpace(a.text), 64); err != nil && strings.TrimSpace(a.text) != "*" {
+			errs = append(errs, fmt.Errorf("Answer %d is not a number: %q", i+1, a.text))
+		}
+		if tol, ok := a.GetOption("tolerance"); ok {
+			if _, err := strconv.ParseFloat(tol, 64); err != nil {
+				errs = append(errs, fmt.Errorf("Tolerance of answer %d is not a number: %q", i+1, tol))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
 // SetShuffleAnswers allows enabling or disabling shuffling of answers. This has
 // no effect for Numerical question types.
 func (q *Numerical) SetShuffleAnswers(b bool) {
@@ -53,10 +87,12 @@ func (q *Numerical) ToXml(w io.Writer) {
 		<text>%s</text>
 	</name>
 	<questiontext format="html">
-		<text><![CDATA[%s]]></text>
+		<text><![CDATA[%s]]></text>`,
+		q.name, q.text)
+	writeFiles(w, q.files, "\t\t")
+	fmt.Fprintf(w, `
 	</questiontext>
-	<defaultgrade>%d</defaultgrade>`,
-		q.name, q.text, q.points)
+	<defaultgrade>%d</defaultgrade>`, q.points)
 	defer fmt.Fprintf(w, `
 </question>`)
 
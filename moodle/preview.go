@@ -0,0 +1,302 @@
+package moodle
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ReneBoedker/MoodlishInquisition/graphics"
+)
+
+// previewStyle is the CSS used by ToPreview.
+const previewStyle = `
+body { font-family: sans-serif; max-width: 50em; margin: auto; padding: 1em; }
+.question { border: 1px solid #ccc; border-radius: 4px; padding: 0 1em 1em; margin-bottom: 1em; }
+.question h2 { font-size: 1.1em; }
+.question h2 small { font-weight: normal; color: #666; }
+.problems { background: #fdd; border-left: 4px solid #c00; padding: 0.5em 1em; }
+.answers li { margin: 0.3em 0; }
+.grade { display: inline-block; min-width: 4.5em; font-weight: bold; }
+.correct .grade { color: #080; }
+.partial .grade { color: #a60; }
+.incorrect .grade { color: #c00; }
+.feedback { color: #555; font-style: italic; margin-left: 4.5em; }
+.gap { border: 1px dashed #888; border-radius: 3px; padding: 0 0.3em; background: #eef; }
+.figure { position: relative; display: inline-block; }
+.figure svg.zones { position: absolute; top: 0; left: 0; }
+.zones circle, .zones rect, .zones polygon { fill: rgba(0, 120, 255, 0.2); stroke: #0078ff; stroke-width: 2; }
+.zones text { font-size: 12px; text-anchor: middle; dominant-baseline: middle; }
+.sr-only { position: absolute; width: 1px; height: 1px; overflow: hidden; clip: rect(0, 0, 0, 0); }
+`
+
+// ToPreview writes qb as a standalone HTML page for reviewing the questions
+// before importing them into Moodle. The page shows the question texts with
+// their attached files, the answers with grades and feedback, the gaps of 'Drag
+// and drop into text' questions, and the drop zones of 'Drag and drop markers'
+// questions. Problems found by the Validate method of each question are shown
+// as well.
+//
+// If mathJax is non-empty, it is used as the path of the MathJax script, which
+// allows rendering the math without internet access.
+func (qb *QuestionBank) ToPreview(w io.Writer, mathJax string) {
+	fmt.Fprintf(w, `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="UTF-8">
+<title>%s</title>
+<style>%s</style>`, html.EscapeString(qb.name), previewStyle)
+	if mathJax != "" {
+		fmt.Fprintf(w, `
+<script id="MathJax-script" async src="%s"></script>`, html.EscapeString(mathJax))
+	}
+	fmt.Fprintf(w, `
+</head>
+<body>
+<h1>%s</h1>`, html.EscapeString(qb.name))
+	defer fmt.Fprint(w, `
+</body>
+</html>
+`)
+
+	for i, q := range qb.questions {
+		previewQuestion(w, i, q)
+	}
+}
+
+// validateCopy calls the Validate method of a copy of q. Validating snaps the
+// grades of the answers, so this ensures that the preview does not modify q.
+func validateCopy(q Question) error {
+	switch q := q.(type) {
+	case *MultiChoice:
+		c := *q
+		c.answers = copyAnswers(q.answers)
+		return c.Validate()
+	case *ShortText:
+		c := *q
+		c.answers = copyAnswers(q.answers)
+		return c.Validate()
+	case *Numerical:
+		c := *q
+		c.answers = copyAnswers(q.answers)
+		return c.Validate()
+	}
+	return q.Validate()
+}
+
+// copyAnswers returns shallow copies of the given answers.
+func copyAnswers(answers []*Answer) []*Answer {
+	copies := make([]*Answer, len(answers))
+	for i, a := range answers {
+		c := *a
+		copies[i] = &c
+	}
+	return copies
+}
+
+// previewQuestion writes the preview of the i'th question q.
+func previewQuestion(w io.Writer, i int, q Question) {
+	fmt.Fprintf(w, `
+<section class="question">
+<h2>Question %d <small>%s`, i+1, html.EscapeString(q.MoodleName()))
+	if points, ok := questionPoints(q); ok {
+		fmt.Fprintf(w, `, %d points`, points)
+	}
+	fmt.Fprint(w, `</small></h2>`)
+	defer fmt.Fprint(w, `
+</section>`)
+
+	if err := validateCopy(q); err != nil {
+		fmt.Fprint(w, `
+<div class="problems"><ul>`)
+		for _, v := range strings.Split(err.Error(), "\n") {
+			fmt.Fprintf(w, `
+<li>%s</li>`, html.EscapeString(v))
+		}
+		fmt.Fprint(w, `
+</ul></div>`)
+	}
+
+	switch q := q.(type) {
+	case *MultiChoice:
+		fmt.Fprintf(w, "\n<div class=\"text\">%s</div>", embedFiles(q.text, q.files))
+		if !q.forceMultiple && q.NCorrect() == 1 {
+			fmt.Fprint(w, "\n<p>One answer is allowed.</p>")
+		} else {
+			fmt.Fprint(w, "\n<p>Multiple answers are allowed.</p>")
+		}
+		previewAnswers(w, q.answers, true)
+	case *ShortText:
+		fmt.Fprintf(w, "\n<div class=\"text\">%s</div>", embedFiles(q.text, q.files))
+		if q.caseSensitive {
+			fmt.Fprint(w, "\n<p>Answers are case sensitive.</p>")
+		}
+		previewAnswers(w, q.answers, false)
+	case *Numerical:
+		fmt.Fprintf(w, "\n<div class=\"text\">%s</div>", embedFiles(q.text, q.files))
+		previewAnswers(w, q.answers, false)
+	case *DropText:
+		previewDropText(w, q)
+	case *DropMarker:
+		previewDropMarker(w, q)
+	default:
+		fmt.Fprint(w, "\n<p>No preview is available for this question type.</p>")
+	}
+}
+
+// questionPoints returns the number of points given for q. If q is defined
+// outside this package, ok is false.
+func questionPoints(q Question) (points uint, ok bool) {
+	switch q := q.(type) {
+	case *MultiChoice:
+		return q.points, true
+	case *ShortText:
+		return q.points, true
+	case *Numerical:
+		return q.points, true
+	case *DropText:
+		return q.points, true
+	case *DropMarker:
+		return q.points, true
+	}
+	return 0, false
+}
+
+// previewAnswers writes a list of the given answers. If isHtml is false, the
+// answer texts are escaped.
+func previewAnswers(w io.Writer, answers []*Answer, isHtml bool) {
+	fmt.Fprint(w, "\n<ul class=\"answers\">")
+	defer fmt.Fprint(w, "\n</ul>")
+
+	for _, a := range answers {
+		class := "incorrect"
+		switch {
+		case a.grade >= 100:
+			class = "correct"
+		case a.grade > 0:
+			class = "partial"
+		}
+
+		text := html.EscapeString(a.text)
+		if isHtml {
+			text = embedFiles(a.text, a.files)
+		}
+		if tol, ok := a.GetOption("tolerance"); ok {
+			text += " &plusmn; " + html.EscapeString(tol)
+		}
+
+		fmt.Fprintf(w, "\n<li class=\"%s\"><span class=\"grade\">%g%%</span> %s", class, a.grade, text)
+		if a.feedback != "" {
+			fmt.Fprintf(w, "\n<div class=\"feedback\">%s</div>", embedFiles(a.feedback, a.feedbackFiles))
+		}
+		fmt.Fprint(w, "</li>")
+	}
+}
+
+// previewDropText writes the text of dt with each [[n]] placeholder replaced by
+// the correct marker, followed by the list of markers.
+func previewDropText(w io.Writer, dt *DropText) {
+	var b strings.Builder
+	for _, seg := range segments(dt.text) {
+		if seg.kind != placeholderSegment {
+			b.WriteString(seg.text)
+			continue
+		}
+		n, _ := strconv.Atoi(strings.Trim(seg.text, "[]"))
+		if n < 1 || n > len(dt.markers) {
+			fmt.Fprintf(&b, `<span class="gap">%s</span>`, seg.text)
+			continue
+		}
+		fmt.Fprintf(&b, `<span class="gap" title="Marker %d">%s</span>`, n, html.EscapeString(dt.markers[n-1].text))
+	}
+	fmt.Fprintf(w, "\n<div class=\"text\">%s</div>", embedFiles(b.String(), dt.files))
+
+	fmt.Fprint(w, "\n<ol class=\"markers\">")
+	for _, m := range dt.markers {
+		fmt.Fprintf(w, "\n<li>%s (group %d", html.EscapeString(m.text), m.dropGroup+1)
+		if m.unlimited {
+			fmt.Fprint(w, ", unlimited")
+		}
+		fmt.Fprint(w, ")</li>")
+	}
+	fmt.Fprint(w, "\n</ol>")
+}
+
+// previewDropMarker writes the text and background image of dm with its drop
+// zones drawn on top, followed by the list of markers.
+func previewDropMarker(w io.Writer, dm *DropMarker) {
+	fmt.Fprintf(w, "\n<div class=\"text\">%s%s</div>", embedFiles(dm.text, dm.files), backgroundDescription(dm.img))
+
+	dim, ok := pixelSize(dm.img)
+	fmt.Fprintf(w, "\n<div class=\"figure\"><img src=\"%s\" alt=\"\"", dataUri(dm.img))
+	if ok {
+		fmt.Fprintf(w, ` width="%.0f" height="%.0f"`, dim[0], dim[1])
+	}
+	fmt.Fprint(w, " />")
+	defer fmt.Fprint(w, "\n</div>")
+
+	if ok {
+		fmt.Fprintf(w, "\n<svg class=\"zones\" width=\"%.0f\" height=\"%.0f\" viewBox=\"0 0 %.0f %.0f\">",
+			dim[0], dim[1], dim[0], dim[1])
+		for _, z := range dm.zones {
+			previewZone(w, z, dm.markers)
+		}
+		fmt.Fprint(w, "\n</svg>")
+	}
+
+	fmt.Fprint(w, "\n<ol class=\"markers\">")
+	for _, m := range dm.markers {
+		fmt.Fprintf(w, "\n<li>%s", html.EscapeString(m.text))
+		if m.nDrags == 0 {
+			fmt.Fprint(w, " (unlimited)")
+		} else {
+			fmt.Fprintf(w, " (%d drags)", m.nDrags)
+		}
+		fmt.Fprint(w, "</li>")
+	}
+	fmt.Fprint(w, "\n</ol>")
+}
+
+// previewZone writes z as an svg shape labelled with the text of its correct
+// marker.
+func previewZone(w io.Writer, z *Zone, markers []*Mark) {
+	var center [2]float64
+	switch z.shape {
+	case Circle:
+		center = z.points[0]
+		fmt.Fprintf(w, "\n<circle cx=\"%.0f\" cy=\"%.0f\" r=\"%.0f\" />", center[0], center[1], z.size[0])
+	case Rectangle:
+		center = [2]float64{z.points[0][0] + z.size[0]/2, z.points[0][1] + z.size[1]/2}
+		fmt.Fprintf(w, "\n<rect x=\"%.0f\" y=\"%.0f\" width=\"%.0f\" height=\"%.0f\" />",
+			z.points[0][0], z.points[0][1], z.size[0], z.size[1])
+	default:
+		center = centroid(z.points)
+		fmt.Fprintf(w, "\n<polygon points=\"%s\" />", strings.ReplaceAll(z.coords(), ";", " "))
+	}
+
+	label := fmt.Sprintf("Marker %d", z.correctMark+1)
+	if z.correctMark >= 0 && z.correctMark < len(markers) {
+		label = markers[z.correctMark].text
+	}
+	fmt.Fprintf(w, "\n<text x=\"%.0f\" y=\"%.0f\">%s</text>", center[0], center[1], html.EscapeString(label))
+}
+
+// embedFiles replaces references to the given attachments in the HTML code s
+// by data URIs, so the images are shown without Moodle.
+func embedFiles(s string, files []*File) string {
+	for _, f := range files {
+		s = strings.ReplaceAll(s, "@@PLUGINFILE@@/"+url.PathEscape(f.name), dataUri(f.img))
+	}
+	return s
+}
+
+// dataUri returns a data URI containing img.
+func dataUri(img graphics.Image) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "data:%s;base64,", mime.TypeByExtension("."+img.Filetype()))
+	img.ToBase64(&b)
+	return b.String()
+}
@@ -0,0 +1,93 @@
+package moodle
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ReneBoedker/MoodlishInquisition/graphics"
+)
+
+func TestPreview(t *testing.T) {
+	gif, _ := graphics.ImageFromBytes([]byte("GIF89a"), "gif")
+	f, _ := NewFile("figure", gif)
+	mc := NewMultiChoice("Which figure? "+f.Html(), 2, []*Answer{
+		NewAnswerWithFeedback("This <b>one</b>", 100, "Well done"),
+		NewAnswer("That one", 0),
+	})
+	mc.AddFiles(f)
+
+	dt := NewDropText("A [[1]] of [[2]]", 1, []*TextMark{
+		NewTextMark("cat", 0, false),
+		NewTextMark("a<b", 0, false),
+	})
+
+	svg, err := graphics.SvgFromBytes([]byte(`<svg width="75pt" height="75pt"></svg>`))
+	if err != nil {
+		t.Fatalf("Reading svg caused error: %s", err)
+	}
+	circle, _ := NewZone(Circle, [2]float64{20, 20}, 20, 20, 0)
+	rect, _ := NewZone(Rectangle, [2]float64{70, 70}, 20, 10, 0)
+	poly, _ := NewPolygonZone([][2]float64{{40, 40}, {60, 40}, {50, 60}}, 0)
+	dm := NewDropMarker("Place A", svg, 1, []*Mark{NewMark("A", 0)}, []*Zone{circle, rect, poly})
+
+	st := NewShortText("", 1, []*Answer{NewAnswer("x", 50)})
+
+	qb := NewQuestionBank("Preview & test", []Question{mc, dt, dm, st})
+	var b strings.Builder
+	qb.ToPreview(&b, "mathjax/tex-chtml.js")
+	out := b.String()
+
+	for _, v := range []string{
+		`<title>Preview &amp; test</title>`,
+		`<script id="MathJax-script" async src="mathjax/tex-chtml.js"></script>`,
+		`Question 1 <small>Multiple choice, 2 points</small>`,
+		`Which figure? <img src="data:image/gif;base64,R0lGODlh"`,
+		`<li class="correct"><span class="grade">100%</span> This <b>one</b>`,
+		`<div class="feedback">Well done</div>`,
+		`<li class="incorrect"><span class="grade">0%</span> That one`,
+		`A <span class="gap" title="Marker 1">cat</span> of <span class="gap" title="Marker 2">a&lt;b</span>`,
+		`<img src="data:image/svg+xml;base64,`,
+		`<svg class="zones" width="100" height="100" viewBox="0 0 100 100">`,
+		`<circle cx="20" cy="20" r="10" />`,
+		`<rect x="60" y="65" width="20" height="10" />`,
+		`<polygon points="40,40 60,40 50,60" />`,
+		`<text x="20" y="20">A</text>`,
+		`<li class="partial"><span class="grade">50%</span> x`,
+		`<li>Question text is empty</li>`,
+		`<li>No answer has grade 100</li>`,
+	} {
+		if !strings.Contains(out, v) {
+			t.Errorf("Preview does not contain %q", v)
+		}
+	}
+	if strings.Count(out, `<div class="problems">`) != 1 {
+		t.Errorf("Expected problems to be shown for one question only")
+	}
+
+	b.Reset()
+	qb.ToPreview(&b, "")
+	if strings.Contains(b.String(), "<script") {
+		t.Errorf("Preview without MathJax path contains a script")
+	}
+}
+
+func TestPreviewKeepsGrades(t *testing.T) {
+	mc := NewMultiChoice("Pick one", 1, []*Answer{
+		NewAnswer("One", 33.333),
+		NewAnswer("Two", 33.333),
+		NewAnswer("Three", 33.333),
+	})
+	num := NewNumerical("Compute", 1, []*Answer{NewAnswer("1", 99.9999)})
+
+	var b strings.Builder
+	NewQuestionBank("Grades", []Question{mc, num}).ToPreview(&b, "")
+
+	for i, a := range mc.answers {
+		if a.GetGrade() != 33.333 {
+			t.Errorf("Preview changed grade of answer %d to %g", i+1, a.GetGrade())
+		}
+	}
+	if g := num.answers[0].GetGrade(); g != 99.9999 {
+		t.Errorf("Preview changed grade of numerical answer to %g", g)
+	}
+}
@@ -0,0 +1,673 @@
+package moodle
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ReneBoedker/MoodlishInquisition/graphics"
+)
+
+const (
+	qtiNamespace = `xmlns="http://www.imsglobal.org/xsd/imsqti_v2p1" ` +
+		`xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" ` +
+		`xsi:schemaLocation="http://www.imsglobal.org/xsd/imsqti_v2p1 http://www.imsglobal.org/xsd/qti/qtiv2p1/imsqti_v2p1.xsd"`
+	qtiMapResponse = `http://www.imsglobal.org/question/qti_v2p1/rptemplates/map_response`
+)
+
+// qtiFile is a file included in a QTI content package.
+type qtiFile struct {
+	path    string // Relative to the root of the package
+	content []byte
+}
+
+// qtiItem is a question converted into a QTI assessment item.
+type qtiItem struct {
+	id    string
+	xml   string
+	files []qtiFile // Files used by the item, e.g. images
+}
+
+// ToQti writes qb as a QTI 2.1 content package, which is a zip archive that
+// can be imported into other learning management systems. Each question is
+// written as an assessment item, and the items are collected in an assessment
+// test. Images attached to the questions are included in the archive and
+// listed in the manifest. This also applies to svg images written directly in
+// the texts.
+//
+// The question types are converted into the following interactions:
+//   - MultiChoice: choiceInteraction
+//   - ShortText: textEntryInteraction
+//   - Numerical: textEntryInteraction with a tolerance
+//   - DropText: gapMatchInteraction
+//   - DropMarker: graphicGapMatchInteraction
+//
+// QTI has no notion of text markers on images, so the markers of DropMarker
+// questions are included as small svg images. Drop groups and answer feedback
+// are not exported, and math is kept as LaTeX code. An error is returned if qb
+// contains other question types, or if a text is not well-formed XHTML.
+func (qb *QuestionBank) ToQti(w io.Writer) error {
+	items := make([]*qtiItem, len(qb.questions))
+	for i, q := range qb.questions {
+		var err error
+		if items[i], err = newQtiItem(fmt.Sprintf("item%d", i+1), q); err != nil {
+			return fmt.Errorf("Question %d: %w", i+1, err)
+		}
+	}
+
+	z := zip.NewWriter(w)
+	add := func(path, content string) error {
+		f, err := z.Create(path)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(f, content)
+		return err
+	}
+
+	if err := add("imsmanifest.xml", qtiManifest(qb.name, items)); err != nil {
+		return err
+	}
+	if err := add("test.xml", qtiTest(qb.name, items)); err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := add(item.path(), item.xml); err != nil {
+			return err
+		}
+		for _, f := range item.files {
+			if err := add(f.path, string(f.content)); err != nil {
+				return err
+			}
+		}
+	}
+	return z.Close()
+}
+
+// path returns the path of item in the content package.
+func (item *qtiItem) path() string {
+	return "items/" + item.id + ".xml"
+}
+
+// qtiManifest returns the manifest of a content package with the given items.
+func qtiManifest(name string, items []*qtiItem) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8"?>
+<manifest xmlns="http://www.imsglobal.org/xsd/imscp_v1p1" identifier="MANIFEST-%s">
+	<metadata>
+		<schema>QTIv2.1 Package</schema>
+		<schemaversion>1.0.0</schemaversion>
+	</metadata>
+	<organizations/>
+	<resources>
+		<resource identifier="test" type="imsqti_test_xmlv2p1" href="test.xml">
+			<file href="test.xml"/>`, qtiIdentifier(name))
+	for _, item := range items {
+		fmt.Fprintf(&b, `
+			<dependency identifierref="%s"/>`, item.id)
+	}
+	fmt.Fprint(&b, `
+		</resource>`)
+
+	for _, item := range items {
+		fmt.Fprintf(&b, `
+		<resource identifier="%s" type="imsqti_item_xmlv2p1" href="%s">
+			<file href="%s"/>`, item.id, item.path(), item.path())
+		for _, f := range item.files {
+			fmt.Fprintf(&b, `
+			<file href="%s"/>`, html.EscapeString(f.path))
+		}
+		fmt.Fprint(&b, `
+		</resource>`)
+	}
+
+	fmt.Fprint(&b, `
+	</resources>
+</manifest>
+`)
+	return b.String()
+}
+
+// qtiTest returns an assessment test containing the given items.
+func qtiTest(name string, items []*qtiItem) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8"?>
+<assessmentTest %s identifier="test" title="%s">
+	<testPart identifier="part1" navigationMode="nonlinear" submissionMode="simultaneous">
+		<assessmentSection identifier="section1" title="%s" visible="true">`,
+		qtiNamespace, html.EscapeString(name), html.EscapeString(name))
+	for _, item := range items {
+		fmt.Fprintf(&b, `
+			<assessmentItemRef identifier="%s" href="%s"/>`, item.id, item.path())
+	}
+	fmt.Fprint(&b, `
+		</assessmentSection>
+	</testPart>
+</assessmentTest>
+`)
+	return b.String()
+}
+
+var reInvalidIdentifier = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// qtiIdentifier turns s into a valid identifier.
+func qtiIdentifier(s string) string {
+	return reInvalidIdentifier.ReplaceAllString(s, "_")
+}
+
+// newQtiItem converts q into an assessment item with the given identifier.
+func newQtiItem(id string, q Question) (*qtiItem, error) {
+	item := &qtiItem{id: id}
+
+	var b strings.Builder
+	var err error
+	switch q := q.(type) {
+	case *MultiChoice:
+		err = item.multiChoice(&b, q)
+	case *ShortText:
+		err = item.shortText(&b, q)
+	case *Numerical:
+		err = item.numerical(&b, q)
+	case *DropText:
+		err = item.dropText(&b, q)
+	case *DropMarker:
+		err = item.dropMarker(&b, q)
+	default:
+		return nil, fmt.Errorf("Question type %q cannot be exported to QTI", q.MoodleName())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	item.xml = b.String()
+	return item, nil
+}
+
+// writeHeader writes the start of the assessment item and the declaration of
+// the SCORE and MAXSCORE outcomes. The response declaration must be given by
+// response. The title may contain HTML entities (see ParseGift).
+func (item *qtiItem) writeHeader(w io.Writer, title string, points uint, response string) {
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<assessmentItem %s identifier="%s" title="%s" adaptive="false" timeDependent="false">
+%s
+	<outcomeDeclaration identifier="SCORE" cardinality="single" baseType="float">
+		<defaultValue><value>0</value></defaultValue>
+	</outcomeDeclaration>
+	<outcomeDeclaration identifier="MAXSCORE" cardinality="single" baseType="float">
+		<defaultValue><value>%d</value></defaultValue>
+	</outcomeDeclaration>`, qtiNamespace, item.id, html.EscapeString(html.UnescapeString(title)), response, points)
+}
+
+// score returns the score given for an answer with the given grade.
+func score(points uint, grade float64) string {
+	return strconv.FormatFloat(float64(points)*grade/100, 'g', 6, 64)
+}
+
+// multiChoice writes mc as an assessment item with a choiceInteraction.
+func (item *qtiItem) multiChoice(w io.Writer, mc *MultiChoice) error {
+	text, err := item.xhtml(mc.text, mc.files)
+	if err != nil {
+		return err
+	}
+
+	single := !mc.forceMultiple && mc.NCorrect() == 1
+	cardinality, maxChoices := "multiple", 0
+	if single {
+		cardinality, maxChoices = "single", 1
+	}
+
+	var response, choices strings.Builder
+	fmt.Fprintf(&response, `	<responseDeclaration identifier="RESPONSE" cardinality="%s" baseType="identifier">
+		<correctResponse>`, cardinality)
+	for i, a := range mc.answers {
+		if a.grade > 0 {
+			fmt.Fprintf(&response, `
+			<value>A%d</value>`, i+1)
+		}
+	}
+	fmt.Fprintf(&response, `
+		</correctResponse>
+		<mapping lowerBound="0" upperBound="%d" defaultValue="0">`, mc.points)
+	for i, a := range mc.answers {
+		fmt.Fprintf(&response, `
+			<mapEntry mapKey="A%d" mappedValue="%s"/>`, i+1, score(mc.points, a.grade))
+
+		answer, err := item.xhtml(a.text, a.files)
+		if err != nil {
+			return fmt.Errorf("Answer %d: %w", i+1, err)
+		}
+		fmt.Fprintf(&choices, `
+			<simpleChoice identifier="A%d">%s</simpleChoice>`, i+1, answer)
+	}
+	fmt.Fprint(&response, `
+		</mapping>
+	</responseDeclaration>`)
+
+	item.writeHeader(w, mc.name, mc.points, response.String())
+	fmt.Fprintf(w, `
+	<itemBody>
+		<div>%s</div>
+		<choiceInteraction responseIdentifier="RESPONSE" shuffle="%t" maxChoices="%d">%s
+		</choiceInteraction>
+	</itemBody>
+	<responseProcessing template="%s"/>
+</assessmentItem>
+`, text, mc.shuffle, maxChoices, choices.String(), qtiMapResponse)
+	return nil
+}
+
+// shortText writes q as an assessment item with a textEntryInteraction.
+func (item *qtiItem) shortText(w io.Writer, q *ShortText) error {
+	text, err := item.xhtml(q.text, q.files)
+	if err != nil {
+		return err
+	}
+
+	var response strings.Builder
+	fmt.Fprint(&response, `	<responseDeclaration identifier="RESPONSE" cardinality="single" baseType="string">
+		<correctResponse>`)
+	for _, a := range q.answers {
+		if a.grade == 100 {
+			fmt.Fprintf(&response, `
+			<value>%s</value>`, html.EscapeString(a.text))
+		}
+	}
+	fmt.Fprintf(&response, `
+		</correctResponse>
+		<mapping lowerBound="0" upperBound="%d" defaultValue="0">`, q.points)
+	for _, a := range q.answers {
+		fmt.Fprintf(&response, `
+			<mapEntry mapKey="%s" mappedValue="%s" caseSensitive="%t"/>`,
+			html.EscapeString(a.text), score(q.points, a.grade), q.caseSensitive)
+	}
+	fmt.Fprint(&response, `
+		</mapping>
+	</responseDeclaration>`)
+
+	item.writeHeader(w, q.name, q.points, response.String())
+	fmt.Fprintf(w, `
+	<itemBody>
+		<div>%s</div>
+		<p><textEntryInteraction responseIdentifier="RESPONSE" expectedLength="20"/></p>
+	</itemBody>
+	<responseProcessing template="%s"/>
+</assessmentItem>
+`, text, qtiMapResponse)
+	return nil
+}
+
+// numerical writes q as an assessment item with a textEntryInteraction. The
+// answers are compared in order, and the first matching answer determines the
+// score.
+func (item *qtiItem) numerical(w io.Writer, q *Numerical) error {
+	text, err := item.xhtml(q.text, q.files)
+	if err != nil {
+		return err
+	}
+
+	var response, processing strings.Builder
+	fmt.Fprint(&response, `	<responseDeclaration identifier="RESPONSE" cardinality="single" baseType="float">
+		<correctResponse>`)
+	for i, a := range q.answers {
+		value := strings.TrimSpace(a.text)
+		if a.grade == 100 && value != "*" {
+			fmt.Fprintf(&response, `
+			<value>%s</value>`, html.EscapeString(value))
+		}
+
+		condition := `<not><isNull><variable identifier="RESPONSE"/></isNull></not>`
+		if value != "*" {
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				return fmt.Errorf("Answer %d is not a number: %q", i+1, a.text)
+			}
+			tolerance := `toleranceMode="exact"`
+			if tol, ok := a.GetOption("tolerance"); ok {
+				tolerance = fmt.Sprintf(`toleranceMode="absolute" tolerance="%s %s"`, html.EscapeString(tol), html.EscapeString(tol))
+			}
+			condition = fmt.Sprintf(`<equal %s><variable identifier="RESPONSE"/><baseValue baseType="float">%s</baseValue></equal>`,
+				tolerance, value)
+		}
+
+		branch := "responseElseIf"
+		if i == 0 {
+			fmt.Fprint(&processing, `
+		<responseCondition>`)
+			branch = "responseIf"
+		}
+		fmt.Fprintf(&processing, `
+			<%s>
+				%s
+				<setOutcomeValue identifier="SCORE"><baseValue baseType="float">%s</baseValue></setOutcomeValue>
+			</%s>`, branch, condition, score(q.points, a.grade), branch)
+	}
+	fmt.Fprint(&response, `
+		</correctResponse>
+	</responseDeclaration>`)
+	if len(q.answers) > 0 {
+		fmt.Fprint(&processing, `
+		</responseCondition>`)
+	}
+
+	item.writeHeader(w, q.name, q.points, response.String())
+	fmt.Fprintf(w, `
+	<itemBody>
+		<div>%s</div>
+		<p><textEntryInteraction responseIdentifier="RESPONSE" expectedLength="10"/></p>
+	</itemBody>
+	<responseProcessing>%s
+	</responseProcessing>
+</assessmentItem>
+`, text, processing.String())
+	return nil
+}
+
+// dropText writes dt as an assessment item with a gapMatchInteraction. Each
+// [[n]] placeholder becomes a gap, and each correctly filled gap gives an equal
+// share of the points.
+func (item *qtiItem) dropText(w io.Writer, dt *DropText) error {
+	var pairs []string
+	var body strings.Builder
+	for _, seg := range segments(dt.text) {
+		if seg.kind != placeholderSegment {
+			body.WriteString(seg.text)
+			continue
+		}
+		n, _ := strconv.Atoi(strings.Trim(seg.text, "[]"))
+		if n < 1 || n > len(dt.markers) {
+			return fmt.Errorf("Placeholder %s refers to a nonexistent marker", seg.text)
+		}
+		pairs = append(pairs, fmt.Sprintf("M%d G%d", n, len(pairs)+1))
+		fmt.Fprintf(&body, `<gap identifier="G%d"/>`, len(pairs))
+	}
+	text, err := item.xhtml(body.String(), dt.files)
+	if err != nil {
+		return err
+	}
+
+	item.writeHeader(w, dt.name, dt.points, qtiPairResponse(pairs, dt.points))
+
+	fmt.Fprintf(w, `
+	<itemBody>
+		<gapMatchInteraction responseIdentifier="RESPONSE" shuffle="%t">`, dt.shuffle)
+	for i, m := range dt.markers {
+		matchMax := 1
+		if m.unlimited {
+			matchMax = 0
+		}
+		fmt.Fprintf(w, `
+			<gapText identifier="M%d" matchMax="%d">%s</gapText>`, i+1, matchMax, html.EscapeString(m.text))
+	}
+	fmt.Fprintf(w, `
+			<div>%s</div>
+		</gapMatchInteraction>
+	</itemBody>
+	<responseProcessing template="%s"/>
+</assessmentItem>
+`, text, qtiMapResponse)
+	return nil
+}
+
+// dropMarker writes dm as an assessment item with a graphicGapMatchInteraction.
+// The markers are included as svg images, and each correctly filled zone gives
+// an equal share of the points.
+func (item *qtiItem) dropMarker(w io.Writer, dm *DropMarker) error {
+	text, err := item.xhtml(dm.text+backgroundDescription(dm.img), dm.files)
+	if err != nil {
+		return err
+	}
+
+	dim, ok := pixelSize(dm.img)
+	if !ok {
+		return fmt.Errorf("The size of the background image is unknown")
+	}
+	background, err := item.addImage("background", dm.img)
+	if err != nil {
+		return err
+	}
+
+	pairs := make([]string, len(dm.zones))
+	for i, z := range dm.zones {
+		pairs[i] = fmt.Sprintf("M%d Z%d", z.correctMark+1, i+1)
+	}
+	item.writeHeader(w, dm.name, dm.points, qtiPairResponse(pairs, dm.points))
+
+	fmt.Fprintf(w, `
+	<itemBody>
+		<div>%s</div>
+		<graphicGapMatchInteraction responseIdentifier="RESPONSE">
+			<object type="%s" data="%s" width="%.0f" height="%.0f"/>`,
+		text, mime.TypeByExtension("."+dm.img.Filetype()), background, dim[0], dim[1])
+
+	for i, m := range dm.markers {
+		svg, width, height := markerSvg(m.text)
+		path := fmt.Sprintf("media/%s-marker%d.svg", item.id, i+1)
+		item.files = append(item.files, qtiFile{"items/" + path, []byte(svg)})
+		fmt.Fprintf(w, `
+			<gapImg identifier="M%d" matchMax="%d"><object type="image/svg+xml" data="%s" width="%d" height="%d"/></gapImg>`,
+			i+1, m.nDrags, path, width, height)
+	}
+
+	for i, z := range dm.zones {
+		shape, coords := "poly", strings.ReplaceAll(z.coords(), ";", ",")
+		switch z.shape {
+		case Circle:
+			shape = "circle"
+		case Rectangle:
+			shape = "rect"
+			coords = fmt.Sprintf("%.0f,%.0f,%.0f,%.0f", z.points[0][0], z.points[0][1],
+				z.points[0][0]+z.size[0], z.points[0][1]+z.size[1])
+		}
+		fmt.Fprintf(w, `
+			<associableHotspot identifier="Z%d" shape="%s" coords="%s" matchMax="1"/>`, i+1, shape, coords)
+	}
+
+	fmt.Fprintf(w, `
+		</graphicGapMatchInteraction>
+	</itemBody>
+	<responseProcessing template="%s"/>
+</assessmentItem>
+`, qtiMapResponse)
+	return nil
+}
+
+// qtiPairResponse returns the declaration of a response consisting of the
+// given directed pairs. Each pair gives an equal share of the points.
+func qtiPairResponse(pairs []string, points uint) string {
+	var b strings.Builder
+	fmt.Fprint(&b, `	<responseDeclaration identifier="RESPONSE" cardinality="multiple" baseType="directedPair">
+		<correctResponse>`)
+	for _, v := range pairs {
+		fmt.Fprintf(&b, `
+			<value>%s</value>`, v)
+	}
+	fmt.Fprintf(&b, `
+		</correctResponse>
+		<mapping lowerBound="0" upperBound="%d" defaultValue="0">`, points)
+	for _, v := range pairs {
+		fmt.Fprintf(&b, `
+			<mapEntry mapKey="%s" mappedValue="%s"/>`, v, score(points, 100/float64(len(pairs))))
+	}
+	fmt.Fprint(&b, `
+		</mapping>
+	</responseDeclaration>`)
+	return b.String()
+}
+
+// markerSvg returns an svg image showing the text of a marker along with its
+// width and height.
+func markerSvg(text string) (svg string, width, height int) {
+	width, height = 16+8*utf8.RuneCountInString(text), 24
+	svg = fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">
+<rect x="0.5" y="0.5" width="%d" height="%d" rx="4" fill="#fff" stroke="#000"/>
+<text x="%d" y="17" font-family="sans-serif" font-size="14" text-anchor="middle">%s</text>
+</svg>
+`, width, height, width, height, width-1, height-1, width/2, html.EscapeString(text))
+	return svg, width, height
+}
+
+// addImage adds img to the files of item and returns its path relative to the
+// item. Images with identical contents share a single file, and a suffix is
+// added if different images are given the same name.
+func (item *qtiItem) addImage(name string, img graphics.Image) (string, error) {
+	var b strings.Builder
+	img.ToBase64(&b)
+	content, err := base64.StdEncoding.DecodeString(b.String())
+	if err != nil {
+		return "", err
+	}
+
+	for _, f := range item.files {
+		if bytes.Equal(f.content, content) {
+			// The image is attached to several text fields
+			return strings.TrimPrefix(f.path, "items/"), nil
+		}
+	}
+
+	ext := "." + img.Filetype()
+	stem := qtiIdentifier(strings.TrimSuffix(name, ext))
+	path := fmt.Sprintf("media/%s-%s%s", item.id, stem, ext)
+	for n := 2; item.hasFile("items/" + path); n++ {
+		path = fmt.Sprintf("media/%s-%s-%d%s", item.id, stem, n, ext)
+	}
+	item.files = append(item.files, qtiFile{"items/" + path, content})
+	return path, nil
+}
+
+// hasFile reports whether item contains a file with the given path.
+func (item *qtiItem) hasFile(path string) bool {
+	for _, f := range item.files {
+		if f.path == path {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	reSvgStart = regexp.MustCompile(`(?i)<svg[\s/>]`)
+	reSvgXmlns = regexp.MustCompile(`^<svg[^>]*\sxmlns\s*=`)
+)
+
+// svgsToFiles replaces the svg elements written directly in the HTML code s,
+// e.g. by SvgImage.ToHtml, with img tags referring to files added to item. QTI
+// does not allow svg elements in the item body. The alternative text of each
+// image is taken from its title element.
+func (item *qtiItem) svgsToFiles(s string) (string, error) {
+	var b strings.Builder
+	for n := 1; ; n++ {
+		loc := reSvgStart.FindStringIndex(s)
+		if loc == nil {
+			break
+		}
+		b.WriteString(s[:loc[0]])
+		s = s[loc[0]:]
+
+		// Find the end of the svg element and its title
+		d := xml.NewDecoder(strings.NewReader(s))
+		d.Entity = xml.HTMLEntity
+		var title strings.Builder
+		inTitle := false
+		depth := 0
+		for {
+			tok, err := d.Token()
+			if err != nil {
+				return "", fmt.Errorf("Svg %d is not well-formed XML: %v", n, err)
+			}
+			switch tok := tok.(type) {
+			case xml.StartElement:
+				depth++
+				inTitle = depth == 2 && tok.Name.Local == "title" && title.Len() == 0
+			case xml.EndElement:
+				depth--
+				inTitle = false
+			case xml.CharData:
+				if inTitle {
+					title.Write(tok)
+				}
+			}
+			if depth == 0 {
+				break
+			}
+		}
+		end := int(d.InputOffset())
+
+		content := s[:end]
+		if !reSvgXmlns.MatchString(content) {
+			content = `<svg xmlns="http://www.w3.org/2000/svg"` + content[len("<svg"):]
+		}
+		img, err := graphics.SvgFromBytes([]byte(content))
+		if err != nil {
+			return "", fmt.Errorf("Svg %d: %v", n, err)
+		}
+		path, err := item.addImage(fmt.Sprintf("svg%d.svg", n), img)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, `<img src="%s" alt="%s"`, path, html.EscapeString(strings.TrimSpace(title.String())))
+		if dim, ok := pixelSize(img); ok {
+			fmt.Fprintf(&b, ` width="%.1f" height="%.1f"`, dim[0], dim[1])
+		}
+		b.WriteString(" />")
+		s = s[end:]
+	}
+	b.WriteString(s)
+	return b.String(), nil
+}
+
+var reVoidTag = regexp.MustCompile(`(?i)^<(area|br|col|hr|img|input|wbr)\b`)
+
+// xhtml converts the HTML code s into XHTML. References to the given attached
+// files are replaced by paths to files added to item, and so are svg elements
+// (see svgsToFiles). An error is returned if
+// the result is not well-formed.
+func (item *qtiItem) xhtml(s string, files []*File) (string, error) {
+	for _, f := range files {
+		path, err := item.addImage(f.name, f.img)
+		if err != nil {
+			return "", err
+		}
+		s = strings.ReplaceAll(s, "@@PLUGINFILE@@/"+url.PathEscape(f.name), path)
+	}
+	s, err := item.svgsToFiles(s)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, seg := range segments(s) {
+		switch {
+		case seg.kind != htmlSegment:
+			// Replace HTML entities that are not defined in XML
+			b.WriteString(html.EscapeString(html.UnescapeString(seg.text)))
+		case strings.HasPrefix(seg.text, "<!"):
+			// Comments and declarations
+		case reVoidTag.MatchString(seg.text) && !strings.HasSuffix(seg.text, "/>"):
+			b.WriteString(strings.TrimSuffix(seg.text, ">") + " />")
+		default:
+			b.WriteString(seg.text)
+		}
+	}
+
+	// Check that the result is well-formed
+	d := xml.NewDecoder(strings.NewReader("<div>" + b.String() + "</div>"))
+	for {
+		_, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("Text is not well-formed XHTML: %v", err)
+		}
+	}
+	return b.String(), nil
+}
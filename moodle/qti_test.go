@@ -0,0 +1,187 @@
+package moodle
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ReneBoedker/MoodlishInquisition/graphics"
+)
+
+func TestToQti(t *testing.T) {
+	f, _ := NewFile("my figure", testPng(t, 40, 20))
+	mc := NewMultiChoice("Which figure?<br>"+f.Html()+"&nbsp;", 2, []*Answer{
+		NewAnswer("This "+f.Html(), 100),
+		NewAnswer("That", 0),
+	})
+	mc.AddFiles(f)
+	mc.answers[0].AddFiles(f)
+
+	st := NewShortText("Name a colour", 1, []*Answer{NewAnswer("red", 100), NewAnswer("pink", 50)})
+	num := NewNumerical(`Compute \(\pi\)`, 1, []*Answer{NewAnswer("3.14", 100), NewAnswer("*", 0)})
+	num.answers[0].SetOption("tolerance", "0.01")
+	dt := NewDropText("A [[1]] of [[2]]", 2, []*TextMark{
+		NewTextMark("cat", 0, false),
+		NewTextMark("a<b", 0, true),
+	})
+	circle, _ := NewZone(Circle, [2]float64{20, 20}, 20, 20, 0)
+	rect, _ := NewZone(Rectangle, [2]float64{70, 50}, 20, 10, 0)
+	dm := NewDropMarker("Place A", testPng(t, 100, 80), 1, []*Mark{NewMark("A", 0)}, []*Zone{circle, rect})
+
+	var b bytes.Buffer
+	if err := NewQuestionBank("Test & bank", []Question{mc, st, num, dt, dm}).ToQti(&b); err != nil {
+		t.Fatalf("Exporting to QTI caused error: %s", err)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		t.Fatalf("Reading package caused error: %s", err)
+	}
+	files := make(map[string]string)
+	for _, v := range r.File {
+		if _, ok := files[v.Name]; ok {
+			t.Errorf("Package contains %s twice", v.Name)
+		}
+		rc, _ := v.Open()
+		content, _ := io.ReadAll(rc)
+		rc.Close()
+		files[v.Name] = string(content)
+
+		if !strings.HasSuffix(v.Name, ".xml") && !strings.HasSuffix(v.Name, ".svg") {
+			continue
+		}
+		d := xml.NewDecoder(bytes.NewReader(content))
+		for {
+			if _, err := d.Token(); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Errorf("%s is not well-formed: %s", v.Name, err)
+				break
+			}
+		}
+	}
+
+	expected := map[string][]string{
+		"imsmanifest.xml": {
+			`<resource identifier="item1" type="imsqti_item_xmlv2p1" href="items/item1.xml">`,
+			`<file href="items/media/item1-my_figure.png"/>`,
+			`<dependency identifierref="item5"/>`,
+		},
+		"test.xml": {`title="Test &amp; bank"`, `<assessmentItemRef identifier="item3" href="items/item3.xml"/>`},
+		"items/item1.xml": {
+			"<div>Which figure?<br /><img src=\"media/item1-my_figure.png\" width=\"40.0\" height=\"20.0\" />\u00a0</div>",
+			`<choiceInteraction responseIdentifier="RESPONSE" shuffle="true" maxChoices="1">`,
+			`<mapEntry mapKey="A1" mappedValue="2"/>`,
+		},
+		"items/item2.xml": {`<mapEntry mapKey="pink" mappedValue="0.5" caseSensitive="false"/>`},
+		"items/item3.xml": {
+			`<equal toleranceMode="absolute" tolerance="0.01 0.01"><variable identifier="RESPONSE"/><baseValue baseType="float">3.14</baseValue></equal>`,
+			`<responseElseIf>`,
+		},
+		"items/item4.xml": {
+			`<gapText identifier="M2" matchMax="0">a&lt;b</gapText>`,
+			`<div>A <gap identifier="G1"/> of <gap identifier="G2"/></div>`,
+			`<mapEntry mapKey="M2 G2" mappedValue="1"/>`,
+		},
+		"items/item5.xml": {
+			`<object type="image/png" data="media/item5-background.png" width="100" height="80"/>`,
+			`<gapImg identifier="M1" matchMax="0"><object type="image/svg+xml" data="media/item5-marker1.svg"`,
+			`<associableHotspot identifier="Z1" shape="circle" coords="20,20,10" matchMax="1"/>`,
+			`<associableHotspot identifier="Z2" shape="rect" coords="60,45,80,55" matchMax="1"/>`,
+		},
+		"items/media/item5-marker1.svg": {`>A</text>`},
+	}
+	for name, contents := range expected {
+		for _, v := range contents {
+			if !strings.Contains(files[name], v) {
+				t.Errorf("%s does not contain %q:\n%s", name, v, files[name])
+			}
+		}
+	}
+	if _, ok := files["items/media/item5-background.png"]; !ok {
+		t.Errorf("Package does not contain the background image")
+	}
+
+	bad := NewMultiChoice("Unclosed <b>tag", 1, []*Answer{NewAnswer("A", 100), NewAnswer("B", 0)})
+	if err := NewQuestionBank("Test", []Question{bad}).ToQti(&b); err == nil {
+		t.Errorf("Malformed HTML failed to return an error")
+	}
+}
+
+func TestQtiAddImage(t *testing.T) {
+	item := &qtiItem{id: "item1"}
+	small, large := testPng(t, 10, 10), testPng(t, 20, 20)
+	for _, v := range []struct {
+		name string
+		img  *graphics.BinaryImage
+		want string
+	}{
+		{"a b.png", small, "media/item1-a_b.png"},
+		{"a_b.png", large, "media/item1-a_b-2.png"},
+		{"copy", small, "media/item1-a_b.png"},
+		{"background", testPng(t, 30, 30), "media/item1-background.png"},
+		{"background", testPng(t, 40, 40), "media/item1-background-2.png"},
+	} {
+		path, err := item.addImage(v.name, v.img)
+		if err != nil {
+			t.Fatalf("Adding %q caused error: %s", v.name, err)
+		}
+		if path != v.want {
+			t.Errorf("Image %q was stored as %q, but expected %q", v.name, path, v.want)
+		}
+	}
+	if len(item.files) != 4 {
+		t.Errorf("Expected 4 files, but got %d", len(item.files))
+	}
+}
+
+func TestQtiInlineSvg(t *testing.T) {
+	svg, err := graphics.SvgFromBytes([]byte(`<svg xmlns="http://www.w3.org/2000/svg" width="75pt" height="30pt"><circle r="5"/></svg>`))
+	if err != nil {
+		t.Fatalf("Reading svg caused error: %s", err)
+	}
+	svg.SetTitle("A dot")
+	var out strings.Builder
+	svg.ToHtml(&out)
+
+	item := &qtiItem{id: "item1"}
+	text, err := item.xhtml("See "+out.String()+` and <svg width="10" height="10"/>`, nil)
+	if err != nil {
+		t.Fatalf("Converting inline svgs caused error: %s", err)
+	}
+	for _, v := range []string{
+		`See <p><img src="media/item1-svg1.svg" alt="A dot" width="100.0" height="40.0" /></p>`,
+		`and <img src="media/item1-svg2.svg" alt="" width="10.0" height="10.0" />`,
+	} {
+		if !strings.Contains(text, v) {
+			t.Errorf("Text does not contain %q:\n%s", v, text)
+		}
+	}
+	if strings.Contains(text, "<svg") {
+		t.Errorf("Text still contains an svg element:\n%s", text)
+	}
+	if len(item.files) != 2 {
+		t.Fatalf("Expected 2 files, but got %d", len(item.files))
+	}
+	if !bytes.Contains(item.files[1].content, []byte(`xmlns="http://www.w3.org/2000/svg"`)) {
+		t.Errorf("Svg file lacks the svg namespace:\n%s", item.files[1].content)
+	}
+
+	if _, err := item.xhtml(`<svg width="10" height="10"><g></svg>`, nil); err == nil {
+		t.Errorf("Malformed svg failed to return an error")
+	}
+}
+
+func TestQtiNumericalWithoutAnswers(t *testing.T) {
+	item := &qtiItem{id: "item1"}
+	var b strings.Builder
+	if err := item.numerical(&b, NewNumerical("Compute", 1, nil)); err != nil {
+		t.Fatalf("Exporting numerical question caused error: %s", err)
+	}
+	if strings.Contains(b.String(), "responseCondition") {
+		t.Errorf("Question without answers contains a responseCondition:\n%s", b.String())
+	}
+}
@@ -9,4 +9,11 @@ type Question interface {
 	ToXml(io.Writer)
 	MoodleName() string
 	SetShuffleAnswers(bool)
+	Validate() error // Reports problems preventing Moodle from importing the question
+}
+
+// FileAttacher is implemented by questions to which files can be attached. All
+// question types of this package implement it.
+type FileAttacher interface {
+	AddFiles(...*File)
 }
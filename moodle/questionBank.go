@@ -5,11 +5,8 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"regexp"
 )
 
-var reMathDelims = regexp.MustCompile(`(\$+)[^$]+(\$+)?`)
-
 // QuestionBank is a collection of questions
 type QuestionBank struct {
 	name      string
@@ -46,7 +43,18 @@ func (qb *QuestionBank) ToXml(w io.Writer) {
 // It generates the specified number of questions and writes it to the given
 // file (after creating it).
 // An error is returned if the file already exists.
+//
+// Each question is checked using its Validate method, and the math in its text
+// fields is validated before writing the file. If problems with the math are
+// found, the returned error wraps a *SyntaxError for each of them.
 func GenerateQuestionBank(fName string, nQuestions int, gen func() Question) error {
+	return GenerateQuestionBankInCategory(fName, fName, nQuestions, gen)
+}
+
+// GenerateQuestionBankInCategory works like GenerateQuestionBank, but places
+// the questions in the given category rather than a category named after the
+// file.
+func GenerateQuestionBankInCategory(fName, category string, nQuestions int, gen func() Question) error {
 	// Check that file does not exist
 	if fileExists(fName) {
 		return fmt.Errorf("File %q already exists", fName)
@@ -56,11 +64,14 @@ func GenerateQuestionBank(fName string, nQuestions int, gen func() Question) err
 	questions := make([]Question, nQuestions, nQuestions)
 	for i := 0; i < nQuestions; i++ {
 		questions[i] = gen()
-		if err := validateSyntax(questions[i]); err != nil {
+		if err := questions[i].Validate(); err != nil {
+			return fmt.Errorf("Question %d: %w", i+1, err)
+		}
+		if err := validateSyntax(i+1, questions[i]); err != nil {
 			return err
 		}
 	}
-	qb := NewQuestionBank(fName, questions)
+	qb := NewQuestionBank(category, questions)
 
 	f, err := os.Create(fName)
 	if err != nil {
@@ -75,19 +86,3 @@ func fileExists(fName string) bool {
 	_, err := os.Stat(fName)
 	return !errors.Is(err, os.ErrNotExist)
 }
-
-func validateSyntax(q Question) error {
-	//return validateTeX(q.GetDescription())
-	return nil
-}
-
-// validateTeX is currently not used
-func validateTeX(s string) error {
-	matches := reMathDelims.FindAllStringSubmatch(s, -1)
-	for _, v := range matches {
-		if len(v[1]) != 2 || len(v[2]) != 2 {
-			return fmt.Errorf("Wrong or missing delimiters in string:\n%q", s)
-		}
-	}
-	return nil
-}
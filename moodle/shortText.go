@@ -1,6 +1,7 @@
 package moodle
 
 import (
+	"errors"
 	"fmt"
 	"hash/fnv"
 	"io"
@@ -13,6 +14,7 @@ type ShortText struct {
 	name          string
 	points        uint
 	text          string
+	files         []*File
 	answers       []*Answer
 	caseSensitive bool
 }
@@ -45,6 +47,25 @@ func (q *ShortText) SetCaseSensitivity(b bool) {
 	q.caseSensitive = b
 }
 
+// AddFiles attaches files to the question text of q. The files must be
+// referenced in the question text using their Html method.
+func (q *ShortText) AddFiles(files ...*File) {
+	q.files = append(q.files, files...)
+}
+
+// Validate checks that q can be imported into Moodle. It must have an answer
+// with grade 100, and all grades must be non-negative and allowed by Moodle.
+// Grades that only differ from an allowed grade by rounding are snapped to the
+// allowed grade.
+func (q *ShortText) Validate() error {
+	errs := validateText(q.text)
+	errs = append(errs, validateAnswers(q.answers, false)...)
+	if !hasFullGrade(q.answers) {
+		errs = append(errs, fmt.Errorf("No answer has grade 100"))
+	}
+	return errors.Join(errs...)
+}
+
 // SetShuffleAnswers allows enabling or disabling shuffling of answers. This has
 // no effect for 'Short Answer' question types.
 func (q *ShortText) SetShuffleAnswers(b bool) {
@@ -61,10 +82,12 @@ func (q *ShortText) ToXml(w io.Writer) {
 		<text>%s</text>
 	</name>
 	<questiontext format="html">
-		<text><![CDATA[%s]]></text>
+		<text><![CDATA[%s]]></text>`,
+		q.name, q.text)
+	writeFiles(w, q.files, "\t\t")
+	fmt.Fprintf(w, `
 	</questiontext>
-	<defaultgrade>%d</defaultgrade>`,
-		q.name, q.text, q.points)
+	<defaultgrade>%d</defaultgrade>`, q.points)
 	defer fmt.Fprintf(w, `
 </question>`)
 
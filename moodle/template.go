@@ -0,0 +1,404 @@
+package moodle
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/ReneBoedker/MoodlishInquisition/graphics"
+	"github.com/ReneBoedker/MoodlishInquisition/unif"
+)
+
+// QuestionTemplate describes a family of questions whose text, answers and
+// feedback are text/template templates. Each call to Instantiate executes the
+// templates to produce a new variant of the question.
+//
+// The templates of a variant are executed in the order text, answers (each
+// followed by its feedback and options). They share a map of values, which is
+// available as dot. The map initially contains the values returned by the
+// function given to SetData, and templates may add values using set, e.g.
+//
+//	{{set "a" (randInt 1 9)}}What is \({{.a}}+1\)?
+//
+// after which the answer template may refer to {{add .a 1}}. Keys that are
+// not defined cause an error.
+//
+// Besides the built-in functions of text/template, the following are
+// available:
+//
+//	randInt a b        Uniformly random integer in [a,b]
+//	randNonZero a b    Uniformly random non-zero integer in [a,b]
+//	randBool           Uniformly random boolean
+//	set key value      Store value under key (the output is empty)
+//	add, sub, mul, div Arithmetic on two numbers
+//	round x digits     Round x to the given number of decimals
+//	latexNum x         Format x for use in LaTeX math
+//	signed x           Format x with an explicit sign, as in x^2 {{signed .b}}x
+//	frac p q           The reduced fraction p/q of two integers in LaTeX
+//	escapeMath s       Apply EscapeMath to s
+//	tikz code          Compile TikZ code and embed the image as HTML
+//
+// Numbers returned by the arithmetic functions are of type float64, and are
+// printed without trailing zeros.
+type QuestionTemplate struct {
+	newQuestion func(description string, points uint, answers []*Answer) Question
+	text        *template.Template
+	points      uint
+	answers     []*AnswerTemplate
+	data        func() map[string]any
+}
+
+// AnswerTemplate is an answer of a QuestionTemplate.
+type AnswerTemplate struct {
+	text     *template.Template
+	grade    float64
+	feedback *template.Template
+	options  []optionTemplate // In the order they were set
+}
+
+// optionTemplate is an option of an AnswerTemplate.
+type optionTemplate struct {
+	name  string
+	value *template.Template
+}
+
+// NewMultiChoiceTemplate creates a template of multiple choice questions. An
+// error is returned if text is not a valid template.
+func NewMultiChoiceTemplate(text string, points uint) (*QuestionTemplate, error) {
+	return newQuestionTemplate(text, points, func(description string, points uint, answers []*Answer) Question {
+		return NewMultiChoice(description, points, answers)
+	})
+}
+
+// NewNumericalTemplate creates a template of numerical questions. An error is
+// returned if text is not a valid template.
+func NewNumericalTemplate(text string, points uint) (*QuestionTemplate, error) {
+	return newQuestionTemplate(text, points, func(description string, points uint, answers []*Answer) Question {
+		return NewNumerical(description, points, answers)
+	})
+}
+
+// NewShortTextTemplate creates a template of short answer questions. An error
+// is returned if text is not a valid template.
+func NewShortTextTemplate(text string, points uint) (*QuestionTemplate, error) {
+	return newQuestionTemplate(text, points, func(description string, points uint, answers []*Answer) Question {
+		return NewShortText(description, points, answers)
+	})
+}
+
+func newQuestionTemplate(text string, points uint, newQuestion func(string, uint, []*Answer) Question) (*QuestionTemplate, error) {
+	tmpl, err := parseTemplate("text", text)
+	if err != nil {
+		return nil, err
+	}
+	return &QuestionTemplate{
+		newQuestion: newQuestion,
+		text:        tmpl,
+		points:      points,
+	}, nil
+}
+
+// AddAnswer adds an answer whose text and feedback are templates. An error is
+// returned if either is not a valid template.
+func (qt *QuestionTemplate) AddAnswer(text string, grade float64, feedback string) (*AnswerTemplate, error) {
+	n := len(qt.answers) + 1
+	textTmpl, err := parseTemplate(fmt.Sprintf("answer %d", n), text)
+	if err != nil {
+		return nil, err
+	}
+	feedbackTmpl, err := parseTemplate(fmt.Sprintf("feedback of answer %d", n), feedback)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &AnswerTemplate{
+		text:     textTmpl,
+		grade:    grade,
+		feedback: feedbackTmpl,
+	}
+	qt.answers = append(qt.answers, a)
+	return a, nil
+}
+
+// SetOption sets an option of the answer, such as 'tolerance' for numerical
+// questions. The value is a template. See also Answer.SetOption.
+//
+// Options are executed in the order they were first set, so random values are
+// drawn in the same order each time.
+func (at *AnswerTemplate) SetOption(option, value string) error {
+	tmpl, err := parseTemplate(option, value)
+	if err != nil {
+		return err
+	}
+	for i, v := range at.options {
+		if v.name == option {
+			at.options[i].value = tmpl
+			return nil
+		}
+	}
+	at.options = append(at.options, optionTemplate{option, tmpl})
+	return nil
+}
+
+// SetData sets a function generating the values of each variant. The function
+// is called once per variant, and the returned map is available as dot in the
+// templates.
+func (qt *QuestionTemplate) SetData(data func() map[string]any) {
+	qt.data = data
+}
+
+// Instantiate executes the templates to create a new variant of the question.
+func (qt *QuestionTemplate) Instantiate() (Question, error) {
+	vars := make(map[string]any)
+	if qt.data != nil {
+		for k, v := range qt.data() {
+			vars[k] = v
+		}
+	}
+	funcs := template.FuncMap{
+		"set": func(key string, value any) string {
+			vars[key] = value
+			return ""
+		},
+	}
+
+	text, err := executeTemplate(qt.text, funcs, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	answers := make([]*Answer, len(qt.answers))
+	for i, at := range qt.answers {
+		response, err := executeTemplate(at.text, funcs, vars)
+		if err != nil {
+			return nil, err
+		}
+		feedback, err := executeTemplate(at.feedback, funcs, vars)
+		if err != nil {
+			return nil, err
+		}
+		answers[i] = NewAnswerWithFeedback(response, at.grade, feedback)
+
+		for _, option := range at.options {
+			value, err := executeTemplate(option.value, funcs, vars)
+			if err != nil {
+				return nil, err
+			}
+			answers[i].SetOption(option.name, value)
+		}
+	}
+
+	return qt.newQuestion(text, qt.points, answers), nil
+}
+
+// Generate creates n variants of the question.
+func (qt *QuestionTemplate) Generate(n int) ([]Question, error) {
+	questions := make([]Question, n)
+	for i := range questions {
+		q, err := qt.Instantiate()
+		if err != nil {
+			return nil, fmt.Errorf("Variant %d: %w", i+1, err)
+		}
+		questions[i] = q
+	}
+	return questions, nil
+}
+
+// parseTemplate parses s as a template with the helper functions.
+func parseTemplate(name, s string) (*template.Template, error) {
+	return template.New(name).Option("missingkey=error").Funcs(templateFuncs).Parse(s)
+}
+
+// executeTemplate executes a copy of tmpl in which funcs replace the helper
+// functions of the same name. This allows the functions to refer to the data
+// of a single variant.
+func executeTemplate(tmpl *template.Template, funcs template.FuncMap, data any) (string, error) {
+	clone, err := tmpl.Clone()
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err := clone.Funcs(funcs).Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// templateFuncs are the helper functions available in templates.
+var templateFuncs = template.FuncMap{
+	"randInt": func(a, b int) int {
+		return unif.IntInInterval(a, b, true)
+	},
+	"randNonZero": func(a, b int) int {
+		return unif.IntInInterval(a, b, false)
+	},
+	"randBool": unif.Bool,
+	"set": func(key string, value any) (string, error) {
+		// Replaced when a variant is instantiated
+		return "", fmt.Errorf("set is only available when instantiating a template")
+	},
+	"add": func(x, y any) (float64, error) {
+		return arithmetic(x, y, func(a, b float64) float64 { return a + b })
+	},
+	"sub": func(x, y any) (float64, error) {
+		return arithmetic(x, y, func(a, b float64) float64 { return a - b })
+	},
+	"mul": func(x, y any) (float64, error) {
+		return arithmetic(x, y, func(a, b float64) float64 { return a * b })
+	},
+	"div": func(x, y any) (float64, error) {
+		if b, err := toFloat(y); err == nil && b == 0 {
+			return 0, fmt.Errorf("Division by zero")
+		}
+		return arithmetic(x, y, func(a, b float64) float64 { return a / b })
+	},
+	"round": func(x any, digits int) (float64, error) {
+		v, err := toFloat(x)
+		if err != nil {
+			return 0, err
+		}
+		scale := math.Pow(10, float64(digits))
+		return math.Round(v*scale) / scale, nil
+	},
+	"latexNum":   latexNum,
+	"signed":     signed,
+	"frac":       latexFrac,
+	"escapeMath": EscapeMath,
+	"tikz":       tikzHtml,
+}
+
+// toFloat converts a number of any built-in type to float64.
+func toFloat(x any) (float64, error) {
+	switch v := x.(type) {
+	case int:
+		return float64(v), nil
+	case int8:
+		return float64(v), nil
+	case int16:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint:
+		return float64(v), nil
+	case uint8:
+		return float64(v), nil
+	case uint16:
+		return float64(v), nil
+	case uint32:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	}
+	return 0, fmt.Errorf("Expected a number, but received %v of type %T", x, x)
+}
+
+// toInt converts a number with an integer value to int.
+func toInt(x any) (int, error) {
+	v, err := toFloat(x)
+	if err != nil {
+		return 0, err
+	}
+	if v != math.Trunc(v) || math.Abs(v) > math.MaxInt32 {
+		return 0, fmt.Errorf("Expected an integer, but received %v", x)
+	}
+	return int(v), nil
+}
+
+// arithmetic applies op to x and y after converting them to float64.
+func arithmetic(x, y any, op func(a, b float64) float64) (float64, error) {
+	a, err := toFloat(x)
+	if err != nil {
+		return 0, err
+	}
+	b, err := toFloat(y)
+	if err != nil {
+		return 0, err
+	}
+	return op(a, b), nil
+}
+
+// latexNum formats x for LaTeX math. Very large and small numbers are written
+// using powers of ten.
+func latexNum(x any) (string, error) {
+	v, err := toFloat(x)
+	if err != nil {
+		return "", err
+	}
+	if v == 0 {
+		// Avoid -0
+		return "0", nil
+	}
+	s := strconv.FormatFloat(v, 'g', -1, 64)
+	mantissa, exponent, ok := strings.Cut(s, "e")
+	if !ok {
+		return s, nil
+	}
+	exp, _ := strconv.Atoi(exponent)
+	if mantissa == "1" || mantissa == "-1" {
+		return fmt.Sprintf(`%s10^{%d}`, strings.TrimSuffix(mantissa, "1"), exp), nil
+	}
+	return fmt.Sprintf(`%s\cdot 10^{%d}`, mantissa, exp), nil
+}
+
+// signed formats x for LaTeX math with an explicit sign.
+func signed(x any) (string, error) {
+	s, err := latexNum(x)
+	if err != nil || strings.HasPrefix(s, "-") {
+		return s, err
+	}
+	return "+" + s, nil
+}
+
+// latexFrac returns the reduced fraction x/y in LaTeX, where x and y must be
+// integers. The fraction is written as an integer if possible.
+func latexFrac(x, y any) (string, error) {
+	p, err := toInt(x)
+	if err != nil {
+		return "", err
+	}
+	q, err := toInt(y)
+	if err != nil {
+		return "", err
+	}
+	if q == 0 {
+		return "", fmt.Errorf("Division by zero")
+	}
+	if q < 0 {
+		p, q = -p, -q
+	}
+	a, b := p, q
+	if a < 0 {
+		a = -a
+	}
+	for b != 0 {
+		a, b = b, a%b
+	}
+	p, q = p/a, q/a
+
+	switch {
+	case q == 1:
+		return strconv.Itoa(p), nil
+	case p < 0:
+		return fmt.Sprintf(`-\frac{%d}{%d}`, -p, q), nil
+	}
+	return fmt.Sprintf(`\frac{%d}{%d}`, p, q), nil
+}
+
+// tikzHtml compiles the TikZ code and returns the image as HTML.
+func tikzHtml(code string) (string, error) {
+	img, err := graphics.SvgFromTikz(code, "")
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	img.ToHtml(&b)
+	return b.String(), nil
+}
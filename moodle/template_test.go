@@ -0,0 +1,140 @@
+package moodle
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ReneBoedker/MoodlishInquisition/unif"
+)
+
+func TestQuestionTemplate(t *testing.T) {
+	qt, err := NewNumericalTemplate(`{{set "a" (randInt 1 9)}}{{set "b" (randNonZero -3 3)}}What is \({{.a}} {{signed .b}}\)?`, 2)
+	if err != nil {
+		t.Fatalf("Parsing template caused error: %s", err)
+	}
+	answer, err := qt.AddAnswer("{{add .a .b}}", 100, "Since {{.a}} and {{.b}} sum to {{add .a .b}}")
+	if err != nil {
+		t.Fatalf("Parsing answer caused error: %s", err)
+	}
+	if err := answer.SetOption("tolerance", "{{div 1 (mul 2 .a)}}"); err != nil {
+		t.Fatalf("Parsing option caused error: %s", err)
+	}
+
+	questions, err := qt.Generate(10)
+	if err != nil {
+		t.Fatalf("Instantiating template caused error: %s", err)
+	}
+	for _, q := range questions {
+		num := q.(*Numerical)
+		var a, b int
+		if _, err := fmt.Sscanf(num.text, `What is \(%d %d\)?`, &a, &b); err != nil {
+			t.Fatalf("Unexpected text %q", num.text)
+		}
+		if a < 1 || a > 9 || b == 0 || b < -3 || b > 3 {
+			t.Errorf("Values %d and %d are outside the ranges", a, b)
+		}
+		if want := strconv.Itoa(a + b); num.answers[0].text != want || num.points != 2 {
+			t.Errorf("Answer was %q, but expected %q", num.answers[0].text, want)
+		}
+		if !strings.HasPrefix(num.answers[0].feedback, "Since ") {
+			t.Errorf("Unexpected feedback %q", num.answers[0].feedback)
+		}
+		if tol, _ := num.answers[0].GetOption("tolerance"); tol == "" {
+			t.Errorf("Tolerance was not set")
+		}
+	}
+
+	// Values from SetData
+	mc, err := NewMultiChoiceTemplate(`Which is \({{frac .p .q}}\)?`, 1)
+	if err != nil {
+		t.Fatalf("Parsing template caused error: %s", err)
+	}
+	mc.AddAnswer(`\({{latexNum (div .p .q)}}\)`, 100, "")
+	mc.AddAnswer(`\({{latexNum (div .q .p)}}\)`, 0, "")
+	mc.SetData(func() map[string]any { return map[string]any{"p": 6, "q": -4} })
+	q, err := mc.Instantiate()
+	if err != nil {
+		t.Fatalf("Instantiating template caused error: %s", err)
+	}
+	if m := q.(*MultiChoice); m.text != `Which is \(-\frac{3}{2}\)?` || m.answers[0].text != `\(-1.5\)` {
+		t.Errorf("Unexpected question %q with answers %q and %q", m.text, m.answers[0].text, m.answers[1].text)
+	}
+
+	// Errors
+	if _, err := NewShortTextTemplate("{{.a", 1); err == nil {
+		t.Errorf("Invalid template failed to return an error")
+	}
+	st, _ := NewShortTextTemplate("{{.missing}}", 1)
+	if _, err := st.Instantiate(); err == nil {
+		t.Errorf("Missing key failed to return an error")
+	}
+	st, _ = NewShortTextTemplate(`{{div 1 0}}`, 1)
+	if _, err := st.Generate(1); err == nil || !strings.Contains(err.Error(), "Division by zero") {
+		t.Errorf("Division by zero gave error %v", err)
+	}
+}
+
+func TestTemplateFormatting(t *testing.T) {
+	testCases := []struct {
+		s    string
+		want string
+	}{
+		{"{{latexNum 2.50}}", "2.5"},
+		{"{{latexNum -0.0}}", "0"},
+		{"{{latexNum 1e-7}}", `10^{-7}`},
+		{"{{latexNum -2.5e30}}", `-2.5\cdot 10^{30}`},
+		{"{{signed 3}} {{signed -3}}", "+3 -3"},
+		{"{{frac 4 2}} {{frac 0 5}} {{frac 3 -9}}", `2 0 -\frac{1}{3}`},
+		{"{{frac (mul 2 1.5) 6}}", `\frac{1}{2}`},
+		{"{{round 3.14159 2}}", "3.14"},
+		{"{{sub 1 0.25}}", "0.75"},
+		{"{{escapeMath `a<b`}}", `a\lt b`},
+	}
+	for _, v := range testCases {
+		tmpl, err := parseTemplate("test", v.s)
+		if err != nil {
+			t.Fatalf("Parsing %q caused error: %s", v.s, err)
+		}
+		got, err := executeTemplate(tmpl, nil, nil)
+		if err != nil {
+			t.Errorf("Executing %q caused error: %s", v.s, err)
+		} else if got != v.want {
+			t.Errorf("Executing %q gave %q, but expected %q", v.s, got, v.want)
+		}
+	}
+}
+
+func TestQuestionTemplateReproducible(t *testing.T) {
+	qt, _ := NewShortTextTemplate("Q", 1)
+	answer, _ := qt.AddAnswer("A", 100, "")
+	names := []string{"e", "d", "c", "b", "a"}
+	for _, v := range names {
+		answer.SetOption(v, "{{randInt 1 1000000}}")
+	}
+	answer.SetOption("e", "fixed")
+
+	draw := func() []string {
+		unif.Seed(7)
+		q, err := qt.Instantiate()
+		if err != nil {
+			t.Fatalf("Instantiating template caused error: %s", err)
+		}
+		values := make([]string, len(names))
+		for i, v := range names {
+			values[i], _ = q.(*ShortText).answers[0].GetOption(v)
+		}
+		return values
+	}
+
+	first := draw()
+	if first[0] != "fixed" {
+		t.Errorf("Option was not replaced: %q", first)
+	}
+	for i := 0; i < 10; i++ {
+		if again := draw(); strings.Join(again, " ") != strings.Join(first, " ") {
+			t.Fatalf("Same seed gave options %q and %q", first, again)
+		}
+	}
+}
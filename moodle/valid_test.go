@@ -75,7 +75,7 @@ func TestOutputValid(t *testing.T) {
 
 func TestEscapeMath(t *testing.T) {
 	testCases := [...][2]string{
-		{`$$(0,2\pi]$$`, `$$(0,2\pi&#93$$`},
+		{`$$(0,2\pi]$$`, `$$(0,2\pi&#93;$$`},
 		{`$$5<7$$`, `$$5\lt 7$$`},
 		{`$$3>1$$`, `$$3\gt 1$$`},
 	}
@@ -85,3 +85,79 @@ func TestEscapeMath(t *testing.T) {
 		}
 	}
 }
+
+func TestEscapeMathIn(t *testing.T) {
+	testCases := [...][2]string{
+		{`<b>Is</b> $$5<7$$?`, `<b>Is</b> $$5\lt 7$$?`},
+		{`He is [[1]] and \(x\in[0,1]\)`, `He is [[1]] and \(x\in&#91;0,1&#93;\)`},
+		{`<span title="$$a<b$$">\[a>b\]</span>`, `<span title="$$a<b$$">\[a\gt b\]</span>`},
+		{`Costs \$5 and $$x<1$$`, `Costs \$5 and $$x\lt 1$$`},
+		{`<!-- $$a<b$$ --> 1 < 2`, `<!-- $$a<b$$ --> 1 < 2`},
+		{`Unclosed $$a<b`, `Unclosed $$a<b`},
+	}
+	for _, v := range testCases {
+		if s := EscapeMathIn(v[0]); s != v[1] {
+			t.Errorf("Received %q, but expected %q", s, v[1])
+		}
+	}
+}
+
+func TestEscapeText(t *testing.T) {
+	s := `Use <b> & [[1]] for $$x$$ or \(y\)`
+	expected := `Use &lt;b&gt; &amp; &#91;&#91;1&#93;&#93; for &#36;&#36;x&#36;&#36; or &#92;(y&#92;)`
+	if got := EscapeText(s); got != expected {
+		t.Errorf("Received %q, but expected %q", got, expected)
+	}
+	if segs := segments(EscapeText(s)); len(segs) != 1 || segs[0].kind != textSegment {
+		t.Errorf("Escaped text contains special segments: %v", segs)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for i, q := range exampleBank().questions {
+		if err := q.Validate(); err != nil {
+			t.Errorf("Question %d of example bank produced error: %s", i, err)
+		}
+	}
+
+	testCases := []struct {
+		q        Question
+		expected string
+	}{
+		{NewMultiChoice("Q", 1, []*Answer{NewAnswer("A", 100)}), "At least 2 answers"},
+		{NewMultiChoice("Q", 1, []*Answer{NewAnswer("A", 0), NewAnswer("B", -50)}), "No answer has a positive grade"},
+		{NewMultiChoice("Q", 1, []*Answer{NewAnswer("A", 50), NewAnswer("B", 0)}), "must have grade 100"},
+		{NewMultiChoice("Q", 1, []*Answer{NewAnswer("A", 50), NewAnswer("B", 40)}), "add up to 90"},
+		{NewMultiChoice("Q", 1, []*Answer{NewAnswer("A", 100), NewAnswer("B", 35)}), "Grade 35 of answer 2"},
+		{NewShortText("Q", 1, []*Answer{NewAnswer("A", 50)}), "No answer has grade 100"},
+		{NewShortText("Q", 1, []*Answer{NewAnswer("A", 100), NewAnswer("B", -10)}), "negative grade"},
+		{NewShortText(" ", 1, []*Answer{NewAnswer("A", 100)}), "Question text is empty"},
+		{NewNumerical("Q", 1, []*Answer{NewAnswer("pi", 100)}), "not a number"},
+		{NewDropText("Q", 1, []*TextMark{NewTextMark("A", 0, false)}), "no [[n]] placeholders"},
+		{NewDropText("Q [[2]]", 1, []*TextMark{NewTextMark("A", 0, false)}), "Placeholder [[2]]"},
+		{NewDropText("Q [[1]] [[1]]", 1, []*TextMark{NewTextMark("A", 0, false)}), "not unlimited"},
+	}
+
+	for i, v := range testCases {
+		err := v.q.Validate()
+		if err == nil || !strings.Contains(err.Error(), v.expected) {
+			t.Errorf("Test case %d: Expected error containing %q, but got %v", i, v.expected, err)
+		}
+	}
+}
+
+func TestValidateSnapsGrades(t *testing.T) {
+	answers := []*Answer{NewAnswer("A", 1), NewAnswer("B", 1), NewAnswer("C", 1), NewAnswer("D", 0)}
+	mc := NewMultiChoice("Q", 1, answers)
+	mc.BalanceGrades(true)
+
+	if err := mc.Validate(); err != nil {
+		t.Fatalf("Balanced grades produced error: %s", err)
+	}
+	if g := answers[0].GetGrade(); g != 33.33333 {
+		t.Errorf("Grade was not snapped to 33.33333, but is %v", g)
+	}
+	if g := answers[3].GetGrade(); g != -33.33333 {
+		t.Errorf("Penalty was not snapped to -33.33333, but is %v", g)
+	}
+}
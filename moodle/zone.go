@@ -0,0 +1,278 @@
+package moodle
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/ReneBoedker/MoodlishInquisition/graphics"
+)
+
+// Shape is the shape of a drop zone in the 'Drag and drop markers' question
+// type.
+type Shape string
+
+// Shapes supported by Moodle.
+const (
+	Circle    Shape = "circle"
+	Rectangle Shape = "rectangle"
+	Polygon   Shape = "polygon"
+)
+
+// Zone describes drop zones in the 'Drag and drop markers' question type.
+type Zone struct {
+	shape       Shape
+	points      [][2]float64 // Center (circle), top left corner (rectangle) or vertices (polygon)
+	size        [2]float64   // Radius (circle) or width and height (rectangle)
+	correctMark int
+}
+
+// NewZone defines a new drop zone with the given parameters.
+// Supported shapes are Circle and Rectangle; polygons are created by
+// NewPolygonZone. The given coordinates describe the center of the zone. When
+// defining a circle, its diameter will be max(width, height).
+//
+// Note that coords should be specified in relation to the top left corner of
+// the image.
+func NewZone(shape Shape, coords [2]float64, width, height float64, correctMark int) (*Zone, error) {
+	z := &Zone{
+		shape:       Shape(strings.ToLower(string(shape))),
+		correctMark: correctMark,
+	}
+	switch z.shape {
+	case Circle:
+		if !(max(width, height) > 0) {
+			return nil, fmt.Errorf("Circle diameter must be positive, but received %g x %g", width, height)
+		}
+		z.points = [][2]float64{coords}
+		z.size = [2]float64{max(width, height) / 2}
+	case Rectangle:
+		if !(width > 0 && height > 0) {
+			return nil, fmt.Errorf("Zone dimensions must be positive, but received %g x %g", width, height)
+		}
+		z.points = [][2]float64{{coords[0] - width/2, coords[1] - height/2}}
+		z.size = [2]float64{width, height}
+	case Polygon:
+		return nil, fmt.Errorf("Polygon zones must be created using NewPolygonZone")
+	default:
+		return nil, fmt.Errorf("Unsupported shape %q", shape)
+	}
+	return z, nil
+}
+
+// NewPolygonZone defines a new polygonal drop zone with the given vertices. At
+// least three vertices are required, and the polygon must enclose a non-empty
+// area.
+//
+// As for NewZone, the vertices should be specified in relation to the top left
+// corner of the image. Moodle only supports whole pixels, so the vertices are
+// rounded.
+func NewPolygonZone(vertices [][2]float64, correctMark int) (*Zone, error) {
+	if len(vertices) < 3 {
+		return nil, fmt.Errorf("Polygon zones need at least 3 vertices, but received %d", len(vertices))
+	}
+
+	points := make([][2]float64, len(vertices))
+	for i, v := range vertices {
+		points[i] = [2]float64{math.Round(v[0]), math.Round(v[1])}
+	}
+	if polygonArea(points) == 0 {
+		return nil, fmt.Errorf("Polygon zone with vertices %v has no area", points)
+	}
+
+	return &Zone{
+		shape:       Polygon,
+		points:      points,
+		correctMark: correctMark,
+	}, nil
+}
+
+// NewNamedZone defines a new drop zone centered at the zone called name in img.
+// Such zones are defined in the TikZ source of the image using the \moodlezone
+// macro (see graphics.SvgImage.ZonePosition). The remaining parameters are as
+// in NewZone.
+func NewNamedZone(img *graphics.SvgImage, name string, shape Shape, width, height float64, correctMark int) (*Zone, error) {
+	coords, err := img.ZonePosition(name)
+	if err != nil {
+		return nil, err
+	}
+	return NewZone(shape, coords, width, height, correctMark)
+}
+
+// NewNamedPolygonZone defines a new polygonal drop zone whose vertices are the
+// zones called names in img. See NewNamedZone and NewPolygonZone for details.
+func NewNamedPolygonZone(img *graphics.SvgImage, names []string, correctMark int) (*Zone, error) {
+	vertices := make([][2]float64, len(names))
+	for i, v := range names {
+		var err error
+		if vertices[i], err = img.ZonePosition(v); err != nil {
+			return nil, err
+		}
+	}
+	return NewPolygonZone(vertices, correctMark)
+}
+
+// Shape returns the shape of z.
+func (z *Zone) Shape() Shape {
+	return z.shape
+}
+
+// coords returns the coordinates of z in the format used by Moodle.
+func (z *Zone) coords() string {
+	switch z.shape {
+	case Circle:
+		return fmt.Sprintf("%.0f,%.0f;%.0f", z.points[0][0], z.points[0][1], z.size[0])
+	case Rectangle:
+		return fmt.Sprintf("%.0f,%.0f;%.0f,%.0f", z.points[0][0], z.points[0][1], z.size[0], z.size[1])
+	}
+
+	vertices := make([]string, len(z.points))
+	for i, v := range z.points {
+		vertices[i] = fmt.Sprintf("%.0f,%.0f", v[0], v[1])
+	}
+	return strings.Join(vertices, ";")
+}
+
+// polygonArea returns the area enclosed by the polygon with the given vertices.
+func polygonArea(vertices [][2]float64) float64 {
+	var a float64
+	for i, v := range vertices {
+		w := vertices[(i+1)%len(vertices)]
+		a += v[0]*w[1] - w[0]*v[1]
+	}
+	return math.Abs(a) / 2
+}
+
+// bounds returns the top left and bottom right corners of the smallest
+// rectangle containing z.
+func (z *Zone) bounds() (topLeft, bottomRight [2]float64) {
+	switch z.shape {
+	case Circle:
+		c, r := z.points[0], z.size[0]
+		return [2]float64{c[0] - r, c[1] - r}, [2]float64{c[0] + r, c[1] + r}
+	case Rectangle:
+		p := z.points[0]
+		return p, [2]float64{p[0] + z.size[0], p[1] + z.size[1]}
+	}
+
+	topLeft, bottomRight = z.points[0], z.points[0]
+	for _, v := range z.points[1:] {
+		topLeft = [2]float64{min(topLeft[0], v[0]), min(topLeft[1], v[1])}
+		bottomRight = [2]float64{max(bottomRight[0], v[0]), max(bottomRight[1], v[1])}
+	}
+	return topLeft, bottomRight
+}
+
+// polygon returns the vertices of z if it is a rectangle or a polygon.
+func (z *Zone) polygon() [][2]float64 {
+	if z.shape != Rectangle {
+		return z.points
+	}
+	p, s := z.points[0], z.size
+	return [][2]float64{p, {p[0] + s[0], p[1]}, {p[0] + s[0], p[1] + s[1]}, {p[0], p[1] + s[1]}}
+}
+
+// overlaps reports whether the interiors of z and o intersect. Zones that
+// merely touch do not overlap.
+func (z *Zone) overlaps(o *Zone) bool {
+	switch {
+	case z.shape == Circle && o.shape == Circle:
+		d := math.Hypot(z.points[0][0]-o.points[0][0], z.points[0][1]-o.points[0][1])
+		return d < z.size[0]+o.size[0]
+	case z.shape == Circle:
+		return circleOverlapsPolygon(z.points[0], z.size[0], o.polygon())
+	case o.shape == Circle:
+		return circleOverlapsPolygon(o.points[0], o.size[0], z.polygon())
+	}
+	return polygonsOverlap(z.polygon(), o.polygon())
+}
+
+// circleOverlapsPolygon reports whether the circle with the given center and
+// radius overlaps the polygon with the given vertices.
+func circleOverlapsPolygon(center [2]float64, radius float64, vertices [][2]float64) bool {
+	if insidePolygon(center, vertices) {
+		return true
+	}
+	for i, v := range vertices {
+		if segmentDistance(center, v, vertices[(i+1)%len(vertices)]) < radius {
+			return true
+		}
+	}
+	return false
+}
+
+// polygonsOverlap reports whether the polygons a and b overlap.
+func polygonsOverlap(a, b [][2]float64) bool {
+	for i, p := range a {
+		p2 := a[(i+1)%len(a)]
+		for j, q := range b {
+			if segmentsCross(p, p2, q, b[(j+1)%len(b)]) {
+				return true
+			}
+		}
+	}
+
+	// Without crossing edges, one polygon may still contain the other. Points
+	// on the boundary are not inside, so an edge midpoint and the centroid are
+	// both tested.
+	return insidePolygon(midpoint(a[0], a[1]), b) || insidePolygon(midpoint(b[0], b[1]), a) ||
+		insidePolygon(centroid(a), b) || insidePolygon(centroid(b), a)
+}
+
+// insidePolygon reports whether p lies strictly inside the polygon with the
+// given vertices.
+func insidePolygon(p [2]float64, vertices [][2]float64) bool {
+	inside := false
+	for i, v := range vertices {
+		w := vertices[(i+1)%len(vertices)]
+		if segmentDistance(p, v, w) == 0 {
+			return false
+		}
+		if (v[1] > p[1]) != (w[1] > p[1]) && p[0] < v[0]+(p[1]-v[1])*(w[0]-v[0])/(w[1]-v[1]) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// segmentsCross reports whether the line segments p1p2 and q1q2 cross each
+// other at a single point that is interior to both.
+func segmentsCross(p1, p2, q1, q2 [2]float64) bool {
+	d1 := orientation(q1, q2, p1)
+	d2 := orientation(q1, q2, p2)
+	d3 := orientation(p1, p2, q1)
+	d4 := orientation(p1, p2, q2)
+	return d1*d2 < 0 && d3*d4 < 0
+}
+
+// orientation returns a positive number if a, b, c are in counterclockwise
+// order, a negative number if they are in clockwise order, and 0 if they are
+// collinear.
+func orientation(a, b, c [2]float64) float64 {
+	return (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+}
+
+// segmentDistance returns the distance from p to the line segment ab.
+func segmentDistance(p, a, b [2]float64) float64 {
+	d := [2]float64{b[0] - a[0], b[1] - a[1]}
+	t := 0.0
+	if l := d[0]*d[0] + d[1]*d[1]; l > 0 {
+		t = max(0, min(1, ((p[0]-a[0])*d[0]+(p[1]-a[1])*d[1])/l))
+	}
+	return math.Hypot(p[0]-a[0]-t*d[0], p[1]-a[1]-t*d[1])
+}
+
+// midpoint returns the midpoint of the line segment ab.
+func midpoint(a, b [2]float64) [2]float64 {
+	return [2]float64{(a[0] + b[0]) / 2, (a[1] + b[1]) / 2}
+}
+
+// centroid returns the average of the given vertices.
+func centroid(vertices [][2]float64) [2]float64 {
+	var c [2]float64
+	for _, v := range vertices {
+		c[0] += v[0] / float64(len(vertices))
+		c[1] += v[1] / float64(len(vertices))
+	}
+	return c
+}
@@ -0,0 +1,174 @@
+package moodle
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ReneBoedker/MoodlishInquisition/graphics"
+)
+
+func TestNewZone(t *testing.T) {
+	testCases := []struct {
+		shape    Shape
+		coords   string
+		hasError bool
+	}{
+		{Circle, "50,40;15", false},
+		{Rectangle, "40,25;20,30", false},
+		{"Circle", "50,40;15", false},
+		{Polygon, "", true},
+		{"triangle", "", true},
+	}
+
+	for _, v := range testCases {
+		z, err := NewZone(v.shape, [2]float64{50, 40}, 20, 30, 0)
+		if (err != nil) != v.hasError {
+			t.Errorf("Shape %q: Unexpected error value %v", v.shape, err)
+			continue
+		}
+		if err == nil && z.coords() != v.coords {
+			t.Errorf("Shape %q: Expected coordinates %q, but got %q", v.shape, v.coords, z.coords())
+		}
+	}
+
+	if _, err := NewZone(Rectangle, [2]float64{50, 40}, 0, 30, 0); err == nil {
+		t.Errorf("Rectangle with zero width failed to return an error")
+	}
+	if _, err := NewZone(Circle, [2]float64{50, 40}, 0, 0, 0); err == nil {
+		t.Errorf("Circle with zero diameter failed to return an error")
+	}
+
+	// The diameter of a circle is the larger of width and height
+	z, err := NewZone(Circle, [2]float64{50, 40}, 30, 0, 0)
+	if err != nil {
+		t.Fatalf("Circle given by its width caused error: %s", err)
+	}
+	if z.coords() != "50,40;15" {
+		t.Errorf("Expected coordinates %q, but got %q", "50,40;15", z.coords())
+	}
+}
+
+func TestNewPolygonZone(t *testing.T) {
+	z, err := NewPolygonZone([][2]float64{{10.2, 10}, {50, 10.7}, {30, 40}}, 1)
+	if err != nil {
+		t.Fatalf("Creating polygon zone caused error: %s", err)
+	}
+	if z.Shape() != Polygon || z.coords() != "10,10;50,11;30,40" {
+		t.Errorf("Unexpected polygon %s with coordinates %q", z.Shape(), z.coords())
+	}
+
+	for _, v := range [][][2]float64{
+		{{0, 0}, {10, 10}},
+		{{0, 0}, {10, 10}, {20, 20}},
+		{{0, 0}, {0.2, 0.1}, {0.4, 0.3}},
+	} {
+		if _, err := NewPolygonZone(v, 0); err == nil {
+			t.Errorf("Polygon %v failed to return an error", v)
+		}
+	}
+}
+
+func TestPolygonZoneXml(t *testing.T) {
+	img, _ := graphics.ImageFromBytes([]byte("GIF89a"), "gif")
+	z, _ := NewPolygonZone([][2]float64{{0, 0}, {10, 0}, {10, 10}, {0, 10}}, 0)
+	dm := NewDropMarker("Mark the square", img, 1, []*Mark{NewMark("A", 1)}, []*Zone{z})
+
+	var b strings.Builder
+	dm.ToXml(&b)
+	if !strings.Contains(b.String(), "<shape>polygon</shape>\n\t\t<coords>0,0;10,0;10,10;0,10</coords>") {
+		t.Errorf("Polygon zone was not written correctly:\n%s", b.String())
+	}
+}
+
+func TestZoneOverlaps(t *testing.T) {
+	circle := func(x, y, d float64) *Zone {
+		z, _ := NewZone(Circle, [2]float64{x, y}, d, d, 0)
+		return z
+	}
+	rect := func(x, y, w, h float64) *Zone {
+		z, _ := NewZone(Rectangle, [2]float64{x, y}, w, h, 0)
+		return z
+	}
+	poly := func(vertices ...[2]float64) *Zone {
+		z, _ := NewPolygonZone(vertices, 0)
+		return z
+	}
+
+	testCases := []struct {
+		a, b     *Zone
+		expected bool
+	}{
+		{circle(0, 0, 20), circle(15, 0, 20), true},
+		{circle(0, 0, 20), circle(20, 0, 20), false},
+		{rect(0, 0, 10, 10), rect(5, 5, 10, 10), true},
+		{rect(0, 0, 10, 10), rect(10, 0, 10, 10), false},
+		{rect(0, 0, 10, 10), rect(0, 0, 10, 10), true},
+		{rect(0, 0, 40, 40), rect(0, 0, 10, 10), true},
+		{circle(0, 0, 10), rect(8, 0, 10, 10), true},
+		{circle(0, 0, 10), rect(20, 0, 10, 10), false},
+		{circle(0, 0, 4), rect(0, 0, 40, 40), true},
+		{poly([2]float64{0, 0}, [2]float64{10, 0}, [2]float64{0, 10}), rect(9, 9, 4, 4), false},
+		{poly([2]float64{0, 0}, [2]float64{10, 0}, [2]float64{0, 10}), rect(4, 4, 4, 4), true},
+	}
+
+	for i, v := range testCases {
+		if got := v.a.overlaps(v.b); got != v.expected {
+			t.Errorf("Test case %d: Expected overlap %t, but got %t", i, v.expected, got)
+		}
+		if got := v.b.overlaps(v.a); got != v.expected {
+			t.Errorf("Test case %d: Overlap is not symmetric", i)
+		}
+	}
+}
+
+func TestDropMarkerValidate(t *testing.T) {
+	img, err := graphics.SvgFromBytes([]byte(`<svg width="75pt" height="75pt"></svg>`))
+	if err != nil {
+		t.Fatalf("Reading svg caused error: %s", err)
+	}
+
+	// The image is 100x100 px
+	zone := func(x, y float64, mark int) *Zone {
+		z, _ := NewZone(Circle, [2]float64{x, y}, 20, 20, mark)
+		return z
+	}
+	marks := []*Mark{NewMark("A", 1), NewMark("B", 0)}
+
+	dm := NewDropMarker("Valid", img, 1, marks, []*Zone{zone(20, 20, 0), zone(50, 50, 1), zone(60, 50, 1)})
+	if err := dm.Validate(); err != nil {
+		t.Errorf("Valid question produced error: %s", err)
+	}
+
+	dm = NewDropMarker("Invalid", img, 1, marks, []*Zone{
+		zone(20, 20, 0),
+		zone(95, 50, 1), // Outside the image
+		zone(25, 20, 1), // Overlaps zone 1
+		zone(50, 50, 2), // Nonexistent marker
+		zone(80, 80, 0), // Marker A is needed twice
+	})
+	err = dm.Validate()
+	if err == nil {
+		t.Fatalf("Invalid question failed to return an error")
+	}
+	for _, v := range []string{
+		"Zone 2 is not within the 100x100 image",
+		"Zone 3 overlaps zone 1",
+		"Zone 4 refers to marker 3",
+		"Marker 1 can be dragged 1 times, but is needed in 2 zones",
+	} {
+		if !strings.Contains(err.Error(), v) {
+			t.Errorf("Error does not contain %q:\n%s", v, err)
+		}
+	}
+
+	// Sizes in other units are converted to px
+	mm, _ := graphics.SvgFromBytes([]byte(`<svg width="25.4mm" height="0.5in"></svg>`))
+	dm = NewDropMarker("Millimetres", mm, 1, marks, []*Zone{zone(20, 20, 0), zone(80, 30, 1)})
+	if err := dm.Validate(); err != nil {
+		t.Errorf("Zones within the 96x48 image produced error: %s", err)
+	}
+	dm = NewDropMarker("Millimetres", mm, 1, marks, []*Zone{zone(20, 20, 0), zone(80, 40, 1)})
+	if err := dm.Validate(); err == nil || !strings.Contains(err.Error(), "Zone 2 is not within the 96x48 image") {
+		t.Errorf("Zone outside the 96x48 image gave error %v", err)
+	}
+}
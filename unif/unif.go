@@ -6,6 +6,28 @@ import (
 	"math/rand/v2"
 )
 
+// source is the generator used by the functions of this package. If nil, the
+// global generator of math/rand/v2 is used.
+var source *rand.Rand
+
+// Seed makes the functions of this package use a generator with the given
+// seed, such that the same sequence of values is generated in each run. This
+// is for instance useful for regenerating a question bank.
+//
+// Unlike the default generator, the seeded generator is not safe for
+// concurrent use.
+func Seed(seed uint64) {
+	source = rand.New(rand.NewPCG(seed, seed))
+}
+
+// intN returns a uniformly random integer in [0,n).
+func intN(n int) int {
+	if source != nil {
+		return source.IntN(n)
+	}
+	return rand.IntN(n)
+}
+
 // IntInInterval generates a uniformly random integer in [a,b].
 // If a>b, the function panics
 func IntInInterval(a, b int, allowZero bool) int {
@@ -13,7 +35,7 @@ func IntInInterval(a, b int, allowZero bool) int {
 		panic(fmt.Errorf("Cannot generate integer in empty interval [%d, %d]", a, b))
 	}
 	for {
-		tmp := a + rand.IntN(b-a+1)
+		tmp := a + intN(b-a+1)
 		if allowZero || tmp != 0 {
 			return tmp
 		}
@@ -30,5 +52,5 @@ func BoundedInt(a int, allowZero bool) int {
 
 // Bool generates a uniformly random boolean value.
 func Bool() bool {
-	return rand.Uint32()&1 == 1
+	return intN(2) == 1
 }